@@ -0,0 +1,59 @@
+package geminirod
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// retryableStatusCodes are API responses worth retrying: rate limits and server-side failures
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// isRetryableAPIError reports whether err is a transient API failure
+func isRetryableAPIError(err error) bool {
+	var apiErr genai.APIError
+	return errors.As(err, &apiErr) && retryableStatusCodes[apiErr.Code]
+}
+
+// retryAfter returns the delay the API asked for in a RetryInfo detail, or 0
+func retryAfter(err error) time.Duration {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return 0
+	}
+	for _, detail := range apiErr.Details {
+		if kind, _ := detail["@type"].(string); !strings.HasSuffix(kind, "RetryInfo") {
+			continue
+		}
+		// Durations are encoded in JSON as strings such as "4s" or "1.5s"
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// backoffDelay returns the delay before the given retry, starting from 1: exponential from initial,
+// capped at max, with jitter between half and the full delay. A delay requested by the API takes precedence.
+func backoffDelay(err error, retry int, initial, max time.Duration) time.Duration {
+	if d := retryAfter(err); d > 0 {
+		return d
+	}
+	delay := initial << (retry - 1)
+	if delay <= 0 || delay > max {
+		delay = max
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,112 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestIsRetryableAPIError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "rate limited", err: genai.APIError{Code: http.StatusTooManyRequests}, want: true},
+		{name: "unavailable", err: genai.APIError{Code: http.StatusServiceUnavailable}, want: true},
+		{name: "wrapped", err: fmt.Errorf("turn 3: %w", genai.APIError{Code: http.StatusInternalServerError}), want: true},
+		{name: "bad request", err: genai.APIError{Code: http.StatusBadRequest}},
+		{name: "not an API error", err: errors.New("connection reset")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableAPIError(tt.err); got != tt.want {
+				t.Errorf("isRetryableAPIError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	rateLimited := genai.APIError{Code: http.StatusTooManyRequests}
+	tests := []struct {
+		name     string
+		err      error
+		retry    int
+		min, max time.Duration
+	}{
+		{name: "first retry", err: rateLimited, retry: 1, min: 500 * time.Millisecond, max: time.Second},
+		{name: "doubled", err: rateLimited, retry: 3, min: 2 * time.Second, max: 4 * time.Second},
+		{name: "capped", err: rateLimited, retry: 10, min: 5 * time.Second, max: 10 * time.Second},
+		{name: "shift overflow capped", err: rateLimited, retry: 80, min: 5 * time.Second, max: 10 * time.Second},
+		{
+			name: "delay requested by the API",
+			err: genai.APIError{Code: http.StatusTooManyRequests, Details: []map[string]any{
+				{"@type": "type.googleapis.com/google.rpc.QuotaFailure"},
+				{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "42s"},
+			}},
+			retry: 1, min: 42 * time.Second, max: 42 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				if d := backoffDelay(tt.err, tt.retry, time.Second, 10*time.Second); d < tt.min || d > tt.max {
+					t.Fatalf("delay = %v, want between %v and %v", d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestModelCallRetries(t *testing.T) {
+	rateLimited := fakeReply{Status: http.StatusTooManyRequests, Message: "Resource has been exhausted"}
+	tests := []struct {
+		name        string
+		replies     []fakeReply
+		maxRetries  int
+		wantRetries int
+		wantCalls   int
+		wantErr     bool
+	}{
+		{name: "recovers", replies: []fakeReply{rateLimited, {Status: http.StatusServiceUnavailable}}, maxRetries: 3, wantRetries: 2, wantCalls: 3},
+		{name: "retries exhausted", replies: []fakeReply{rateLimited, rateLimited, rateLimited}, maxRetries: 2, wantRetries: 2, wantCalls: 3, wantErr: true},
+		{name: "not retryable", replies: []fakeReply{{Status: http.StatusForbidden, Message: "permission denied"}}, maxRetries: 3, wantCalls: 1, wantErr: true},
+		{name: "retries disabled", replies: []fakeReply{rateLimited}, maxRetries: -1, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, tt.replies...)
+			config, _ := testConfig(t, backend)
+			config.MaxRetries = tt.maxRetries
+			clock := config.Clock.(*fakeClock)
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := len(backend.calls()); got != tt.wantCalls {
+				t.Errorf("got %d model calls, want %d", got, tt.wantCalls)
+			}
+
+			retries := eventsOf[RetryEvent](events)
+			if len(retries) != tt.wantRetries {
+				t.Fatalf("got %d RetryEvents, want %d", len(retries), tt.wantRetries)
+			}
+			sleeps := clock.sleeps()
+			for i, retry := range retries {
+				if retry.Attempt != i+1 || retry.MaxRetries != tt.maxRetries || retry.Err == nil {
+					t.Errorf("RetryEvent %d = %+v, want attempt %d of %d with its error", i, retry, i+1, tt.maxRetries)
+				}
+				if i >= len(sleeps) || sleeps[i] != retry.Delay {
+					t.Errorf("slept %v, want the delay %v of each RetryEvent", sleeps, retry.Delay)
+				}
+			}
+		})
+	}
+}
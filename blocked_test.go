@@ -0,0 +1,79 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestBlockedPrompt(t *testing.T) {
+	ratings := []*genai.SafetyRating{{Category: genai.HarmCategoryDangerousContent, Probability: genai.HarmProbabilityHigh, Blocked: true}}
+	tests := []struct {
+		name       string
+		candidates []*genai.Candidate
+	}{
+		{name: "no candidate"},
+		{name: "candidate without content", candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonSafety}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: &genai.GenerateContentResponse{
+				Candidates: tt.candidates,
+				PromptFeedback: &genai.GenerateContentResponsePromptFeedback{
+					BlockReason:        genai.BlockedReasonSafety,
+					BlockReasonMessage: "prompt blocked",
+					SafetyRatings:      ratings,
+				},
+			}})
+			config, _ := testConfig(t, backend)
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed with %v, want a BlockedEvent", err)
+			}
+			blocked := eventsOf[BlockedEvent](events)
+			if len(blocked) != 1 {
+				t.Fatalf("got %d BlockedEvents, want 1", len(blocked))
+			}
+			got := blocked[0]
+			if got.Reason != string(genai.BlockedReasonSafety) || got.Message != "prompt blocked" {
+				t.Errorf("BlockedEvent = %+v, want the block reason and message", got)
+			}
+			if len(got.SafetyRatings) != 1 || got.SafetyRatings[0].Category != genai.HarmCategoryDangerousContent {
+				t.Errorf("SafetyRatings = %+v, want the ratings of the feedback", got.SafetyRatings)
+			}
+			if calls := len(backend.calls()); calls != 1 {
+				t.Errorf("got %d model calls, want the blocked prompt not retried", calls)
+			}
+		})
+	}
+}
+
+func TestEmptyCandidatesEndTheRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *genai.GenerateContentResponse
+	}{
+		{name: "no candidate", response: &genai.GenerateContentResponse{}},
+		{name: "nil content", response: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonStop}}}},
+		{name: "feedback without a block reason", response: &genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: tt.response})
+			config, _ := testConfig(t, backend)
+			config.MaxEmptyResponseRetries = -1
+
+			// collectEvents returns once the channel is closed, so a panic or a hang fails the test
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); !errors.Is(err, ErrEmptyResponse) {
+				t.Fatalf("error = %v, want ErrEmptyResponse", err)
+			}
+			if blocked := eventsOf[BlockedEvent](events); len(blocked) != 0 {
+				t.Errorf("got %v, want no BlockedEvent without a block reason", blocked)
+			}
+		})
+	}
+}
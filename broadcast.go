@@ -0,0 +1,242 @@
+package geminirod
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoDecider rejects the function calls a Broadcaster receives while no decider is subscribed
+var ErrNoDecider = errors.New("no decider subscribed to answer the function call")
+
+// Broadcaster fans the events of one run out to several subscribers.
+//
+// Informational events go to every subscriber through its own buffer. When a buffer is full, the event is dropped
+// for that subscriber only and counted, see Dropped, so a slow subscriber never holds up the others or the run.
+// Events that need an answer have a single responder: SafetyConfirmationEvents and the custom function calls of
+// ProgressEvents are delivered only to the decider, see SubscribeDecider, without dropping. Other subscribers see
+// ProgressEvents with copies of the calls that cannot be answered, and do not see SafetyConfirmationEvents.
+// Without a decider, such events are answered at once rather than left waiting: the first custom call needing action
+// is rejected with ErrNoDecider, ending the run, and safety confirmations are denied.
+//
+// Each subscriber receives events in the order of the source. Subscriber channels are closed when the source is;
+// subscribing after that returns a channel that is already closed.
+type Broadcaster struct {
+	events <-chan Event
+
+	mu          sync.Mutex
+	subscribers []*subscriber
+	decider     *subscriber
+	finished    bool // The source is closed and drained, later subscribers get closed channels
+	startOnce   sync.Once
+}
+
+type subscriber struct {
+	ch      chan Event
+	done    chan struct{}
+	dropped atomic.Int64
+
+	// sendMu serializes sending on ch with closing it
+	sendMu    sync.Mutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewBroadcaster creates a Broadcaster for the events returned by StartLoop.
+// Subscribe, including the decider, before calling Start so no event is missed.
+func NewBroadcaster(events <-chan Event) *Broadcaster {
+	return &Broadcaster{events: events}
+}
+
+// Subscribe returns a channel receiving informational events, buffering up to buffer of them
+func (b *Broadcaster) Subscribe(buffer int) <-chan Event {
+	sub := newSubscriber(buffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finished {
+		sub.close()
+		return sub.ch
+	}
+	b.subscribers = append(b.subscribers, sub)
+	return sub.ch
+}
+
+// SubscribeDecider returns the channel of the subscriber answering function calls and safety confirmations.
+// It receives every event unchanged and is never dropped from, so it must keep reading.
+// A later call replaces the previous decider, closing its channel.
+func (b *Broadcaster) SubscribeDecider() <-chan Event {
+	sub := newSubscriber(0)
+	b.mu.Lock()
+	if b.finished {
+		b.mu.Unlock()
+		sub.close()
+		return sub.ch
+	}
+	previous := b.decider
+	b.decider = sub
+	b.mu.Unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+	return sub.ch
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe or SubscribeDecider and closes it
+func (b *Broadcaster) Unsubscribe(ch <-chan Event) {
+	b.mu.Lock()
+	var found *subscriber
+	if b.decider != nil && b.decider.ch == ch {
+		found = b.decider
+		b.decider = nil
+	}
+	for i, sub := range b.subscribers {
+		if sub.ch == ch {
+			found = sub
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	if found != nil {
+		found.close()
+	}
+}
+
+// Dropped returns the number of events dropped for a subscriber because its buffer was full
+func (b *Broadcaster) Dropped(ch <-chan Event) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subscribers {
+		if sub.ch == ch {
+			return int(sub.dropped.Load())
+		}
+	}
+	return 0
+}
+
+// Start forwards events to the subscribers until the source is closed. Calling it again has no effect.
+func (b *Broadcaster) Start() {
+	b.startOnce.Do(func() {
+		go b.run()
+	})
+}
+
+func (b *Broadcaster) run() {
+	for event := range b.events {
+		b.mu.Lock()
+		subscribers := append([]*subscriber(nil), b.subscribers...)
+		decider := b.decider
+		b.mu.Unlock()
+
+		if decider == nil || !decider.send(event) {
+			answerWithoutDecider(event)
+		}
+		if informational, ok := informationalCopy(event); ok {
+			for _, sub := range subscribers {
+				sub.trySend(informational)
+			}
+		}
+	}
+
+	b.mu.Lock()
+	subscribers := b.subscribers
+	decider := b.decider
+	b.subscribers, b.decider = nil, nil
+	b.finished = true
+	b.mu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.close()
+	}
+	if decider != nil {
+		decider.close()
+	}
+}
+
+// answerWithoutDecider answers the events needing a decision that no decider received
+func answerWithoutDecider(event Event) {
+	switch e := event.(type) {
+	case SafetyConfirmationEvent:
+		e.Deny()
+	case ProgressEvent:
+		// Rejecting one call ends the run, the others are never awaited
+		for _, fc := range e.FunctionCalls {
+			if fc.NeedsAction() {
+				fc.Reject(ErrNoDecider)
+				return
+			}
+		}
+	}
+}
+
+// informationalCopy returns the event as seen by subscribers other than the decider, if they see it at all
+func informationalCopy(event Event) (Event, bool) {
+	switch e := event.(type) {
+	case SafetyConfirmationEvent:
+		return nil, false
+	case ProgressEvent:
+		calls := make([]*FunctionCall, len(e.FunctionCalls))
+		for i, fc := range e.FunctionCalls {
+			calls[i] = &FunctionCall{
+				FunctionName: fc.FunctionName,
+				Args:         fc.Args,
+				Info:         fc.Info,
+				needsAction:  fc.needsAction,
+			}
+		}
+		e.FunctionCalls = calls
+		return e, true
+	default:
+		return event, true
+	}
+}
+
+func newSubscriber(buffer int) *subscriber {
+	return &subscriber{
+		ch:   make(chan Event, max(buffer, 0)),
+		done: make(chan struct{}),
+	}
+}
+
+// send delivers an event, waiting until it is received or the subscriber is closed.
+// It reports whether the event was delivered.
+func (s *subscriber) send(event Event) bool {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case s.ch <- event:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// trySend delivers an event if the buffer has room, counting it as dropped otherwise
+func (s *subscriber) trySend(event Event) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// close stops delivery and closes the channel, unblocking a pending send first
+func (s *subscriber) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.sendMu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.sendMu.Unlock()
+	})
+}
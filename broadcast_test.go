@@ -0,0 +1,227 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// recordedCall creates a function call needing action, recording the rejection it gets
+func recordedCall(name string) (*FunctionCall, *error) {
+	var rejected error
+	fc := NewFunctionCall(name, nil, func(map[string]any) {}, func(err error) { rejected = err })
+	return fc, &rejected
+}
+
+// drain reads a channel until it is closed
+func drain(ch <-chan Event) []Event {
+	var events []Event
+	for event := range ch {
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestBroadcasterSlowSubscriberIsolation(t *testing.T) {
+	const total = 100
+	source := make(chan Event)
+	b := NewBroadcaster(source)
+	fast := b.Subscribe(total)
+	slow := b.Subscribe(1)
+	b.Start()
+
+	// The slow subscriber does not read until the source is done; the source must not wait for it
+	sent := make(chan struct{})
+	go func() {
+		for i := range total {
+			source <- WarningEvent{Message: fmt.Sprint(i)}
+		}
+		close(source)
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("source held up by the slow subscriber")
+	}
+
+	fastEvents := drain(fast)
+	if len(fastEvents) != total {
+		t.Fatalf("fast subscriber got %d events, want %d", len(fastEvents), total)
+	}
+	for i, event := range fastEvents {
+		if event.(WarningEvent).Message != fmt.Sprint(i) {
+			t.Fatalf("event %d = %v, want the source order", i, event)
+		}
+	}
+	if got := len(drain(slow)); got != 1 {
+		t.Errorf("slow subscriber got %d events, want its buffer of 1", got)
+	}
+}
+
+func TestBroadcasterDroppedCount(t *testing.T) {
+	source := make(chan Event)
+	b := NewBroadcaster(source)
+	slow := b.Subscribe(2)
+	b.Start()
+
+	for range 5 {
+		source <- WarningEvent{}
+	}
+	// The count is updated by the forwarding goroutine; a sixth send completes only once the fifth is handled
+	source <- WarningEvent{}
+	if got := b.Dropped(slow); got < 3 {
+		t.Errorf("Dropped = %d, want at least 3 of the events beyond the buffer", got)
+	}
+	close(source)
+	drain(slow)
+}
+
+func TestBroadcasterDecider(t *testing.T) {
+	source := make(chan Event)
+	b := NewBroadcaster(source)
+	decider := b.SubscribeDecider()
+	observer := b.Subscribe(10)
+	b.Start()
+
+	fc, rejected := recordedCall("query_table")
+	approved := false
+	go func() {
+		source <- ProgressEvent{FunctionCalls: []*FunctionCall{fc}}
+		source <- NewSafetyConfirmationEvent("confirm", func() { approved = true }, nil)
+		close(source)
+	}()
+
+	var deciderEvents []Event
+	for event := range decider {
+		deciderEvents = append(deciderEvents, event)
+		switch e := event.(type) {
+		case ProgressEvent:
+			e.FunctionCalls[0].Reject(errors.New("decided"))
+		case SafetyConfirmationEvent:
+			e.Approve()
+		}
+	}
+	if len(deciderEvents) != 2 {
+		t.Fatalf("decider got %d events, want the progress and the safety confirmation", len(deciderEvents))
+	}
+	if *rejected == nil || (*rejected).Error() != "decided" || !approved {
+		t.Errorf("rejected = %v, approved = %v, want the decider's answers", *rejected, approved)
+	}
+
+	observed := drain(observer)
+	if len(observed) != 1 {
+		t.Fatalf("observer got %d events, want only the progress", len(observed))
+	}
+	copied := observed[0].(ProgressEvent).FunctionCalls[0]
+	copied.Reject(errors.New("observer"))
+	if (*rejected).Error() != "decided" {
+		t.Error("observer could answer the call")
+	}
+}
+
+func TestBroadcasterWithoutDecider(t *testing.T) {
+	source := make(chan Event)
+	b := NewBroadcaster(source)
+	observer := b.Subscribe(10)
+	b.Start()
+
+	builtIn := NewFunctionCall("click_at", nil, nil, nil)
+	first, firstRejected := recordedCall("query_table")
+	second, secondRejected := recordedCall("query_users")
+	denied := false
+
+	done := make(chan struct{})
+	go func() {
+		source <- ProgressEvent{FunctionCalls: []*FunctionCall{builtIn, first, second}}
+		source <- NewSafetyConfirmationEvent("confirm", nil, func() { denied = true })
+		close(source)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("events needing a decision left waiting without a decider")
+	}
+	drain(observer)
+
+	if !errors.Is(*firstRejected, ErrNoDecider) {
+		t.Errorf("first call rejected with %v, want ErrNoDecider", *firstRejected)
+	}
+	if *secondRejected != nil {
+		t.Errorf("second call rejected with %v, want only the first rejected", *secondRejected)
+	}
+	if !denied {
+		t.Error("safety confirmation not denied")
+	}
+}
+
+func TestBroadcasterDeciderUnsubscribed(t *testing.T) {
+	source := make(chan Event)
+	b := NewBroadcaster(source)
+	decider := b.SubscribeDecider()
+	observer := b.Subscribe(10)
+	b.Start()
+
+	fc, rejected := recordedCall("query_table")
+	done := make(chan struct{})
+	go func() {
+		source <- WarningEvent{}
+		source <- ProgressEvent{FunctionCalls: []*FunctionCall{fc}}
+		close(source)
+		close(done)
+	}()
+
+	// The decider stops reading after the first event, leaving the progress pending
+	<-decider
+	b.Unsubscribe(decider)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending call left waiting after the decider unsubscribed")
+	}
+	drain(observer) // Closed once the forwarding is over
+	if !errors.Is(*rejected, ErrNoDecider) {
+		t.Errorf("call rejected with %v, want ErrNoDecider", *rejected)
+	}
+}
+
+func TestBroadcasterLoopWithoutDecider(t *testing.T) {
+	backend := newFakeBackend(t, fakeReply{Response: callResponse(call("query_table", nil))})
+	config, _ := testConfig(t, backend)
+
+	b := NewBroadcaster(StartLoop(context.Background(), config))
+	observer := b.Subscribe(100)
+	b.Start()
+
+	events := drain(observer)
+	if err := finalError(events); !errors.Is(err, ErrNoDecider) {
+		t.Fatalf("error = %v, want the run ended by ErrNoDecider", err)
+	}
+}
+
+func TestBroadcasterSubscribeAfterSourceClosed(t *testing.T) {
+	source := make(chan Event)
+	b := NewBroadcaster(source)
+	observer := b.Subscribe(10)
+	b.Start()
+	close(source)
+	drain(observer) // Closed once the forwarding is over
+
+	late := map[string]<-chan Event{
+		"subscriber": b.Subscribe(10),
+		"decider":    b.SubscribeDecider(),
+	}
+	for name, ch := range late {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Errorf("%s received an event after the source closed", name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s channel left open after the source closed", name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package geminirod
+
+import (
+	"context"
+	"fmt"
+
+	computeruse "github.com/PeronGH/computer-use-lib"
+)
+
+// normalizedGridSize is the size of the coordinate grid the computer-use model outputs
+const normalizedGridSize = 1000
+
+// BrowserConfig configures a browser session created by NewBrowserSession
+type BrowserConfig struct {
+	Width           int    // Viewport width in pixels. Default: 1440, must be set together with Height
+	Height          int    // Viewport height in pixels. Default: 900, must be set together with Width
+	InitialURL      string // Default: "about:blank"
+	SearchEngineURL string // URL opened by the search tool. Default: computer-use-lib's default
+	Headful         bool   // Show the browser window instead of running headless
+}
+
+// CoordinateSpace describes how coordinates output by the model map onto the viewport
+type CoordinateSpace struct {
+	Width    int // Viewport and screenshot width in pixels
+	Height   int // Viewport and screenshot height in pixels
+	GridSize int // Coordinates from the model range from 0 to GridSize-1 on both axes
+}
+
+// ToPixels converts normalized model coordinates to viewport pixels
+func (cs CoordinateSpace) ToPixels(x, y int) (int, int) {
+	return x * cs.Width / cs.GridSize, y * cs.Height / cs.GridSize
+}
+
+// BrowserSession is the browser the loop and the built-in tools act on, with coordinates on the 0-999 grid.
+// *computeruse.Session implements it, see NewBrowserSession.
+type BrowserSession interface {
+	GetURL() (string, error)
+	Screenshot() ([]byte, error) // PNG of the viewport
+	Close() error
+
+	Navigate(url string) error
+	GoBack() error
+	GoForward() error
+	Search() error
+
+	ClickAt(x, y int) error
+	HoverAt(x, y int) error
+	TypeTextAt(x, y int, text string, clearBefore, pressEnter bool) error
+	Key(keys ...string) error
+	Scroll(direction string, amount int) error
+	ScrollAt(x, y int, direction string, magnitude int) error
+	ClickDrag(fromX, fromY, toX, toY int) error
+	MouseDown(x, y int) error
+	MouseUp(x, y int) error
+	MouseMove(x, y int) error
+}
+
+var _ BrowserSession = (*computeruse.Session)(nil)
+
+// NewBrowserSession creates a computer-use session with settings the loop relies on:
+// normalized coordinates, and a viewport matching the screenshots the model sees.
+func NewBrowserSession(ctx context.Context, config BrowserConfig) (*computeruse.Session, CoordinateSpace, error) {
+	// Apply defaults
+	if config.Width == 0 && config.Height == 0 {
+		config.Width = 1440
+		config.Height = 900
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, CoordinateSpace{}, fmt.Errorf("invalid viewport size %dx%d: width and height must both be positive", config.Width, config.Height)
+	}
+
+	session, err := computeruse.NewSession(ctx, computeruse.SessionConfig{
+		ScreenWidth:          config.Width,
+		ScreenHeight:         config.Height,
+		NormalizeCoordinates: true,
+		InitialURL:           config.InitialURL,
+		SearchEngineURL:      config.SearchEngineURL,
+		Headless:             !config.Headful,
+	})
+	if err != nil {
+		return nil, CoordinateSpace{}, fmt.Errorf("failed to create browser session: %w", err)
+	}
+
+	return session, CoordinateSpace{
+		Width:    config.Width,
+		Height:   config.Height,
+		GridSize: normalizedGridSize,
+	}, nil
+}
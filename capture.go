@@ -0,0 +1,120 @@
+package geminirod
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+
+	"google.golang.org/genai"
+)
+
+// captureToolName is the name of the region capture tool offered to the model
+const captureToolName = "capture_element_screenshot"
+
+// Region size limits on the normalized grid. Beyond half the viewport a capture adds little over the
+// regular screenshot while costing as much, so larger regions are refused.
+const (
+	minCaptureSize     = 20
+	maxCaptureSize     = 500
+	defaultCaptureSize = 250
+)
+
+// captureDeclaration declares the region capture tool to the model
+var captureDeclaration = &genai.FunctionDeclaration{
+	Name: captureToolName,
+	Description: "Captures a region of the page at full native resolution, for reading small text. " +
+		"The image replaces the usual viewport screenshot for this call; the response includes the region's offset.",
+	Parameters: objectSchema(withCoordinates(map[string]*genai.Schema{
+		"width":  {Type: genai.TypeInteger, Description: "Region width on the 0-999 grid, from 20 to 500. Default: 250."},
+		"height": {Type: genai.TypeInteger, Description: "Region height on the 0-999 grid, from 20 to 500. Default: 250."},
+	}), "x", "y"),
+}
+
+// handleCaptureElementScreenshot captures the region centered on x/y and returns it as the function response image
+func handleCaptureElementScreenshot(session BrowserSession, args map[string]any) (*genai.Part, error) {
+	x, y, err := extractCoordinates(args)
+	if err != nil {
+		return nil, err
+	}
+	width, err := extractCaptureSize(args, "width")
+	if err != nil {
+		return nil, err
+	}
+	height, err := extractCaptureSize(args, "height")
+	if err != nil {
+		return nil, err
+	}
+
+	// Center the region on x/y, shifted to stay on the grid
+	left := min(max(x-width/2, 0), normalizedGridSize-width)
+	top := min(max(y-height/2, 0), normalizedGridSize-height)
+
+	screenshot, err := session.Screenshot()
+	if err != nil {
+		return nil, wrapToolError(ToolErrorSessionDead, "failed to take screenshot", err)
+	}
+	img, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+
+	// Crop the native screenshot, the viewport and the screenshot share the same pixel size
+	bounds := img.Bounds()
+	rect := image.Rect(
+		bounds.Min.X+left*bounds.Dx()/normalizedGridSize,
+		bounds.Min.Y+top*bounds.Dy()/normalizedGridSize,
+		bounds.Min.X+(left+width)*bounds.Dx()/normalizedGridSize,
+		bounds.Min.Y+(top+height)*bounds.Dy()/normalizedGridSize,
+	)
+	cropper, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, fmt.Errorf("unsupported screenshot image type %T", img)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, cropper.SubImage(rect)); err != nil {
+		return nil, fmt.Errorf("failed to encode region: %w", err)
+	}
+
+	url, err := session.GetURL()
+	if err != nil {
+		return nil, err
+	}
+
+	return genai.NewPartFromFunctionResponseWithParts(captureToolName, map[string]any{
+		"url": url,
+		"region": map[string]any{
+			"x":      left,
+			"y":      top,
+			"width":  width,
+			"height": height,
+		},
+	}, []*genai.FunctionResponsePart{
+		genai.NewFunctionResponsePartFromBytes(buf.Bytes(), "image/png"),
+	}), nil
+}
+
+// extractCaptureSize parses an optional region dimension and checks it is within limits
+func extractCaptureSize(args map[string]any, name string) (int, error) {
+	val, exists := args[name]
+	if !exists {
+		return defaultCaptureSize, nil
+	}
+
+	var size int
+	switch v := val.(type) {
+	case float64:
+		size = int(v)
+	case int:
+		size = v
+	default:
+		return 0, newToolError(ToolErrorInvalidArgument, "%s argument must be a number", name)
+	}
+	if size < minCaptureSize || size > maxCaptureSize {
+		return 0, newToolError(ToolErrorOutOfBounds, "%s must be between %d and %d", name, minCaptureSize, maxCaptureSize)
+	}
+	return size, nil
+}
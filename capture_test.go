@@ -0,0 +1,184 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestElementCaptureDisabledByDefault(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call(captureToolName, map[string]any{"x": 500.0, "y": 500.0}))},
+	)
+	config, _ := testConfig(t, backend)
+
+	var needsAction bool
+	events := collectEvents(t, context.Background(), config, func(fc *FunctionCall) {
+		needsAction = fc.FunctionName == captureToolName
+		fc.Respond(map[string]any{"handled": "by the subscriber"})
+	})
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if slices.Contains(backend.calls()[0].declaredFunctions(), captureToolName) {
+		t.Error("capture tool declared without EnableElementCapture")
+	}
+	if !needsAction {
+		t.Error("capture call not left to the subscriber")
+	}
+}
+
+func TestElementCapture(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call(captureToolName, map[string]any{"x": 10.0, "y": 10.0, "width": 100.0}))},
+	)
+	config, _ := testConfig(t, backend)
+	config.EnableElementCapture = true
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	calls := backend.calls()
+	if !slices.Contains(calls[0].declaredFunctions(), captureToolName) {
+		t.Errorf("declared %v, want the capture tool", calls[0].declaredFunctions())
+	}
+	history := calls[1].Contents
+	response := functionResponses(history[len(history)-1])[captureToolName]
+	if response == nil {
+		t.Fatal("capture response missing")
+	}
+	region, _ := response.Response["region"].(map[string]any)
+	// Kept on the grid: centered on 10 it would start at -40
+	if region["x"] != 0.0 || region["width"] != 100.0 || region["height"] != float64(defaultCaptureSize) {
+		t.Errorf("region = %v, want a 100x%d region at the left edge", region, defaultCaptureSize)
+	}
+	if len(response.Parts) != 1 || response.Parts[0].InlineData == nil {
+		t.Error("capture response has no image")
+	}
+}
+
+func TestExtractCaptureSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]any
+		want     int
+		wantCode string
+	}{
+		{name: "default", args: map[string]any{}, want: defaultCaptureSize},
+		{name: "float", args: map[string]any{"width": 120.0}, want: 120},
+		{name: "int", args: map[string]any{"width": 120}, want: 120},
+		{name: "smallest", args: map[string]any{"width": float64(minCaptureSize)}, want: minCaptureSize},
+		{name: "largest", args: map[string]any{"width": float64(maxCaptureSize)}, want: maxCaptureSize},
+		{name: "too small", args: map[string]any{"width": 5.0}, wantCode: ToolErrorOutOfBounds},
+		{name: "too large", args: map[string]any{"width": 999.0}, wantCode: ToolErrorOutOfBounds},
+		{name: "not a number", args: map[string]any{"width": "wide"}, wantCode: ToolErrorInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := extractCaptureSize(tt.args, "width")
+			if tt.wantCode != "" {
+				var toolErr *ToolError
+				if !errors.As(err, &toolErr) || toolErr.Code != tt.wantCode {
+					t.Fatalf("error = %v, want a %s ToolError", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil || size != tt.want {
+				t.Errorf("size = %d, %v, want %d", size, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopToolFailuresReported(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     string
+		args     map[string]any
+		wantCode string
+	}{
+		{name: "capture", call: captureToolName, args: map[string]any{"x": 10.0, "y": 10.0, "width": 5000.0}, wantCode: ToolErrorOutOfBounds},
+		{name: "finding", call: findingToolName, args: map[string]any{"value": "42"}, wantCode: ToolErrorInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []ToolErrorMode{ToolErrorModeAbort, ToolErrorModeReport} {
+				backend := newFakeBackend(t,
+					fakeReply{Response: callResponse(call(tt.call, tt.args))},
+				)
+				config, _ := testConfig(t, backend)
+				config.EnableElementCapture = true
+				config.EnableFindings = true
+				config.ToolErrorMode = mode
+				config.MaxConsecutiveToolErrors = 3
+
+				events := collectEvents(t, context.Background(), config, nil)
+				if mode == ToolErrorModeAbort {
+					if finalError(events) == nil {
+						t.Error("abort mode: run succeeded, want the failure to end it")
+					}
+					continue
+				}
+				if err := finalError(events); err != nil {
+					t.Fatalf("report mode: run failed: %v", err)
+				}
+				calls := backend.calls()
+				if len(calls) != 2 {
+					t.Fatalf("report mode: got %d model calls, want 2", len(calls))
+				}
+				history := calls[1].Contents
+				response := functionResponses(history[len(history)-1])[tt.call]
+				if response == nil || response.Response["code"] != tt.wantCode {
+					t.Errorf("report mode: response = %+v, want code %s", response, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareWrapsLoopTools(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(
+			call(captureToolName, map[string]any{"x": 500.0, "y": 500.0}),
+			call(findingToolName, map[string]any{"key": "price", "value": "42"}),
+		)},
+	)
+	config, _ := testConfig(t, backend)
+	config.EnableElementCapture = true
+	config.EnableFindings = true
+	var seen []string
+	config.ToolMiddleware = []ToolMiddleware{func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			name, _ := ToolNameFromContext(ctx)
+			seen = append(seen, name)
+			result, err := next(ctx, session, args)
+			if err == nil {
+				result["audited"] = true
+			}
+			return result, err
+		}
+	}}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if !slices.Equal(seen, []string{captureToolName, findingToolName}) {
+		t.Errorf("middleware saw %v, want the capture and finding calls", seen)
+	}
+	if got := len(eventsOf[FindingEvent](events)); got != 1 {
+		t.Errorf("got %d FindingEvents, want 1", got)
+	}
+	history := backend.calls()[1].Contents
+	responses := functionResponses(history[len(history)-1])
+	for _, name := range []string{captureToolName, findingToolName} {
+		if responses[name] == nil || responses[name].Response["audited"] != true {
+			t.Errorf("%s response = %+v, want the middleware's field", name, responses[name])
+		}
+	}
+	if len(responses[captureToolName].Parts) != 1 {
+		t.Error("capture image lost through the middleware")
+	}
+}
@@ -0,0 +1,40 @@
+package geminirod
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the loop and the built-in tools the time and how to wait.
+// Replace it to run deadlines, backoff and waits on simulated time.
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d, returning early with the context error if ctx is done first
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// systemClock is the real time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// clockOrSystem returns clock, or the system clock if it is nil
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return systemClock{}
+	}
+	return clock
+}
@@ -0,0 +1,118 @@
+package geminirod
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// DefaultCompactionModel summarizes old turns during an emergency compaction
+const DefaultCompactionModel = "gemini-2.5-flash"
+
+// ErrContextTooLarge is reported when the conversation exceeds the model's input limit, even after compaction.
+// The CompletionEvent carries the compacted history, so the run can be continued with InitialHistory.
+var ErrContextTooLarge = errors.New("conversation exceeds the model's input limit")
+
+// Size of a function response kept in the transcript given to the summarization model
+const maxTranscriptResponseLength = 500
+
+// compactionPrompt asks the summarization model for a summary of the transcript that follows it
+const compactionPrompt = "Below is the start of a conversation between a user and a browser automation agent. " +
+	"Summarize it for the agent, who will continue the task without it: keep the task, the pages visited, " +
+	"what was tried, what worked or failed and any values found. Answer with the summary only.\n\n"
+
+// isInputTooLargeError reports whether err is the API rejecting a request for exceeding the model's input limit
+func isInputTooLargeError(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusBadRequest {
+		return false
+	}
+	message := strings.ToLower(apiErr.Message)
+	return strings.Contains(message, "exceeds the maximum number of tokens") ||
+		strings.Contains(message, "input token count") ||
+		strings.Contains(message, "payload size exceeds")
+}
+
+// compactHistory shrinks history as a last resort: it drops the screenshots after keep but the latest ones, then
+// replaces the oldest fraction of the contents after keep with a summary written by model. The first keep contents,
+// the earlier conversation, the prompt and the demonstrations, are left as they are, images included. It returns the
+// compacted history and a description of what was done; a failed summary leaves the pruned history and is described
+// rather than returned as an error.
+func compactHistory(ctx context.Context, client *genai.Client, model string, fraction float64, history []*genai.Content, keep int) ([]*genai.Content, string) {
+	pruned := pruneOldScreenshots(history[keep:], 1, -1)
+	description := fmt.Sprintf("removed %d screenshots (%d bytes)", pruned.ScreenshotsRemoved, pruned.BytesFreed)
+
+	// Cut before a model turn, so function calls stay next to their responses
+	cut := compactionCut(history, keep, fraction)
+	if cut < 0 {
+		return history, description + ", nothing to summarize"
+	}
+
+	resp, err := client.Models.GenerateContent(ctx, model, genai.Text(compactionPrompt+renderTranscript(history[keep:cut])), nil)
+	if err != nil {
+		return history, description + fmt.Sprintf(", summarizing with %s failed: %v", model, err)
+	}
+	summary := resp.Text()
+	if summary == "" {
+		return history, description + fmt.Sprintf(", %s returned an empty summary", model)
+	}
+
+	compacted := slices.Clone(history[:keep])
+	compacted = append(compacted, &genai.Content{
+		Role: genai.RoleUser,
+		Parts: []*genai.Part{
+			{Text: "Summary of the earlier part of this conversation, compacted to fit the input limit:\n" + summary},
+		},
+	})
+	compacted = append(compacted, history[cut:]...)
+	return compacted, description + fmt.Sprintf(", summarized %d contents with %s", cut-keep, model)
+}
+
+// compactionCut returns the index of the model content the summarized part ends before, -1 if there is none.
+// It is the first model content at or after the fraction of the contents after keep, or the last one before it.
+func compactionCut(history []*genai.Content, keep int, fraction float64) int {
+	target := keep + int(float64(len(history)-keep)*fraction)
+	last := -1
+	for i := keep + 1; i < len(history); i++ {
+		if history[i].Role != genai.RoleModel {
+			continue
+		}
+		if i >= target {
+			return i
+		}
+		last = i
+	}
+	return last
+}
+
+// renderTranscript writes contents as plain text for the summarization model, leaving out images
+func renderTranscript(contents []*genai.Content) string {
+	var sb strings.Builder
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			switch {
+			case part.Thought:
+				continue
+			case part.Text != "":
+				fmt.Fprintf(&sb, "%s: %s\n", content.Role, part.Text)
+			case part.FunctionCall != nil:
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				fmt.Fprintf(&sb, "%s called %s %s\n", content.Role, part.FunctionCall.Name, args)
+			case part.FunctionResponse != nil:
+				response, _ := json.Marshal(part.FunctionResponse.Response)
+				text := string(response)
+				if len(text) > maxTranscriptResponseLength {
+					text = text[:maxTranscriptResponseLength] + "..."
+				}
+				fmt.Fprintf(&sb, "%s response: %s\n", part.FunctionResponse.Name, text)
+			}
+		}
+	}
+	return sb.String()
+}
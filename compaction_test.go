@@ -0,0 +1,176 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// tooLarge is the API's answer to a request exceeding the input limit
+var tooLarge = fakeReply{Status: http.StatusBadRequest, Message: "The input token count (1200000) exceeds the maximum number of tokens allowed (1048576)."}
+
+// compactionScript makes three turns of clicks, then fails the fourth model call for its size
+func compactionScript(after ...fakeReply) []fakeReply {
+	replies := []fakeReply{
+		{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+		{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+		{Response: callResponse(call("click_at", map[string]any{"x": 30.0, "y": 30.0}))},
+		tooLarge,
+	}
+	return append(replies, after...)
+}
+
+func TestEmergencyCompaction(t *testing.T) {
+	backend := newFakeBackend(t, compactionScript(fakeReply{Response: textResponse("the agent clicked twice")})...)
+	config, _ := testConfig(t, backend)
+	config.CompactionModel = "summarizer"
+	demo := NewDemonstration([]DemonstrationStep{{Action: "click_at", Observation: "the menu opened"}})
+	config.Demonstrations = []*genai.Content{demo}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	warnings := eventsOf[WarningEvent](events)
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "compacted") {
+		t.Errorf("warnings = %+v, want the compaction described", warnings)
+	}
+
+	calls := backend.calls()
+	if len(calls) != 6 {
+		t.Fatalf("got %d model calls, want 3 turns, the failed call, the summary and the retry", len(calls))
+	}
+	summary := calls[4]
+	if summary.Model != "summarizer" {
+		t.Errorf("summary written by %s, want the compaction model", summary.Model)
+	}
+	if hasText(summary.Contents, "the menu opened") || !hasText(summary.Contents, "click_at") {
+		t.Error("summarized transcript should hold the run's turns and not the demonstration")
+	}
+
+	// The prompt and the demonstration are kept, the first two turns summarized, the last one kept
+	retry := calls[5].Contents
+	if len(retry) != 5 {
+		t.Fatalf("retry sent %d contents, want the prompt, the demonstration, the summary and the last turn", len(retry))
+	}
+	if retry[0].Parts[0].Text != config.Prompt {
+		t.Errorf("first content = %q, want the prompt", retry[0].Parts[0].Text)
+	}
+	if !hasText(retry[1:2], "the menu opened") {
+		t.Error("demonstration not kept after the prompt")
+	}
+	if !hasText(retry[2:3], "Summary of the earlier part") || !hasText(retry[2:3], "the agent clicked twice") {
+		t.Errorf("third content = %+v, want the summary", retry[2])
+	}
+	if call := retry[3].Parts[0].FunctionCall; call == nil || call.Args["x"] != 30.0 {
+		t.Errorf("fourth content = %+v, want the last click kept", retry[3])
+	}
+}
+
+func TestEmergencyCompactionStillTooLarge(t *testing.T) {
+	backend := newFakeBackend(t, compactionScript(fakeReply{Response: textResponse("summary")}, tooLarge)...)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); !errors.Is(err, ErrContextTooLarge) {
+		t.Fatalf("error = %v, want ErrContextTooLarge", err)
+	}
+	completions := eventsOf[CompletionEvent](events)
+	if len(completions) != 1 || !hasText(completions[0].History, "Summary of the earlier part") {
+		t.Error("CompletionEvent should carry the compacted history to resume from")
+	}
+	if got := len(backend.calls()); got != 6 {
+		t.Errorf("got %d model calls, want a single retry", got)
+	}
+}
+
+func TestEmergencyCompactionDisabled(t *testing.T) {
+	backend := newFakeBackend(t, compactionScript()...)
+	config, _ := testConfig(t, backend)
+	config.DisableEmergencyCompaction = true
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); !errors.Is(err, ErrContextTooLarge) {
+		t.Fatalf("error = %v, want ErrContextTooLarge", err)
+	}
+	if got := len(backend.calls()); got != 4 {
+		t.Errorf("got %d model calls, want no summary nor retry", got)
+	}
+}
+
+func TestEmergencyCompactionSummaryFails(t *testing.T) {
+	backend := newFakeBackend(t, compactionScript(
+		fakeReply{Status: http.StatusInternalServerError, Message: "summarizer down"},
+		fakeReply{Response: textResponse("finished")},
+	)...)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	warnings := eventsOf[WarningEvent](events)
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "failed") {
+		t.Errorf("warnings = %+v, want the failed summary described", warnings)
+	}
+	// Only the screenshots were dropped, the turns are all kept
+	if retry := backend.calls()[5].Contents; len(retry) != 7 {
+		t.Errorf("retry sent %d contents, want the whole pruned history", len(retry))
+	}
+}
+
+func TestCompactionCut(t *testing.T) {
+	user := &genai.Content{Role: genai.RoleUser}
+	model := &genai.Content{Role: genai.RoleModel}
+	// prompt, demonstration, then turns of a model call and its response
+	history := []*genai.Content{user, user, model, user, model, user, model, user}
+	tests := []struct {
+		keep     int
+		fraction float64
+		want     int
+	}{
+		{keep: 2, fraction: 0.5, want: 6},
+		{keep: 2, fraction: 0.1, want: 4}, // At least one turn is summarized
+		{keep: 2, fraction: 1, want: 6},   // The latest turn is always kept
+		{keep: 6, fraction: 0.5, want: -1},
+	}
+	for _, tt := range tests {
+		if got := compactionCut(history, tt.keep, tt.fraction); got != tt.want {
+			t.Errorf("compactionCut(keep %d, fraction %v) = %d, want %d", tt.keep, tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestEmergencyCompactionKeepsPromptImages(t *testing.T) {
+	backend := newFakeBackend(t, compactionScript(fakeReply{Response: textResponse("summary")})...)
+	config, _ := testConfig(t, backend)
+	config.SkipInitialScreenshot = false
+	config.MaxRecentScreenshots = -1
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// The prompt keeps its screenshot, of the turns after it only the latest one does
+	retry := backend.calls()[5].Contents
+	if !slices.ContainsFunc(retry[0].Parts, isInlineImage) {
+		t.Error("screenshot of the prompt dropped by the compaction")
+	}
+	screenshots := 0
+	for _, content := range retry[1:] {
+		for _, part := range content.Parts {
+			if hasScreenshotParts(part) {
+				screenshots++
+			}
+		}
+	}
+	if screenshots != 1 {
+		t.Errorf("retry holds %d screenshots after the prompt, want the latest one", screenshots)
+	}
+}
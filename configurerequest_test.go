@@ -0,0 +1,97 @@
+package geminirod
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestConfigureRequestPersistsAcrossTurns(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+	)
+	config, _ := testConfig(t, backend)
+	hookCalls := 0
+	config.ConfigureRequest = func(c *genai.GenerateContentConfig) {
+		hookCalls++
+		c.SafetySettings = []*genai.SafetySetting{{Category: genai.HarmCategoryHarassment, Threshold: genai.HarmBlockThresholdBlockOnlyHigh}}
+		c.HTTPOptions = &genai.HTTPOptions{Headers: http.Header{"X-Proxy-Route": []string{"agents"}}}
+	}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if hookCalls != 1 {
+		t.Errorf("ConfigureRequest called %d times, want once per run", hookCalls)
+	}
+
+	calls := backend.calls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d model calls, want 3", len(calls))
+	}
+	for i, request := range calls {
+		if len(request.SafetySettings) != 1 || request.SafetySettings[0].Category != genai.HarmCategoryHarassment {
+			t.Errorf("call %d: safety settings = %+v, want the hook's", i, request.SafetySettings)
+		}
+		if got := request.Header.Get("X-Proxy-Route"); got != "agents" {
+			t.Errorf("call %d: X-Proxy-Route = %q, want the hook's header", i, got)
+		}
+		if !hasTool(request.Tools, "computerUse") {
+			t.Errorf("call %d: computer use tool missing", i)
+		}
+	}
+}
+
+func TestConfigureRequestRemovingBrowserTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   bool
+		wantErr bool
+	}{
+		{name: "refused", wantErr: true},
+		{name: "allowed", allow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t)
+			config, _ := testConfig(t, backend)
+			config.ConfigureRequest = func(c *genai.GenerateContentConfig) {
+				c.Tools = nil
+			}
+			config.AllowRemovingBrowserTool = tt.allow
+
+			events := collectEvents(t, context.Background(), config, nil)
+			err := finalError(events)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "removed the computer use tool") {
+					t.Fatalf("error = %v, want the removal refused", err)
+				}
+				if calls := len(backend.calls()); calls != 0 {
+					t.Errorf("got %d model calls, want none", calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if calls := backend.calls(); len(calls) != 1 || hasTool(calls[0].Tools, "computerUse") {
+				t.Errorf("requests = %+v, want one without the computer use tool", calls)
+			}
+		})
+	}
+}
+
+// hasTool reports whether a request declares a tool of the kind, e.g. "computerUse"
+func hasTool(tools []map[string]any, kind string) bool {
+	for _, tool := range tools {
+		if _, ok := tool[kind]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package geminirod
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunDeadlineStopsOnTurnBoundary(t *testing.T) {
+	clock := newFakeClock()
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("open_web_browser", nil)), Handle: func() { clock.advance(time.Minute) }},
+	)
+	config, _ := testConfig(t, backend)
+	config.Clock = clock
+	config.RunDeadline = clock.Now().Add(30 * time.Second)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	deadlines := eventsOf[DeadlineEvent](events)
+	if len(deadlines) != 1 {
+		t.Fatalf("got %d DeadlineEvents, want 1", len(deadlines))
+	}
+	if deadlines[0].Turns != 1 {
+		t.Errorf("Turns = %d, want 1", deadlines[0].Turns)
+	}
+	// A minute in the model call, then the second the tool waits for rendering
+	if want := time.Minute + time.Second; deadlines[0].Elapsed != want {
+		t.Errorf("Elapsed = %v, want %v", deadlines[0].Elapsed, want)
+	}
+	if got := len(backend.calls()); got != 1 {
+		t.Errorf("got %d model calls, want 1", got)
+	}
+}
+
+func TestRunDeadlineWrapUp(t *testing.T) {
+	clock := newFakeClock()
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("open_web_browser", nil)), Handle: func() { clock.advance(40 * time.Second) }},
+		fakeReply{Response: callResponse(call("open_web_browser", nil))},
+		fakeReply{Response: callResponse(call("open_web_browser", nil))},
+	)
+	config, _ := testConfig(t, backend)
+	config.Clock = clock
+	config.RunDeadline = clock.Now().Add(time.Minute)
+	config.WrapUpMargin = 30 * time.Second
+	config.WrapUpMessage = "wrap it up"
+	config.WrapUpTurns = 1
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	calls := backend.calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d model calls, want the first and one wrap-up turn", len(calls))
+	}
+	if hasText(calls[0].Contents, "wrap it up") {
+		t.Error("first request already asked to wrap up")
+	}
+	if !hasText(calls[1].Contents[len(calls[1].Contents)-1:], "wrap it up") {
+		t.Error("wrap-up message missing from the latest user turn of the second request")
+	}
+	deadlines := eventsOf[DeadlineEvent](events)
+	if len(deadlines) != 1 || deadlines[0].Turns != 2 {
+		t.Errorf("DeadlineEvents = %+v, want one after 2 turns", deadlines)
+	}
+}
+
+func TestMaxRunDuration(t *testing.T) {
+	clock := newFakeClock()
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("open_web_browser", nil)), Handle: func() { clock.advance(10 * time.Second) }},
+	)
+	config, _ := testConfig(t, backend)
+	config.Clock = clock
+	config.MaxRunDuration = 5 * time.Second
+	config.RunDeadline = clock.Now().Add(time.Hour) // The earlier limit applies
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if got := len(eventsOf[DeadlineEvent](events)); got != 1 {
+		t.Fatalf("got %d DeadlineEvents, want 1", got)
+	}
+	if got := len(backend.calls()); got != 1 {
+		t.Errorf("got %d model calls, want 1", got)
+	}
+}
+
+func TestNoDeadlineRunsToCompletion(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("open_web_browser", nil))},
+	)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if got := len(eventsOf[DeadlineEvent](events)); got != 0 {
+		t.Errorf("got %d DeadlineEvents, want none", got)
+	}
+	if got := len(backend.calls()); got != 2 {
+		t.Errorf("got %d model calls, want 2", got)
+	}
+}
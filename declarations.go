@@ -0,0 +1,173 @@
+package geminirod
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+)
+
+// builtInToolDeclarations describes the built-in tools as plain function declarations.
+// Names and arguments must match what the handlers in tools.go accept.
+var builtInToolDeclarations = map[string]*genai.FunctionDeclaration{
+	"open_web_browser": {
+		Name:        "open_web_browser",
+		Description: "Opens the web browser.",
+	},
+	"wait_5_seconds": {
+		Name:        "wait_5_seconds",
+		Description: "Waits for 5 seconds, or the given duration, to allow unfinished webpage processes to complete.",
+		Parameters: objectSchema(map[string]*genai.Schema{
+			"duration_seconds": {Type: genai.TypeNumber, Description: "How long to wait, in seconds. Capped by the loop. Default: 5."},
+		}),
+	},
+	"go_back": {
+		Name:        "go_back",
+		Description: "Navigates back to the previous webpage in the browser history.",
+	},
+	"go_forward": {
+		Name:        "go_forward",
+		Description: "Navigates forward to the next webpage in the browser history.",
+	},
+	"search": {
+		Name:        "search",
+		Description: "Directly jumps to a search engine home page. Used when you need to start with a search.",
+	},
+	"navigate": {
+		Name:        "navigate",
+		Description: "Navigates directly to a specified URL.",
+		Parameters: objectSchema(map[string]*genai.Schema{
+			"url": {Type: genai.TypeString, Description: "The URL to navigate to."},
+		}, "url"),
+	},
+	"click_at": {
+		Name:        "click_at",
+		Description: "Clicks at a specific coordinate on the webpage.",
+		Parameters:  objectSchema(coordinateProperties(), "x", "y"),
+	},
+	"hover_at": {
+		Name:        "hover_at",
+		Description: "Hovers the mouse at a specific coordinate on the webpage. Useful for revealing sub-menus.",
+		Parameters:  objectSchema(coordinateProperties(), "x", "y"),
+	},
+	"type_text_at": {
+		Name:        "type_text_at",
+		Description: "Types text at a specific coordinate. By default clears the field first and presses ENTER after typing.",
+		Parameters: objectSchema(withCoordinates(map[string]*genai.Schema{
+			"text":                {Type: genai.TypeString, Description: "The text to type."},
+			"press_enter":         {Type: genai.TypeBoolean, Description: "Whether to press ENTER after typing. Default: true."},
+			"clear_before_typing": {Type: genai.TypeBoolean, Description: "Whether to clear the field before typing. Default: true."},
+		}), "x", "y", "text"),
+	},
+	"key_combination": {
+		Name:        "key_combination",
+		Description: "Presses keyboard keys and combinations, such as \"Control+C\" or \"Enter\".",
+		Parameters: objectSchema(map[string]*genai.Schema{
+			"keys": {Type: genai.TypeString, Description: "The keys to press, joined by \"+\"."},
+		}, "keys"),
+	},
+	"scroll_document": {
+		Name:        "scroll_document",
+		Description: "Scrolls the entire webpage in the given direction.",
+		Parameters: objectSchema(map[string]*genai.Schema{
+			"direction": directionSchema(),
+			"magnitude": {Type: genai.TypeInteger, Description: "Optional scroll distance on the 0-999 grid."},
+			"fraction":  {Type: genai.TypeNumber, Description: "Optional scroll distance as a fraction of the viewport, e.g. 0.5 for half a screen. Instead of magnitude."},
+		}, "direction"),
+	},
+	"scroll_at": {
+		Name:        "scroll_at",
+		Description: "Scrolls the element at a specific coordinate in the given direction by a magnitude.",
+		Parameters: objectSchema(withCoordinates(map[string]*genai.Schema{
+			"direction": directionSchema(),
+			"magnitude": {Type: genai.TypeInteger, Description: "The scroll distance on the 0-999 grid. Default: 800."},
+			"fraction":  {Type: genai.TypeNumber, Description: "Optional scroll distance as a fraction of the viewport, e.g. 0.5 for half a screen. Instead of magnitude."},
+		}), "x", "y", "direction"),
+	},
+	"drag_and_drop": {
+		Name:        "drag_and_drop",
+		Description: "Drags from a starting coordinate and drops at a destination coordinate.",
+		Parameters: objectSchema(withCoordinates(map[string]*genai.Schema{
+			"destination_x": {Type: genai.TypeInteger, Description: "The destination x coordinate on the 0-999 grid."},
+			"destination_y": {Type: genai.TypeInteger, Description: "The destination y coordinate on the 0-999 grid."},
+			"waypoints": {
+				Type:        genai.TypeArray,
+				Description: "Optional points the pointer passes through on its way to the destination, e.g. each row of a sortable list.",
+				Items:       objectSchema(coordinateProperties(), "x", "y"),
+			},
+			"steps": {Type: genai.TypeInteger, Description: "Optional number of pointer moves per path segment. Default: 1."},
+		}), "x", "y", "destination_x", "destination_y"),
+	},
+}
+
+// sensitiveArgs lists built-in tool arguments that may contain passwords or personal data
+var sensitiveArgs = map[string][]string{
+	"type_text_at": {"text"},
+}
+
+// maskSensitiveArgs returns a copy of args with sensitive values replaced by a mask of the same length.
+// args itself is returned when the tool has no sensitive arguments.
+func maskSensitiveArgs(name string, args map[string]any) map[string]any {
+	fields, ok := sensitiveArgs[name]
+	if !ok {
+		return args
+	}
+
+	masked := maps.Clone(args)
+	for _, field := range fields {
+		if value, ok := masked[field].(string); ok {
+			masked[field] = strings.Repeat("*", utf8.RuneCountInString(value))
+		}
+	}
+	return masked
+}
+
+// BuiltInToolDeclarations returns function declarations for all built-in tools, sorted by name.
+// Use them to let a model without native computer use drive the browser.
+func BuiltInToolDeclarations() []*genai.FunctionDeclaration {
+	names := make([]string, 0, len(builtInToolDeclarations))
+	for name := range builtInToolDeclarations {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	declarations := make([]*genai.FunctionDeclaration, 0, len(names))
+	for _, name := range names {
+		declarations = append(declarations, builtInToolDeclarations[name])
+	}
+	return declarations
+}
+
+// Schema helpers
+
+func objectSchema(properties map[string]*genai.Schema, required ...string) *genai.Schema {
+	return &genai.Schema{
+		Type:       genai.TypeObject,
+		Properties: properties,
+		Required:   required,
+	}
+}
+
+func coordinateProperties() map[string]*genai.Schema {
+	return map[string]*genai.Schema{
+		"x": {Type: genai.TypeInteger, Description: "The x coordinate on the 0-999 grid."},
+		"y": {Type: genai.TypeInteger, Description: "The y coordinate on the 0-999 grid."},
+	}
+}
+
+func withCoordinates(properties map[string]*genai.Schema) map[string]*genai.Schema {
+	for name, schema := range coordinateProperties() {
+		properties[name] = schema
+	}
+	return properties
+}
+
+func directionSchema() *genai.Schema {
+	return &genai.Schema{
+		Type:        genai.TypeString,
+		Description: "The direction to scroll.",
+		Enum:        []string{"up", "down", "left", "right"},
+	}
+}
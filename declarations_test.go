@@ -0,0 +1,76 @@
+package geminirod
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMaskSensitiveArgs(t *testing.T) {
+	args := map[string]any{"x": 10.0, "y": 20.0, "text": "pässwörd"}
+	masked := maskSensitiveArgs("type_text_at", args)
+	if masked["text"] != "********" {
+		t.Errorf("text = %v, want a mask of one * per character", masked["text"])
+	}
+	if masked["x"] != 10.0 || masked["y"] != 20.0 {
+		t.Errorf("masked = %v, want other arguments unchanged", masked)
+	}
+	if args["text"] != "pässwörd" {
+		t.Error("masking modified the original args")
+	}
+
+	other := map[string]any{"url": "https://example.com"}
+	if got := maskSensitiveArgs("navigate", other); got["url"] != "https://example.com" {
+		t.Errorf("navigate args = %v, want them unmasked", got)
+	}
+}
+
+func TestSensitiveArgsMaskedInEvents(t *testing.T) {
+	for _, logSensitive := range []bool{false, true} {
+		backend := newFakeBackend(t,
+			fakeReply{Response: callResponse(call("type_text_at", map[string]any{
+				"x": 10.0, "y": 10.0, "text": "secret", "press_enter": false, "clear_before_typing": false,
+			}))},
+		)
+		config, browser := testConfig(t, backend)
+		config.EmitScreenshots = true
+		config.LogSensitiveArgs = logSensitive
+
+		events := collectEvents(t, context.Background(), config, nil)
+		if err := finalError(events); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+
+		want := "******"
+		if logSensitive {
+			want = "secret"
+		}
+		progress := eventsOf[ProgressEvent](events)
+		if len(progress) == 0 || len(progress[0].FunctionCalls) != 1 {
+			t.Fatalf("progress events = %+v, want the type_text_at call first", progress)
+		}
+		if got := progress[0].FunctionCalls[0].Args["text"]; got != want {
+			t.Errorf("LogSensitiveArgs=%v: ProgressEvent text = %v, want %v", logSensitive, got, want)
+		}
+		screenshots := eventsOf[ScreenshotEvent](events)
+		if len(screenshots) != 1 || screenshots[0].Args["text"] != want {
+			t.Errorf("LogSensitiveArgs=%v: ScreenshotEvents = %d, want one with text %v", logSensitive, len(screenshots), want)
+		}
+		results := eventsOf[ToolResultEvent](events)
+		if len(results) != 1 || results[0].Args["text"] != want {
+			t.Errorf("LogSensitiveArgs=%v: ToolResultEvents = %d, want one with text %v", logSensitive, len(results), want)
+		}
+
+		// The model always sees what it asked for
+		calls := backend.calls()
+		if len(calls) != 2 {
+			t.Fatalf("got %d model calls, want 2", len(calls))
+		}
+		modelTurn := calls[1].Contents[len(calls[1].Contents)-2]
+		if got := modelTurn.Parts[0].FunctionCall.Args["text"]; got != "secret" {
+			t.Errorf("history text = %v, want the real value", got)
+		}
+		if text := browser.typedText(); text != "secret" {
+			t.Errorf("typed %q, want the real value", text)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package geminirod
+
+import (
+	"encoding/json"
+	"maps"
+
+	"google.golang.org/genai"
+)
+
+// callDeduplication maps every call of a turn to its first identical occurrence
+type callDeduplication struct {
+	sources    []int  // Index into the unique calls for each original call
+	duplicates []bool // Whether each original call repeats an earlier one
+}
+
+// deduplicateFunctionCalls removes calls identical (name and args) to an earlier call in the same turn
+func deduplicateFunctionCalls(functionCalls []*genai.FunctionCall) ([]*genai.FunctionCall, callDeduplication) {
+	var unique []*genai.FunctionCall
+	dedup := callDeduplication{
+		sources:    make([]int, len(functionCalls)),
+		duplicates: make([]bool, len(functionCalls)),
+	}
+	seen := make(map[string]int)
+
+	for i, fc := range functionCalls {
+		// encoding/json sorts map keys, so equal args encode identically
+		args, err := json.Marshal(fc.Args)
+		if err != nil {
+			// Unencodable args are never considered duplicates
+			dedup.sources[i] = len(unique)
+			unique = append(unique, fc)
+			continue
+		}
+
+		key := fc.Name + "\x00" + string(args)
+		if idx, ok := seen[key]; ok {
+			dedup.sources[i] = idx
+			dedup.duplicates[i] = true
+			continue
+		}
+
+		seen[key] = len(unique)
+		dedup.sources[i] = len(unique)
+		unique = append(unique, fc)
+	}
+
+	return unique, dedup
+}
+
+// count returns the number of duplicate calls
+func (d callDeduplication) count() int {
+	n := 0
+	for _, duplicate := range d.duplicates {
+		if duplicate {
+			n++
+		}
+	}
+	return n
+}
+
+// expand builds one response per original call from the responses to the unique calls.
+// Duplicates are answered with the original response, flagged and without screenshots.
+func (d callDeduplication) expand(uniqueParts []*genai.Part) []*genai.Part {
+	parts := make([]*genai.Part, len(d.sources))
+	for i, source := range d.sources {
+		original := uniqueParts[source]
+		if !d.duplicates[i] {
+			parts[i] = original
+			continue
+		}
+
+		response := maps.Clone(original.FunctionResponse.Response)
+		if response == nil {
+			response = map[string]any{}
+		}
+		response["duplicate_of_previous_call"] = true
+		parts[i] = genai.NewPartFromFunctionResponse(original.FunctionResponse.Name, response)
+	}
+	return parts
+}
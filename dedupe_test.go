@@ -0,0 +1,88 @@
+package geminirod
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestDeduplicateFunctionCalls(t *testing.T) {
+	click := call("click_at", map[string]any{"x": 10.0, "y": 20.0})
+	// Same args in another order encode identically
+	sameClick := call("click_at", map[string]any{"y": 20.0, "x": 10.0})
+	otherClick := call("click_at", map[string]any{"x": 10.0, "y": 30.0})
+	hover := call("hover_at", map[string]any{"x": 10.0, "y": 20.0})
+
+	tests := []struct {
+		name           string
+		calls          []*genai.FunctionCall
+		wantUnique     int
+		wantDuplicates []bool
+	}{
+		{name: "no duplicates", calls: []*genai.FunctionCall{click, otherClick, hover}, wantUnique: 3, wantDuplicates: []bool{false, false, false}},
+		{name: "repeated call", calls: []*genai.FunctionCall{click, sameClick}, wantUnique: 1, wantDuplicates: []bool{false, true}},
+		{name: "repeat after another call", calls: []*genai.FunctionCall{click, hover, click}, wantUnique: 2, wantDuplicates: []bool{false, false, true}},
+		{name: "same args, other tool", calls: []*genai.FunctionCall{click, hover}, wantUnique: 2, wantDuplicates: []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unique, dedup := deduplicateFunctionCalls(tt.calls)
+			if len(unique) != tt.wantUnique {
+				t.Errorf("got %d unique calls, want %d", len(unique), tt.wantUnique)
+			}
+			if !slices.Equal(dedup.duplicates, tt.wantDuplicates) {
+				t.Errorf("duplicates = %v, want %v", dedup.duplicates, tt.wantDuplicates)
+			}
+		})
+	}
+}
+
+func TestDeduplicateIdenticalCallsInTurn(t *testing.T) {
+	click := map[string]any{"x": 10.0, "y": 20.0}
+	tests := []struct {
+		name        string
+		deduplicate bool
+		wantClicks  int
+	}{
+		{name: "disabled", wantClicks: 2},
+		{name: "enabled", deduplicate: true, wantClicks: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(call("click_at", click), call("click_at", click))})
+			config, browser := testConfig(t, backend)
+			config.DeduplicateIdenticalCallsInTurn = tt.deduplicate
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if clicks := len(browser.recorded("ClickAt")); clicks != tt.wantClicks {
+				t.Errorf("clicked %d times, want %d", clicks, tt.wantClicks)
+			}
+
+			// Every call of the batch is answered, the repeat flagged when it was skipped
+			history := backend.calls()[1].Contents
+			var responses []*genai.FunctionResponse
+			for _, part := range history[len(history)-1].Parts {
+				if part.FunctionResponse != nil {
+					responses = append(responses, part.FunctionResponse)
+				}
+			}
+			if len(responses) != 2 {
+				t.Fatalf("got %d function responses, want one per call", len(responses))
+			}
+			if responses[0].Response["duplicate_of_previous_call"] != nil {
+				t.Errorf("first response = %v, want it unflagged", responses[0].Response)
+			}
+			if flagged := responses[1].Response["duplicate_of_previous_call"] == true; flagged != tt.deduplicate {
+				t.Errorf("second response = %v, want duplicate_of_previous_call %v", responses[1].Response, tt.deduplicate)
+			}
+			if tt.deduplicate && responses[1].Response["url"] != responses[0].Response["url"] {
+				t.Errorf("duplicate response = %v, want the URL of the original", responses[1].Response)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package geminirod
+
+import (
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+// DemonstrationStep is a single turn of a recorded run: the action taken and a brief textual observation of its result
+type DemonstrationStep struct {
+	Action      string // e.g. `click_at(x=120, y=480)`
+	Observation string // e.g. "The cookie banner closed"
+}
+
+// demonstrationHeader introduces the demonstration to the model
+const demonstrationHeader = "The following is a demonstration of a successful run of a similar task. " +
+	"Use it as guidance, but act on what you actually see."
+
+// NewDemonstration converts recorded steps into a compact few-shot content without screenshots.
+// The returned content can be passed in StartLoopConfig.Demonstrations.
+func NewDemonstration(steps []DemonstrationStep) *genai.Content {
+	parts := []*genai.Part{{Text: demonstrationHeader}}
+	for i, step := range steps {
+		text := fmt.Sprintf("Step %d: %s", i+1, step.Action)
+		if step.Observation != "" {
+			text += "\nObservation: " + step.Observation
+		}
+		parts = append(parts, &genai.Part{Text: text})
+	}
+
+	return &genai.Content{
+		Role:  genai.RoleUser,
+		Parts: parts,
+	}
+}
+
+// TrimDemonstration returns a copy of demo that fits within approximately maxTokens tokens.
+// The first part (the header) is always kept; steps are dropped from the middle first,
+// since the start and the end of a trajectory are usually the most informative.
+// Tokens are estimated from text length (about 4 characters per token).
+func TrimDemonstration(demo *genai.Content, maxTokens int) *genai.Content {
+	if demo == nil || len(demo.Parts) == 0 {
+		return demo
+	}
+
+	header := demo.Parts[0]
+	steps := append([]*genai.Part(nil), demo.Parts[1:]...)
+
+	total := estimateTokens(header)
+	for _, part := range steps {
+		total += estimateTokens(part)
+	}
+
+	// Drop the middle step until the demonstration fits
+	for total > maxTokens && len(steps) > 0 {
+		mid := len(steps) / 2
+		total -= estimateTokens(steps[mid])
+		steps = append(steps[:mid], steps[mid+1:]...)
+	}
+
+	return &genai.Content{
+		Role:  demo.Role,
+		Parts: append([]*genai.Part{header}, steps...),
+	}
+}
+
+// estimateTokens roughly estimates the number of tokens of a text part
+func estimateTokens(part *genai.Part) int {
+	return (len(part.Text) + 3) / 4
+}
@@ -0,0 +1,106 @@
+package geminirod
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestNewDemonstration(t *testing.T) {
+	demo := NewDemonstration([]DemonstrationStep{
+		{Action: "click_at(x=120, y=480)", Observation: "The cookie banner closed"},
+		{Action: "navigate(url=https://example.com/cart)"},
+	})
+	if demo.Role != genai.RoleUser {
+		t.Errorf("role = %s, want user", demo.Role)
+	}
+	want := []string{
+		demonstrationHeader,
+		"Step 1: click_at(x=120, y=480)\nObservation: The cookie banner closed",
+		"Step 2: navigate(url=https://example.com/cart)",
+	}
+	if len(demo.Parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(demo.Parts), len(want))
+	}
+	for i, part := range demo.Parts {
+		if part.Text != want[i] || part.InlineData != nil {
+			t.Errorf("part %d = %q, want the text %q only", i, part.Text, want[i])
+		}
+	}
+}
+
+func TestTrimDemonstration(t *testing.T) {
+	var steps []DemonstrationStep
+	for i := 1; i <= 5; i++ {
+		// Each step is 20 characters, about 5 tokens
+		steps = append(steps, DemonstrationStep{Action: fmt.Sprintf("action_%d_padding_xx", i)})
+	}
+	demo := NewDemonstration(steps)
+	header := estimateTokens(demo.Parts[0])
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		wantSteps []string
+	}{
+		{name: "fits", maxTokens: 1000, wantSteps: []string{"1", "2", "3", "4", "5"}},
+		{name: "middle dropped first", maxTokens: header + 4*7, wantSteps: []string{"1", "2", "4", "5"}},
+		{name: "ends kept longest", maxTokens: header + 2*7, wantSteps: []string{"1", "5"}},
+		{name: "header only", maxTokens: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trimmed := TrimDemonstration(demo, tt.maxTokens)
+			if trimmed.Parts[0] != demo.Parts[0] {
+				t.Error("header dropped")
+			}
+			var got []string
+			for _, part := range trimmed.Parts[1:] {
+				number, _, _ := strings.Cut(strings.TrimPrefix(part.Text, "Step "), ":")
+				got = append(got, number)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantSteps, ",") {
+				t.Errorf("kept steps %v, want %v", got, tt.wantSteps)
+			}
+			if len(demo.Parts) != 6 {
+				t.Errorf("original has %d parts after trimming, want it untouched", len(demo.Parts))
+			}
+		})
+	}
+
+	if TrimDemonstration(nil, 10) != nil {
+		t.Error("trimming nil did not return nil")
+	}
+}
+
+func TestDemonstrationsInLoop(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+	)
+	config, _ := testConfig(t, backend)
+	config.RetainNoScreenshots = true
+	config.Demonstrations = []*genai.Content{
+		NewDemonstration([]DemonstrationStep{{Action: "click_at(x=1, y=1)", Observation: "the first demo worked"}}),
+		NewDemonstration([]DemonstrationStep{{Action: "click_at(x=2, y=2)", Observation: "the second demo worked"}}),
+	}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// The demonstrations follow the prompt in every request, pruning leaves them alone
+	for i, request := range backend.calls() {
+		contents := request.Contents
+		if len(contents) < 3 || contents[0].Parts[0].Text != config.Prompt {
+			t.Fatalf("request %d does not start with the prompt", i)
+		}
+		if !hasText(contents[1:2], "the first demo worked") || !hasText(contents[2:3], "the second demo worked") {
+			t.Errorf("request %d does not carry the demonstrations after the prompt", i)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package geminirod
+
+import (
+	"context"
+	"time"
+)
+
+// dragStepDelay gives drag-aware UIs time to react to each pointer move
+const dragStepDelay = 50 * time.Millisecond
+
+// maxDragSteps bounds the interpolation granularity of a drag segment
+const maxDragSteps = 50
+
+// point is a coordinate on the normalized grid
+type point struct {
+	x, y int
+}
+
+// extractWaypoints parses the optional waypoints argument, a list of {x, y} objects
+func extractWaypoints(args map[string]any) ([]point, error) {
+	val, exists := args["waypoints"]
+	if !exists {
+		return nil, nil
+	}
+
+	list, ok := val.([]any)
+	if !ok {
+		return nil, newToolError(ToolErrorInvalidArgument, "waypoints argument must be a list of {x, y} objects")
+	}
+
+	waypoints := make([]point, 0, len(list))
+	for i, item := range list {
+		coords, ok := item.(map[string]any)
+		if !ok {
+			return nil, newToolError(ToolErrorInvalidArgument, "waypoint %d must be an {x, y} object", i)
+		}
+		x, y, err := extractCoordinates(coords)
+		if err != nil {
+			return nil, err
+		}
+		if x < 0 || x >= normalizedGridSize || y < 0 || y >= normalizedGridSize {
+			return nil, newToolError(ToolErrorOutOfBounds, "waypoint %d (%d, %d) is outside the 0-999 grid", i, x, y)
+		}
+		waypoints = append(waypoints, point{x, y})
+	}
+	return waypoints, nil
+}
+
+// extractDragSteps parses the optional steps argument, the number of moves per path segment
+func extractDragSteps(args map[string]any) (int, error) {
+	val, exists := args["steps"]
+	if !exists {
+		return 1, nil
+	}
+
+	var steps int
+	switch v := val.(type) {
+	case float64:
+		steps = int(v)
+	case int:
+		steps = v
+	default:
+		return 0, newToolError(ToolErrorInvalidArgument, "steps argument must be a number")
+	}
+	if steps < 1 || steps > maxDragSteps {
+		return 0, newToolError(ToolErrorOutOfBounds, "steps argument must be between 1 and %d", maxDragSteps)
+	}
+	return steps, nil
+}
+
+// dragAlongPath presses the mouse at the first point, moves through the others in steps and releases at the last.
+// It returns the number of pointer moves made. If the drag fails or is cancelled once the mouse is down,
+// the mouse is released where it is so the page is not left mid-drag.
+func dragAlongPath(ctx context.Context, session BrowserSession, path []point, steps int, opts toolOptions) (moves int, err error) {
+	start := path[0]
+	if err := session.MouseDown(start.x, start.y); err != nil {
+		return 0, err
+	}
+
+	current := start
+	defer func() {
+		if err != nil {
+			// Best effort, the drag error is the one worth reporting
+			_ = session.MouseUp(current.x, current.y)
+		}
+	}()
+
+	for i := 1; i < len(path); i++ {
+		from, to := path[i-1], path[i]
+		for step := 1; step <= steps; step++ {
+			x := from.x + (to.x-from.x)*step/steps
+			y := from.y + (to.y-from.y)*step/steps
+			if err := session.MouseMove(x, y); err != nil {
+				return moves, err
+			}
+			current = point{x, y}
+			moves++
+			if err := opts.sleep(ctx, dragStepDelay); err != nil {
+				return moves, err
+			}
+		}
+	}
+
+	end := path[len(path)-1]
+	return moves, session.MouseUp(end.x, end.y)
+}
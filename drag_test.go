@@ -0,0 +1,88 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDragAlongPath(t *testing.T) {
+	session, browser := newFakeSession(t)
+	clock := newFakeClock()
+	path := []point{{10, 10}, {20, 10}, {20, 30}}
+
+	moves, err := dragAlongPath(context.Background(), session, path, 2, toolOptions{clock: clock})
+	if err != nil {
+		t.Fatalf("dragAlongPath: %v", err)
+	}
+	if moves != 4 {
+		t.Errorf("moves = %d, want 2 per segment", moves)
+	}
+	if got := len(clock.sleeps()); got != 4 {
+		t.Errorf("slept %d times, want once per move", got)
+	}
+	want := []string{"MouseDown", "MouseMove", "MouseMove", "MouseMove", "MouseMove", "MouseUp"}
+	if actions := browser.actions(); !slices.Equal(actions, want) {
+		t.Errorf("actions = %v, want %v", actions, want)
+	}
+	if moves := browser.recorded("MouseMove"); moves[1].Args[0] != 20 || moves[1].Args[1] != 10 {
+		t.Errorf("second move to %v, want the end of the first segment (20, 10)", moves[1].Args)
+	}
+}
+
+func TestDragAlongPathReleasesOnFailure(t *testing.T) {
+	session, browser := newFakeSession(t)
+	moved := 0
+	browser.failCalls(func(call fakeCall) error {
+		if call.Method == "MouseMove" {
+			moved++
+			if moved == 2 {
+				return errors.New("node not found")
+			}
+		}
+		return nil
+	})
+
+	moves, err := dragAlongPath(context.Background(), session, []point{{10, 10}, {50, 50}}, 4, toolOptions{clock: newFakeClock()})
+	if err == nil {
+		t.Fatal("dragAlongPath succeeded, want the move error")
+	}
+	if moves != 1 {
+		t.Errorf("moves = %d, want 1 before the failure", moves)
+	}
+	if actions := browser.actions(); actions[len(actions)-1] != "MouseUp" {
+		t.Errorf("actions = %v, want the mouse released after the failure", actions)
+	}
+}
+
+func TestDragAlongPathReleasesOnCancel(t *testing.T) {
+	session, browser := newFakeSession(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := dragAlongPath(ctx, session, []point{{10, 10}, {50, 50}}, 4, toolOptions{clock: newFakeClock()})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	actions := browser.actions()
+	if actions[len(actions)-1] != "MouseUp" {
+		t.Errorf("actions = %v, want the mouse released after cancellation", actions)
+	}
+	// The first step is made before the cancelled wait
+	if released := browser.recorded("MouseUp"); released[0].Args[0] != 20 || released[0].Args[1] != 20 {
+		t.Errorf("released at %v, want (20, 20) where the pointer was", released[0].Args)
+	}
+}
+
+func TestDragAlongPathNoReleaseWithoutPress(t *testing.T) {
+	session, browser := newFakeSession(t)
+	browser.fail("MouseDown", errors.New("node not found"))
+
+	if _, err := dragAlongPath(context.Background(), session, []point{{10, 10}, {50, 50}}, 1, toolOptions{clock: newFakeClock()}); err == nil {
+		t.Fatal("dragAlongPath succeeded, want the press error")
+	}
+	if actions := browser.actions(); !slices.Equal(actions, []string{"MouseDown"}) {
+		t.Errorf("actions = %v, want only the failed press", actions)
+	}
+}
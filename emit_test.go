@@ -0,0 +1,87 @@
+package geminirod
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmitEventGivesUpOnceCancelled(t *testing.T) {
+	events := make(chan Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// No consumer is waiting, so the event is dropped instead of blocking
+	if emitEvent(ctx, events, ErrorEvent{}) {
+		t.Error("emitEvent reported an event delivered without a consumer")
+	}
+
+	// A waiting consumer still gets it
+	received := make(chan Event)
+	go func() { received <- <-events }()
+	for !emitEvent(ctx, events, WarningEvent{Message: "last"}) {
+		time.Sleep(time.Millisecond) // Until the consumer is waiting
+	}
+	if event := <-received; event.(WarningEvent).Message != "last" {
+		t.Errorf("received %+v, want the WarningEvent", event)
+	}
+}
+
+func TestLoopExitsWhenConsumerStopsReading(t *testing.T) {
+	tests := []struct {
+		name  string
+		reads int // Events read before the consumer stops
+	}{
+		{name: "no event read"},
+		{name: "first event read", reads: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t,
+				fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+				fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+			)
+			config, _ := testConfig(t, backend)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			events := StartLoop(ctx, config)
+			for range tt.reads {
+				<-events
+			}
+			cancel()
+
+			// The goroutine must exit without anyone reading, closing the channel
+			waitForLoopExit(t)
+			select {
+			case _, ok := <-events:
+				if ok {
+					// Events may still be buffered by a consumer-ready send; the channel must close right after
+					for range events {
+					}
+				}
+			case <-time.After(time.Second):
+				t.Fatal("channel not closed after the loop exited")
+			}
+		})
+	}
+}
+
+// waitForLoopExit fails the test if the loop goroutine is still running after a while
+func waitForLoopExit(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		if !strings.Contains(stacks, "gemini-rod.startLoop.func") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("loop goroutine still running after cancellation:\n%s", stacks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,76 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestEmptyResponseRetried(t *testing.T) {
+	empties := []*genai.GenerateContentResponse{
+		{},                                   // No candidate
+		{Candidates: []*genai.Candidate{{}}}, // No content
+		textResponse("   "),                  // Whitespace only
+		modelResponse(&genai.Part{Text: "thinking", Thought: true}), // Thoughts only
+	}
+	for i, empty := range empties {
+		backend := newFakeBackend(t,
+			fakeReply{Response: empty},
+			fakeReply{Response: textResponse("the answer")},
+		)
+		config, _ := testConfig(t, backend)
+
+		events := collectEvents(t, context.Background(), config, nil)
+		if err := finalError(events); err != nil {
+			t.Fatalf("response %d: run failed: %v", i, err)
+		}
+		if got := len(eventsOf[WarningEvent](events)); got != 1 {
+			t.Errorf("response %d: got %d warnings, want 1", i, got)
+		}
+		completions := eventsOf[CompletionEvent](events)
+		if len(completions) != 1 || completions[0].Text != "the answer" {
+			t.Fatalf("response %d: completions = %+v, want the answer of the retry", i, completions)
+		}
+		// The empty response is not kept
+		calls := backend.calls()
+		if len(calls) != 2 || len(calls[1].Contents) != len(calls[0].Contents) {
+			t.Errorf("response %d: retry sent %d contents, want the same %d as the first call", i, len(calls[1].Contents), len(calls[0].Contents))
+		}
+	}
+}
+
+func TestEmptyResponseRetriesExhausted(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: textResponse("")},
+		fakeReply{Response: textResponse("")},
+		fakeReply{Response: textResponse("")},
+	)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); !errors.Is(err, ErrEmptyResponse) {
+		t.Fatalf("error = %v, want ErrEmptyResponse", err)
+	}
+	if got := len(backend.calls()); got != 3 {
+		t.Errorf("got %d model calls, want the first and 2 retries", got)
+	}
+	if got := exitCodeForError(finalError(events)); got != ExitTransient {
+		t.Errorf("exit code = %d, want ExitTransient", got)
+	}
+}
+
+func TestEmptyResponseRetriesDisabled(t *testing.T) {
+	backend := newFakeBackend(t, fakeReply{Response: textResponse("")})
+	config, _ := testConfig(t, backend)
+	config.MaxEmptyResponseRetries = -1
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); !errors.Is(err, ErrEmptyResponse) {
+		t.Fatalf("error = %v, want ErrEmptyResponse", err)
+	}
+	if got := len(backend.calls()); got != 1 {
+		t.Errorf("got %d model calls, want 1", got)
+	}
+}
@@ -1,5 +1,11 @@
 package geminirod
 
+import (
+	"time"
+
+	"google.golang.org/genai"
+)
+
 // Event represents different events that can occur during the StartLoop execution.
 // This interface uses a sealed/sum-type pattern similar to Rust enums.
 type Event interface {
@@ -10,10 +16,22 @@ type Event interface {
 type ProgressEvent struct {
 	Text          string
 	FunctionCalls []*FunctionCall
+	Usage         Usage // Tokens of this turn's model calls
 }
 
 func (ProgressEvent) isEvent() {}
 
+// TextDeltaEvent carries a chunk of model text as it is generated, see StartLoopConfig.Stream.
+// The turn's ProgressEvent follows with the full text. Chunks of a call that is retried, after a RetryEvent
+// or a WarningEvent, are superseded by those of the retry.
+type TextDeltaEvent struct {
+	Turn    int // Turn being generated, starting from 1
+	Text    string
+	Thought bool // Part of a thought summary rather than the answer
+}
+
+func (TextDeltaEvent) isEvent() {}
+
 // ErrorEvent represents an error that occurred
 type ErrorEvent struct {
 	Err error
@@ -21,6 +39,149 @@ type ErrorEvent struct {
 
 func (ErrorEvent) isEvent() {}
 
+// CompletionEvent is the last event of every run, sent just before the channel is closed,
+// whether the run finished, failed or was cancelled
+type CompletionEvent struct {
+	Text          string           // Text of the last model turn
+	History       []*genai.Content // Full conversation, partial if the run stopped early
+	Turns         int              // Number of completed model turns
+	FunctionCalls int              // Number of function calls executed, duplicates skipped by the loop excluded
+	Duration      time.Duration    // Wall-clock duration of the run
+	Usage         Usage            // Tokens of all model calls of the run
+
+	TypedCharacters int // Characters typed by type_text_at over the run, see MaxTypedCharacters
+	Clicks          int // Clicks made by click_at over the run, see MaxClicks
+}
+
+func (CompletionEvent) isEvent() {}
+
+// DeadlineEvent is the final event of a run stopped on a turn boundary because RunDeadline or MaxRunDuration was reached
+type DeadlineEvent struct {
+	Elapsed time.Duration // Wall-clock time since the loop started
+	Turns   int           // Number of completed model turns
+}
+
+func (DeadlineEvent) isEvent() {}
+
+// MaxTurnsExceededEvent is the final event of a run stopped by MaxTurns.
+// The last turn's function calls were executed and their responses are the end of History.
+type MaxTurnsExceededEvent struct {
+	Turns   int              // Number of completed model turns
+	History []*genai.Content // Conversation so far, for inspecting what happened
+}
+
+func (MaxTurnsExceededEvent) isEvent() {}
+
+// BudgetExceededEvent is the final event of a run stopped by MaxTotalTokens or MaxEstimatedCost.
+// The run stops before the next model call; History in the CompletionEvent is complete up to there.
+type BudgetExceededEvent struct {
+	Reason        string
+	Usage         Usage   // Tokens used by the run
+	EstimatedCost float64 // Usage.EstimatedCost at Pricing, 0 without Pricing
+}
+
+func (BudgetExceededEvent) isEvent() {}
+
+// BlockedEvent is the final event of a run whose prompt the API blocked, e.g. for safety reasons.
+// The blocked request is not retried.
+type BlockedEvent struct {
+	Reason        string                // Block reason reported by the API, e.g. "SAFETY"
+	Message       string                // Readable explanation, if any
+	SafetyRatings []*genai.SafetyRating // Ratings that led to the block, if any
+}
+
+func (BlockedEvent) isEvent() {}
+
+// ActionLimitExceededEvent is the final event of a run stopped by MaxTypedCharacters or MaxClicks.
+// The action that would have exceeded the limit was not executed.
+type ActionLimitExceededEvent struct {
+	Reason          string
+	TypedCharacters int // Characters typed so far
+	Clicks          int // Clicks made so far
+}
+
+func (ActionLimitExceededEvent) isEvent() {}
+
+// FindingEvent is emitted when the model reports an intermediate result with report_finding, see EnableFindings
+type FindingEvent struct {
+	Key      string
+	Finding  Finding
+	Findings map[string]Finding // All findings of the run so far
+}
+
+func (FindingEvent) isEvent() {}
+
+// WaitingForGateEvent is emitted when the loop pauses before a model call until TurnGate lets it through
+type WaitingForGateEvent struct {
+	Turn int // Turn waiting to start, starting from 1
+}
+
+func (WaitingForGateEvent) isEvent() {}
+
+// RetryEvent is emitted before a failed model call is retried, e.g. after a rate limit
+type RetryEvent struct {
+	Attempt    int           // Retry about to be made, starting from 1
+	MaxRetries int           // Retries allowed, see StartLoopConfig.MaxRetries
+	Delay      time.Duration // Time waited before the retry
+	Err        error         // Error of the failed call
+}
+
+func (RetryEvent) isEvent() {}
+
+// ScreenshotEvent carries the screenshot taken after a built-in tool was executed, see EmitScreenshots.
+// It follows the ProgressEvent announcing the call, in call order, and is not sent when the screenshot was skipped.
+type ScreenshotEvent struct {
+	ToolName   string
+	Args       map[string]any // Sensitive arguments masked unless LogSensitiveArgs is set
+	URL        string         // Page URL after the action
+	Screenshot []byte         // PNG, as sent to the model
+	Time       time.Time      // When the screenshot was taken
+}
+
+func (ScreenshotEvent) isEvent() {}
+
+// ToolResultEvent reports the outcome of a built-in tool call executed by the loop, after its ScreenshotEvent if any.
+// A failed call is reported whether it was sent to the model or ended the run, see ToolErrorMode.
+type ToolResultEvent struct {
+	Turn     int // Turn the call was made in, starting from 1
+	ToolName string
+	Args     map[string]any // Sensitive arguments masked unless LogSensitiveArgs is set
+	Response map[string]any // Response fields sent to the model, without the screenshot. Nil if the call failed
+	Err      error          // Nil on success
+	Code     string         // Code of Err if it is a ToolError, see errors.As
+	Attempts int            // Times the tool ran, more than 1 if transient errors were retried. 0 if it did not run
+	Duration time.Duration  // Time spent executing the call, screenshot included
+}
+
+func (ToolResultEvent) isEvent() {}
+
+// UserMessageEvent is emitted when messages sent with Loop.Inject are added to the history,
+// as one user turn placed after the previous turn's function responses, right before the model call
+type UserMessageEvent struct {
+	Turn     int      // Turn the messages were added before, starting from 1
+	Messages []string // Messages in the order they were injected
+}
+
+func (UserMessageEvent) isEvent() {}
+
+// WarningEvent represents a non-fatal problem the loop recovered from
+type WarningEvent struct {
+	Message string
+}
+
+func (WarningEvent) isEvent() {}
+
+// PruneEvent reports screenshots removed from the history to keep context size manageable.
+// It is only emitted when something was actually removed.
+type PruneEvent struct {
+	TurnIndex          int   // Turn after which pruning happened, starting from 1
+	ScreenshotsRemoved int   // Number of screenshot parts removed
+	BytesFreed         int   // Total size of the removed image data
+	AffectedTurns      []int // Indices into the history of the contents that lost screenshots
+}
+
+func (PruneEvent) isEvent() {}
+
 // SafetyConfirmationEvent represents a safety confirmation that requires user approval
 type SafetyConfirmationEvent struct {
 	Explanation string
@@ -37,17 +198,20 @@ func (sc *SafetyConfirmationEvent) Approve() {
 	}
 }
 
-// Deny denies the safety decision and terminates the loop
+// Deny denies the safety decision. The action is not executed and the model is told it was denied
 func (sc *SafetyConfirmationEvent) Deny() {
 	if sc.denyFunc != nil {
 		sc.denyFunc()
 	}
 }
 
-// FunctionCall represents a function call that may or may not require action from the subscriber
+// FunctionCall represents a function call that may or may not require action from the subscriber.
+// For the calls of a loop, only the first Respond or Reject counts and neither blocks, so the calls of a turn
+// can be answered in any order.
 type FunctionCall struct {
 	FunctionName string
 	Args         map[string]any
+	Info         LoopInfo // Run state when the call was made, see ContextWithLoopInfo
 	needsAction  bool
 	respondFunc  func(response map[string]any)
 	rejectFunc   func(err error)
@@ -66,9 +230,22 @@ func (fc *FunctionCall) Respond(response map[string]any) {
 	}
 }
 
-// Reject rejects this function call with an error
+// Reject rejects this function call with an error, ending the run
 func (fc *FunctionCall) Reject(err error) {
 	if fc.rejectFunc != nil {
 		fc.rejectFunc(err)
 	}
 }
+
+// RejectAndContinue rejects this function call without ending the run.
+// The model receives {"error": "rejected by user: <err>"}, or "rejected by user" for a nil err,
+// and can choose a different approach.
+func (fc *FunctionCall) RejectAndContinue(err error) {
+	message := "rejected by user"
+	if err != nil {
+		message += ": " + err.Error()
+	}
+	fc.Respond(map[string]any{
+		"error": message,
+	})
+}
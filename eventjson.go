@@ -0,0 +1,264 @@
+package geminirod
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// EventSchemaVersion is the version of the JSON event format written by MarshalEvent and described by EventSchema.
+// It is bumped whenever an event's fields change.
+const EventSchemaVersion = 9
+
+//go:embed eventschema.json
+var eventSchema []byte
+
+// EventSchema returns the JSON Schema of the envelopes written by MarshalEvent
+func EventSchema() []byte {
+	return slices.Clone(eventSchema)
+}
+
+// eventEnvelope wraps every serialized event
+type eventEnvelope struct {
+	SchemaVersion int    `json:"schema_version"`
+	Type          string `json:"type"`
+	Event         any    `json:"event"`
+}
+
+// MarshalEvent serializes an event to JSON for consumers outside Go, see EventSchema.
+// The envelope carries the schema version and the event type, e.g. {"schema_version":N,"type":"warning","event":{...}}.
+// Callbacks such as Approve or Respond cannot be serialized; the event only describes what happened.
+func MarshalEvent(event Event) ([]byte, error) {
+	eventType, payload, err := eventPayload(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(eventEnvelope{
+		SchemaVersion: EventSchemaVersion,
+		Type:          eventType,
+		Event:         payload,
+	})
+}
+
+// Wire forms of the event fields that do not serialize as they are
+
+type functionCallJSON struct {
+	FunctionName string         `json:"function_name"`
+	Args         map[string]any `json:"args"`
+	NeedsAction  bool           `json:"needs_action"`
+	Info         loopInfoJSON   `json:"info"`
+}
+
+type loopInfoJSON struct {
+	Turn           int    `json:"turn"`
+	CurrentURL     string `json:"current_url"`
+	RemainingTurns int    `json:"remaining_turns"`
+	Deadline       string `json:"deadline,omitempty"` // RFC 3339, omitted without a deadline
+}
+
+type usageJSON struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CachedTokens     int `json:"cached_tokens"`
+	CandidatesTokens int `json:"candidates_tokens"`
+	ThoughtsTokens   int `json:"thoughts_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	Calls            int `json:"calls"`
+}
+
+type findingJSON struct {
+	Value string `json:"value"`
+	Note  string `json:"note"`
+}
+
+// eventPayload returns the type name and the wire form of an event
+func eventPayload(event Event) (string, any, error) {
+	switch ev := event.(type) {
+	case ProgressEvent:
+		calls := make([]functionCallJSON, 0, len(ev.FunctionCalls))
+		for _, fc := range ev.FunctionCalls {
+			calls = append(calls, functionCallToJSON(fc))
+		}
+		return "progress", struct {
+			Text          string             `json:"text"`
+			FunctionCalls []functionCallJSON `json:"function_calls"`
+			Usage         usageJSON          `json:"usage"`
+		}{ev.Text, calls, usageJSON(ev.Usage)}, nil
+	case TextDeltaEvent:
+		return "text_delta", struct {
+			Turn    int    `json:"turn"`
+			Text    string `json:"text"`
+			Thought bool   `json:"thought"`
+		}{ev.Turn, ev.Text, ev.Thought}, nil
+	case ErrorEvent:
+		return "error", struct {
+			Error string `json:"error"`
+		}{errorString(ev.Err)}, nil
+	case CompletionEvent:
+		return "completion", struct {
+			Text            string           `json:"text"`
+			History         []*genai.Content `json:"history"`
+			Turns           int              `json:"turns"`
+			FunctionCalls   int              `json:"function_calls"`
+			TypedCharacters int              `json:"typed_characters"`
+			Clicks          int              `json:"clicks"`
+			DurationMS      int64            `json:"duration_ms"`
+			Usage           usageJSON        `json:"usage"`
+		}{ev.Text, historyOrEmpty(ev.History), ev.Turns, ev.FunctionCalls, ev.TypedCharacters, ev.Clicks, ev.Duration.Milliseconds(), usageJSON(ev.Usage)}, nil
+	case DeadlineEvent:
+		return "deadline", struct {
+			ElapsedMS int64 `json:"elapsed_ms"`
+			Turns     int   `json:"turns"`
+		}{ev.Elapsed.Milliseconds(), ev.Turns}, nil
+	case MaxTurnsExceededEvent:
+		return "max_turns_exceeded", struct {
+			Turns   int              `json:"turns"`
+			History []*genai.Content `json:"history"`
+		}{ev.Turns, historyOrEmpty(ev.History)}, nil
+	case BudgetExceededEvent:
+		return "budget_exceeded", struct {
+			Reason        string    `json:"reason"`
+			Usage         usageJSON `json:"usage"`
+			EstimatedCost float64   `json:"estimated_cost"`
+		}{ev.Reason, usageJSON(ev.Usage), ev.EstimatedCost}, nil
+	case BlockedEvent:
+		ratings := ev.SafetyRatings
+		if ratings == nil {
+			ratings = []*genai.SafetyRating{}
+		}
+		return "blocked", struct {
+			Reason        string                `json:"reason"`
+			Message       string                `json:"message"`
+			SafetyRatings []*genai.SafetyRating `json:"safety_ratings"`
+		}{ev.Reason, ev.Message, ratings}, nil
+	case ActionLimitExceededEvent:
+		return "action_limit_exceeded", struct {
+			Reason          string `json:"reason"`
+			TypedCharacters int    `json:"typed_characters"`
+			Clicks          int    `json:"clicks"`
+		}{ev.Reason, ev.TypedCharacters, ev.Clicks}, nil
+	case FindingEvent:
+		findings := make(map[string]findingJSON, len(ev.Findings))
+		for key, finding := range ev.Findings {
+			findings[key] = findingJSON(finding)
+		}
+		return "finding", struct {
+			Key      string                 `json:"key"`
+			Finding  findingJSON            `json:"finding"`
+			Findings map[string]findingJSON `json:"findings"`
+		}{ev.Key, findingJSON(ev.Finding), findings}, nil
+	case WaitingForGateEvent:
+		return "waiting_for_gate", struct {
+			Turn int `json:"turn"`
+		}{ev.Turn}, nil
+	case RetryEvent:
+		return "retry", struct {
+			Attempt    int    `json:"attempt"`
+			MaxRetries int    `json:"max_retries"`
+			DelayMS    int64  `json:"delay_ms"`
+			Error      string `json:"error"`
+		}{ev.Attempt, ev.MaxRetries, ev.Delay.Milliseconds(), errorString(ev.Err)}, nil
+	case ScreenshotEvent:
+		args := ev.Args
+		if args == nil {
+			args = map[string]any{}
+		}
+		screenshot := ev.Screenshot
+		if screenshot == nil {
+			screenshot = []byte{} // An empty string rather than null
+		}
+		return "screenshot", struct {
+			ToolName   string         `json:"tool_name"`
+			Args       map[string]any `json:"args"`
+			URL        string         `json:"url"`
+			Screenshot []byte         `json:"screenshot"` // Base64
+			Time       time.Time      `json:"time"`
+		}{ev.ToolName, args, ev.URL, screenshot, ev.Time}, nil
+	case ToolResultEvent:
+		args := ev.Args
+		if args == nil {
+			args = map[string]any{}
+		}
+		return "tool_result", struct {
+			Turn       int            `json:"turn"`
+			ToolName   string         `json:"tool_name"`
+			Args       map[string]any `json:"args"`
+			Response   map[string]any `json:"response"` // Null if the call failed
+			Error      string         `json:"error"`
+			Code       string         `json:"code"`
+			Attempts   int            `json:"attempts"`
+			DurationMS int64          `json:"duration_ms"`
+		}{ev.Turn, ev.ToolName, args, ev.Response, errorString(ev.Err), ev.Code, ev.Attempts, ev.Duration.Milliseconds()}, nil
+	case UserMessageEvent:
+		messages := ev.Messages
+		if messages == nil {
+			messages = []string{}
+		}
+		return "user_message", struct {
+			Turn     int      `json:"turn"`
+			Messages []string `json:"messages"`
+		}{ev.Turn, messages}, nil
+	case WarningEvent:
+		return "warning", struct {
+			Message string `json:"message"`
+		}{ev.Message}, nil
+	case PruneEvent:
+		affected := ev.AffectedTurns
+		if affected == nil {
+			affected = []int{}
+		}
+		return "prune", struct {
+			TurnIndex          int   `json:"turn_index"`
+			ScreenshotsRemoved int   `json:"screenshots_removed"`
+			BytesFreed         int   `json:"bytes_freed"`
+			AffectedTurns      []int `json:"affected_turns"`
+		}{ev.TurnIndex, ev.ScreenshotsRemoved, ev.BytesFreed, affected}, nil
+	case SafetyConfirmationEvent:
+		return "safety_confirmation", struct {
+			Explanation string `json:"explanation"`
+		}{ev.Explanation}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported event type %T", event)
+	}
+}
+
+// functionCallToJSON returns the wire form of a function call
+func functionCallToJSON(fc *FunctionCall) functionCallJSON {
+	args := fc.Args
+	if args == nil {
+		args = map[string]any{}
+	}
+	info := loopInfoJSON{
+		Turn:           fc.Info.Turn,
+		CurrentURL:     fc.Info.CurrentURL,
+		RemainingTurns: fc.Info.RemainingTurns,
+	}
+	if !fc.Info.Deadline.IsZero() {
+		info.Deadline = fc.Info.Deadline.Format(time.RFC3339Nano)
+	}
+	return functionCallJSON{
+		FunctionName: fc.FunctionName,
+		Args:         args,
+		NeedsAction:  fc.NeedsAction(),
+		Info:         info,
+	}
+}
+
+// historyOrEmpty serializes a nil history as an empty array rather than null
+func historyOrEmpty(history []*genai.Content) []*genai.Content {
+	if history == nil {
+		return []*genai.Content{}
+	}
+	return history
+}
+
+// errorString returns the message of err, empty if nil
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,271 @@
+package geminirod
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// schemaValidator checks JSON values against the subset of JSON Schema used by eventschema.json
+type schemaValidator struct {
+	defs map[string]map[string]any
+}
+
+func newSchemaValidator(t *testing.T) (*schemaValidator, map[string]any) {
+	t.Helper()
+	var root map[string]any
+	if err := json.Unmarshal(EventSchema(), &root); err != nil {
+		t.Fatalf("parsing EventSchema: %v", err)
+	}
+	v := &schemaValidator{defs: make(map[string]map[string]any)}
+	for name, def := range root["$defs"].(map[string]any) {
+		v.defs[name] = def.(map[string]any)
+	}
+	return v, root
+}
+
+// validate returns the first violation of schema by value, nil if there is none
+func (v *schemaValidator) validate(schema map[string]any, value any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, found := v.defs[strings.TrimPrefix(ref, "#/$defs/")]
+		if !found {
+			return fmt.Errorf("%s: unresolved $ref %s", path, ref)
+		}
+		return v.validate(def, value, path)
+	}
+	if want, ok := schema["const"]; ok && value != want {
+		return fmt.Errorf("%s: %v is not the constant %v", path, value, want)
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+	}
+	if err := checkType(schema["type"], value, path); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range asSlice(schema["required"]) {
+			if _, ok := value[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required %s", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for key, field := range value {
+			fieldPath := path + "." + key
+			if property, ok := properties[key]; ok {
+				if err := v.validate(property.(map[string]any), field, fieldPath); err != nil {
+					return err
+				}
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					return fmt.Errorf("%s: not allowed by the schema", fieldPath)
+				}
+			case map[string]any:
+				if err := v.validate(extra, field, fieldPath); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", path, value)
+			}
+		}
+		if schema["contentEncoding"] == "base64" {
+			if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+				return fmt.Errorf("%s: not base64: %v", path, err)
+			}
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, option := range oneOf {
+			if v.validate(option.(map[string]any), value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d oneOf options, want exactly 1", path, matches)
+		}
+	}
+	return nil
+}
+
+func asSlice(value any) []any {
+	s, _ := value.([]any)
+	return s
+}
+
+// checkType checks value against a type keyword, a name or a list of names
+func checkType(types any, value any, path string) error {
+	if types == nil {
+		return nil
+	}
+	names, ok := types.([]any)
+	if !ok {
+		names = []any{types}
+	}
+	for _, name := range names {
+		if hasType(name.(string), value) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %v (%T) is not of type %v", path, value, value, types)
+}
+
+func hasType(name string, value any) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func TestMarshalEventMatchesSchema(t *testing.T) {
+	usage := Usage{PromptTokens: 10, CandidatesTokens: 5, TotalTokens: 15, Calls: 1}
+	history := []*genai.Content{genai.NewContentFromText("task", genai.RoleUser)}
+	finding := Finding{Value: "42", Note: "from the table"}
+
+	// Each event type, with its fields empty and set
+	events := []Event{
+		ProgressEvent{},
+		ProgressEvent{Text: "clicking", Usage: usage, FunctionCalls: []*FunctionCall{
+			NewFunctionCall("click_at", map[string]any{"x": 1.0}, nil, nil),
+			{FunctionName: "query_table", Info: LoopInfo{Turn: 2, CurrentURL: "https://example.com", RemainingTurns: 3, Deadline: time.Unix(1700000000, 0)}},
+		}},
+		TextDeltaEvent{},
+		TextDeltaEvent{Turn: 1, Text: "hmm", Thought: true},
+		ErrorEvent{},
+		ErrorEvent{Err: errors.New("boom")},
+		CompletionEvent{},
+		CompletionEvent{Text: "done", History: history, Turns: 2, FunctionCalls: 3, TypedCharacters: 4, Clicks: 5, Duration: time.Second, Usage: usage},
+		DeadlineEvent{},
+		DeadlineEvent{Elapsed: time.Minute, Turns: 4},
+		MaxTurnsExceededEvent{},
+		MaxTurnsExceededEvent{Turns: 10, History: history},
+		BudgetExceededEvent{},
+		BudgetExceededEvent{Reason: "tokens", Usage: usage, EstimatedCost: 0.25},
+		BlockedEvent{},
+		BlockedEvent{Reason: "SAFETY", Message: "blocked", SafetyRatings: []*genai.SafetyRating{{Category: genai.HarmCategoryHarassment, Blocked: true}}},
+		ActionLimitExceededEvent{},
+		ActionLimitExceededEvent{Reason: "clicks", TypedCharacters: 1, Clicks: 2},
+		FindingEvent{},
+		FindingEvent{Key: "answer", Finding: finding, Findings: map[string]Finding{"answer": finding}},
+		WaitingForGateEvent{},
+		WaitingForGateEvent{Turn: 3},
+		RetryEvent{},
+		RetryEvent{Attempt: 1, MaxRetries: 3, Delay: time.Second, Err: errors.New("503")},
+		ScreenshotEvent{},
+		ScreenshotEvent{ToolName: "click_at", Args: map[string]any{"x": 1.0}, URL: "https://example.com", Screenshot: []byte("png"), Time: time.Unix(1700000000, 0)},
+		ToolResultEvent{},
+		ToolResultEvent{Turn: 1, ToolName: "click_at", Args: map[string]any{"x": 1.0}, Response: map[string]any{"url": "https://example.com"}, Attempts: 2, Duration: time.Second},
+		ToolResultEvent{Turn: 1, ToolName: "click_at", Err: errors.New("detached"), Code: ToolErrorActionFailed, Attempts: 1},
+		UserMessageEvent{},
+		UserMessageEvent{Turn: 2, Messages: []string{"hurry"}},
+		WarningEvent{},
+		WarningEvent{Message: "careful"},
+		PruneEvent{},
+		PruneEvent{TurnIndex: 3, ScreenshotsRemoved: 2, BytesFreed: 100, AffectedTurns: []int{1, 3}},
+		NewSafetyConfirmationEvent("confirm", nil, nil),
+	}
+
+	validator, root := newSchemaValidator(t)
+	covered := make(map[string]bool)
+	for _, event := range events {
+		t.Run(fmt.Sprintf("%T", event), func(t *testing.T) {
+			data, err := MarshalEvent(event)
+			if err != nil {
+				t.Fatalf("MarshalEvent: %v", err)
+			}
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			var envelope map[string]any
+			if err := decoder.Decode(&envelope); err != nil {
+				t.Fatalf("decoding %s: %v", data, err)
+			}
+			if err := validator.validate(root, envelope, "$"); err != nil {
+				t.Errorf("%s\ndoes not match the schema: %v", data, err)
+			}
+			eventType, _ := envelope["type"].(string)
+			covered[eventType] = true
+		})
+	}
+
+	// Every type of the schema is exercised, so a new event cannot skip this test
+	for _, eventType := range asSlice(root["properties"].(map[string]any)["type"].(map[string]any)["enum"]) {
+		if !covered[eventType.(string)] {
+			t.Errorf("no event of type %s tested", eventType)
+		}
+	}
+	if len(asSlice(root["oneOf"])) != len(covered) {
+		t.Errorf("schema has %d oneOf options, tested %d types", len(asSlice(root["oneOf"])), len(covered))
+	}
+}
+
+func TestMarshalEventRejectsUnknownTypes(t *testing.T) {
+	type otherEvent struct{ Event }
+	if _, err := MarshalEvent(otherEvent{}); err == nil {
+		t.Error("MarshalEvent succeeded for an unsupported event type")
+	}
+}
+
+func TestSchemaValidatorCatchesViolations(t *testing.T) {
+	validator, root := newSchemaValidator(t)
+	invalid := []string{
+		`{"schema_version":1,"type":"warning","event":{"message":"x"}}`,
+		`{"schema_version":9,"type":"warning","event":{}}`,
+		`{"schema_version":9,"type":"warning","event":{"message":"x","extra":1}}`,
+		`{"schema_version":9,"type":"prune","event":{"turn_index":1.5,"screenshots_removed":0,"bytes_freed":0,"affected_turns":[]}}`,
+		`{"schema_version":9,"type":"user_message","event":{"turn":1,"messages":null}}`,
+		`{"schema_version":9,"type":"nonsense","event":{}}`,
+	}
+	for _, data := range invalid {
+		var envelope map[string]any
+		if err := json.Unmarshal([]byte(data), &envelope); err != nil {
+			t.Fatalf("parsing %s: %v", data, err)
+		}
+		if validator.validate(root, envelope, "$") == nil {
+			t.Errorf("%s validated, want a violation", data)
+		}
+	}
+}
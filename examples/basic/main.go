@@ -6,41 +6,30 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
-	computeruse "github.com/PeronGH/computer-use-lib"
 	geminirod "github.com/PeronGH/gemini-rod"
 	"google.golang.org/genai"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run executes the example and returns the process exit code, see geminirod.ExitCodeFor
+func run() int {
 	// Parse command-line arguments
 	query := flag.String("query", "", "The query for the browser agent to execute.")
 	initialURL := flag.String("initial-url", "", "The initial URL loaded for the computer.")
 	model := flag.String("model", "", "Set which main model to use.")
 	unsafe := flag.Bool("unsafe", false, "Skip safety confirmation (unrecommended, may violate ToS)")
+	maxTurns := flag.Int("max-turns", 0, "Stop after this many model turns, 0 = unlimited.")
+	listModels := flag.Bool("list-models", false, "List the available computer use models and exit.")
 	flag.Parse()
 
-	if *query == "" {
-		log.Fatal("Error: --query flag is required")
-	}
-
 	// Create context
 	ctx := context.Background()
 
-	// Initialize computer use session
-	session, err := computeruse.NewSession(ctx, computeruse.SessionConfig{
-		InitialURL:           *initialURL,
-		NormalizeCoordinates: true,
-	})
-	if err != nil {
-		log.Fatalf("Failed to create computer use session: %v", err)
-	}
-	defer func() {
-		if err := session.Close(); err != nil {
-			log.Printf("Failed to close session: %v", err)
-		}
-	}()
-
 	// Initialize Genai client
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey: os.Getenv("GEMINI_API_KEY"),
@@ -49,21 +38,61 @@ func main() {
 		},
 	})
 	if err != nil {
-		log.Fatalf("Failed to create genai client: %v", err)
+		log.Printf("Failed to create genai client: %v", err)
+		return geminirod.ExitPermanent
+	}
+
+	if *listModels {
+		models, err := geminirod.ListSupportedModels(ctx, client)
+		if err != nil {
+			log.Printf("Failed to list models: %v", err)
+			return geminirod.ExitCodeFor(geminirod.ErrorEvent{Err: err})
+		}
+		for _, name := range models {
+			fmt.Println(name)
+		}
+		return geminirod.ExitSuccess
+	}
+
+	if *query == "" {
+		log.Print("Error: --query flag is required")
+		return geminirod.ExitPermanent
+	}
+
+	// Initialize computer use session
+	session, coordinateSpace, err := geminirod.NewBrowserSession(ctx, geminirod.BrowserConfig{
+		InitialURL: *initialURL,
+		Headful:    true,
+	})
+	if err != nil {
+		log.Printf("Failed to create computer use session: %v", err)
+		return geminirod.ExitTransient
 	}
+	defer func() {
+		if err := session.Close(); err != nil {
+			log.Printf("Failed to close session: %v", err)
+		}
+	}()
 
 	// Start the agent loop
 	eventChan := geminirod.StartLoop(ctx, geminirod.StartLoopConfig{
 		GenaiClient:            client,
 		ComputerUseSession:     session,
+		CoordinateSpace:        coordinateSpace,
 		ExtraTools:             nil,
 		Prompt:                 *query,
 		Model:                  *model,
 		SkipSafetyConfirmation: *unsafe,
+		MaxTurns:               *maxTurns,
 	})
 
-	// Process events
+	// Process events, keeping the exit code of the event that ended the run
+	exitCode := geminirod.ExitSuccess
 	for event := range eventChan {
+		if code := geminirod.ExitCodeFor(event); code != geminirod.ExitSuccess {
+			exitCode = code
+		}
+
 		switch e := event.(type) {
 		case geminirod.ProgressEvent:
 			// Print reasoning/text if present
@@ -100,14 +129,39 @@ func main() {
 				e.Approve()
 			default:
 				e.Deny()
-				fmt.Println("Terminating agent loop")
-				return
+				fmt.Println("Action denied, the model will look for another way")
 			}
 
+		case geminirod.CompletionEvent:
+			fmt.Printf("\nRun finished: %d turns, %d function calls in %s\n", e.Turns, e.FunctionCalls, e.Duration.Round(time.Second))
+			fmt.Printf("Tokens: %d in, %d out, %d thinking, %d total\n", e.Usage.PromptTokens, e.Usage.CandidatesTokens, e.Usage.ThoughtsTokens, e.Usage.TotalTokens)
+
+		case geminirod.MaxTurnsExceededEvent:
+			fmt.Printf("\nStopped after reaching the turn limit (%d turns)\n", e.Turns)
+
+		case geminirod.BudgetExceededEvent:
+			fmt.Printf("\nStopped: token budget reached, %s\n", e.Reason)
+
+		case geminirod.DeadlineEvent:
+			fmt.Printf("\nRun deadline reached after %s (%d turns)\n", e.Elapsed, e.Turns)
+
+		case geminirod.RetryEvent:
+			log.Printf("Model call failed, retrying in %s (%d/%d): %v", e.Delay.Round(time.Millisecond), e.Attempt, e.MaxRetries, e.Err)
+
+		case geminirod.WarningEvent:
+			log.Printf("Warning: %s", e.Message)
+
+		case geminirod.BlockedEvent:
+			fmt.Printf("\nPrompt blocked: %s %s\n", e.Reason, e.Message)
+
+		case geminirod.ActionLimitExceededEvent:
+			fmt.Printf("\nStopped: %s\n", e.Reason)
+
 		case geminirod.ErrorEvent:
-			log.Fatalf("Error: %v", e.Err)
+			log.Printf("Error: %v", e.Err)
 		}
 	}
 
 	fmt.Println("Agent Loop Complete")
+	return exitCode
 }
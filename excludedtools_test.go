@@ -0,0 +1,76 @@
+package geminirod
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExcludedTools(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit bool
+	}{
+		{name: "computer use tool"},
+		{name: "declared explicitly", explicit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(
+				call("key_combination", map[string]any{"keys": "Control+W"}),
+				call("click_at", map[string]any{"x": 10.0, "y": 10.0}),
+			)})
+			config, browser := testConfig(t, backend)
+			config.ExcludedTools = []string{"drag_and_drop", "key_combination"}
+			config.DeclareBuiltInsExplicitly = tt.explicit
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			// The model is told about the exclusion
+			request := backend.calls()[0]
+			if tt.explicit {
+				declared := request.declaredFunctions()
+				if slices.Contains(declared, "key_combination") || slices.Contains(declared, "drag_and_drop") || !slices.Contains(declared, "click_at") {
+					t.Errorf("declared functions = %v, want the excluded tools left out", declared)
+				}
+			} else {
+				var excluded []any
+				for _, tool := range request.Tools {
+					if computerUse, ok := tool["computerUse"].(map[string]any); ok {
+						excluded, _ = computerUse["excludedPredefinedFunctions"].([]any)
+					}
+				}
+				if !slices.Equal(excluded, []any{"drag_and_drop", "key_combination"}) {
+					t.Errorf("excluded predefined functions = %v, want the excluded tools", excluded)
+				}
+			}
+
+			// An excluded call is answered with an error and never reaches the browser, the rest of the turn runs
+			if !slices.Equal(browser.actions(), []string{"ClickAt"}) {
+				t.Errorf("browser actions = %v, want the click only", browser.actions())
+			}
+			history := backend.calls()[1].Contents
+			responses := functionResponses(history[len(history)-1])
+			denied := responses["key_combination"]
+			if denied == nil || denied.Response["code"] != ToolErrorPolicyDenied || !strings.Contains(denied.Response["error"].(string), "disabled by policy") {
+				t.Errorf("key_combination response = %+v, want a policy error", denied)
+			}
+			if clicked := responses["click_at"]; clicked == nil || clicked.Response["error"] != nil {
+				t.Errorf("click_at response = %+v, want it executed", clicked)
+			}
+		})
+	}
+}
+
+func TestExcludedToolsValidated(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+	config.ExcludedTools = []string{"click_at", "format_disk"}
+	if err := config.Validate(context.Background()); err == nil || !strings.Contains(err.Error(), "format_disk") {
+		t.Errorf("error = %v, want the unknown tool refused", err)
+	}
+}
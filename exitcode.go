@@ -0,0 +1,49 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+)
+
+// Process exit codes for programs wrapping a run, see ExitCodeFor
+const (
+	ExitSuccess    = 0 // The model finished the task
+	ExitIncomplete = 2 // The run stopped early: turn, action, time or token limits, or cancellation
+	ExitTransient  = 3 // A temporary infrastructure failure, such as rate limits or a dead browser; retrying may succeed
+	ExitPermanent  = 4 // A failure retrying will not fix, such as bad credentials, an unknown model or a bad config
+	ExitPolicy     = 5 // The API blocked the prompt, or an action was refused by policy
+)
+
+// ExitCodeFor returns the exit code for an event ending a run, so embedders report outcomes consistently.
+// Events that do not end a run, and the CompletionEvent, map to ExitSuccess; with the event loop, keep the last
+// non-zero code seen. For Run, pass RunResult.Final.
+func ExitCodeFor(event Event) int {
+	switch e := event.(type) {
+	case MaxTurnsExceededEvent, DeadlineEvent, ActionLimitExceededEvent, BudgetExceededEvent:
+		return ExitIncomplete
+	case BlockedEvent:
+		return ExitPolicy
+	case ErrorEvent:
+		return exitCodeForError(e.Err)
+	default:
+		return ExitSuccess
+	}
+}
+
+// exitCodeForError classifies the error ending a run
+func exitCodeForError(err error) int {
+	var toolErr *ToolError
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrActionLimitExceeded):
+		return ExitIncomplete
+	case errors.Is(err, errSafetyDenied), errors.As(err, &toolErr) && toolErr.Code == ToolErrorPolicyDenied:
+		return ExitPolicy
+	case isRetryableAPIError(err), errors.Is(err, ErrEmptyResponse), errors.Is(err, ErrSessionDead),
+		errors.As(err, &toolErr) && (toolErr.Retryable || toolErr.Code == ToolErrorSessionDead || toolErr.Code == ToolErrorTimeout):
+		return ExitTransient
+	default:
+		return ExitPermanent
+	}
+}
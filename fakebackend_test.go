@@ -0,0 +1,275 @@
+package geminirod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// fakeBackend serves the Gemini API endpoints used by the loop from scripted replies, recording each request.
+// Once the script is used up, every call gets a final text answer.
+type fakeBackend struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	replies  []fakeReply
+	requests []fakeRequest
+	models   []string // Models whose get requests were served
+}
+
+// fakeReply is the answer to one generateContent call
+type fakeReply struct {
+	Response *genai.GenerateContentResponse   // Sent if Status is 0 or 200
+	Chunks   []*genai.GenerateContentResponse // Sent as server-sent events to streaming calls, instead of Response
+	Status   int                              // HTTP status of an error reply
+	Message  string                           // Message of an error reply
+	Handle   func()                           // Called before the reply is sent, e.g. to block the call
+}
+
+// fakeRequest is the body of a generateContent call, as sent on the wire
+type fakeRequest struct {
+	Model             string
+	Stream            bool
+	Header            http.Header            `json:"-"`
+	Contents          []*genai.Content       `json:"contents"`
+	SystemInstruction *genai.Content         `json:"systemInstruction"`
+	Tools             []map[string]any       `json:"tools"`
+	GenerationConfig  map[string]any         `json:"generationConfig"`
+	SafetySettings    []*genai.SafetySetting `json:"safetySettings"`
+	Labels            map[string]string      `json:"labels"`
+}
+
+// newFakeBackend starts a backend answering with replies, closed at the end of the test
+func newFakeBackend(t *testing.T, replies ...fakeReply) *fakeBackend {
+	t.Helper()
+	backend := &fakeBackend{replies: replies}
+	backend.server = httptest.NewServer(http.HandlerFunc(backend.serve))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+// client returns a genai client talking to the backend
+func (b *fakeBackend) client(t *testing.T) *genai.Client {
+	t.Helper()
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test-key",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: b.server.URL},
+	})
+	if err != nil {
+		t.Fatalf("creating the genai client: %v", err)
+	}
+	return client
+}
+
+// calls returns the generateContent requests received so far
+func (b *fakeBackend) calls() []fakeRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeRequest(nil), b.requests...)
+}
+
+func (b *fakeBackend) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1beta/")
+	model, method, found := strings.Cut(path, ":")
+	if !found {
+		// models.get, used by preflight
+		b.mu.Lock()
+		b.models = append(b.models, model)
+		b.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"name": model})
+		return
+	}
+
+	request := fakeRequest{
+		Model:  strings.TrimPrefix(model, "models/"),
+		Stream: method == "streamGenerateContent",
+		Header: r.Header.Clone(),
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiErrorBody(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	b.mu.Lock()
+	b.requests = append(b.requests, request)
+	reply := fakeReply{Response: textResponse("done")}
+	if len(b.replies) > 0 {
+		reply = b.replies[0]
+		b.replies = b.replies[1:]
+	}
+	b.mu.Unlock()
+
+	if reply.Handle != nil {
+		reply.Handle()
+	}
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		writeJSON(w, reply.Status, apiErrorBody(reply.Status, reply.Message))
+		return
+	}
+	if !request.Stream {
+		writeJSON(w, http.StatusOK, reply.Response)
+		return
+	}
+
+	chunks := reply.Chunks
+	if chunks == nil {
+		chunks = []*genai.GenerateContentResponse{reply.Response}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func apiErrorBody(status int, message string) map[string]any {
+	return map[string]any{"error": map[string]any{
+		"code":    status,
+		"message": message,
+		"status":  http.StatusText(status),
+	}}
+}
+
+// textResponse is a model turn answering with text only, ending the run
+func textResponse(text string) *genai.GenerateContentResponse {
+	return modelResponse(genai.NewPartFromText(text))
+}
+
+// callResponse is a model turn calling the given functions
+func callResponse(calls ...*genai.FunctionCall) *genai.GenerateContentResponse {
+	parts := make([]*genai.Part, len(calls))
+	for i, call := range calls {
+		parts[i] = &genai.Part{FunctionCall: call}
+	}
+	return modelResponse(parts...)
+}
+
+// modelResponse is a model turn made of parts
+func modelResponse(parts ...*genai.Part) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{
+			Content:      &genai.Content{Role: genai.RoleModel, Parts: parts},
+			FinishReason: genai.FinishReasonStop,
+		}},
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     100,
+			CandidatesTokenCount: 10,
+			TotalTokenCount:      110,
+		},
+	}
+}
+
+// call is a function call with args
+func call(name string, args map[string]any) *genai.FunctionCall {
+	if args == nil {
+		args = map[string]any{}
+	}
+	return &genai.FunctionCall{Name: name, Args: args}
+}
+
+// testConfig returns a config running against backend and session, skipping the startup checks and the
+// initial screenshot so tests only see the calls they script
+func testConfig(t *testing.T, backend *fakeBackend) (StartLoopConfig, *fakeBrowser) {
+	t.Helper()
+	session, browser := newFakeSession(t)
+	return StartLoopConfig{
+		GenaiClient:           backend.client(t),
+		ComputerUseSession:    session,
+		Prompt:                "test task",
+		SkipPreflight:         true,
+		SkipInitialScreenshot: true,
+		MaxRetries:            -1,
+		Clock:                 newFakeClock(),
+	}, browser
+}
+
+// collectEvents runs a loop and returns its events, answering custom calls with answer if it is not nil
+func collectEvents(t *testing.T, ctx context.Context, config StartLoopConfig, answer func(*FunctionCall)) []Event {
+	t.Helper()
+	var events []Event
+	for event := range StartLoop(ctx, config) {
+		events = append(events, event)
+		switch e := event.(type) {
+		case ProgressEvent:
+			for _, fc := range e.FunctionCalls {
+				if fc.NeedsAction() && answer != nil {
+					answer(fc)
+				}
+			}
+		case SafetyConfirmationEvent:
+			e.Approve()
+		}
+	}
+	return events
+}
+
+// eventsOf returns the events of type T
+func eventsOf[T Event](events []Event) []T {
+	var matching []T
+	for _, event := range events {
+		if e, ok := event.(T); ok {
+			matching = append(matching, e)
+		}
+	}
+	return matching
+}
+
+// finalError returns the error of the run's ErrorEvent, nil if there is none
+func finalError(events []Event) error {
+	errs := eventsOf[ErrorEvent](events)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[len(errs)-1].Err
+}
+
+// functionResponses returns the function responses of a content, by function name
+func functionResponses(content *genai.Content) map[string]*genai.FunctionResponse {
+	responses := make(map[string]*genai.FunctionResponse)
+	for _, part := range content.Parts {
+		if part.FunctionResponse != nil {
+			responses[part.FunctionResponse.Name] = part.FunctionResponse
+		}
+	}
+	return responses
+}
+
+// hasText reports whether one of the contents has a text part containing text
+func hasText(contents []*genai.Content, text string) bool {
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			if strings.Contains(part.Text, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// declaredFunctions returns the names of the functions declared in a request
+func (r fakeRequest) declaredFunctions() []string {
+	var names []string
+	for _, tool := range r.Tools {
+		declarations, _ := tool["functionDeclarations"].([]any)
+		for _, declaration := range declarations {
+			fields, _ := declaration.(map[string]any)
+			name, _ := fields["name"].(string)
+			names = append(names, name)
+		}
+	}
+	return names
+}
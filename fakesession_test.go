@@ -0,0 +1,284 @@
+package geminirod
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBrowser is a BrowserSession without a browser. It records every call, keeps a navigation history
+// for go_back and go_forward, and fails the methods tests ask it to.
+type fakeBrowser struct {
+	mu         sync.Mutex
+	history    []string // Visited URLs, the page is history[current]
+	current    int
+	screenshot []byte
+	calls      []fakeCall
+	failures   map[string]error // By method, e.g. "ClickAt"
+	failWhen   func(call fakeCall) error
+}
+
+// fakeCall is a recorded BrowserSession call
+type fakeCall struct {
+	Method string
+	Args   []any
+}
+
+// fakeSearchURL is the page the fake's Search opens
+const fakeSearchURL = "https://search.example/"
+
+// newFakeSession returns a fakeBrowser on https://start.example/, both as the session and for inspection
+func newFakeSession(t *testing.T) (BrowserSession, *fakeBrowser) {
+	t.Helper()
+	fake := &fakeBrowser{
+		history:    []string{"https://start.example/"},
+		screenshot: testPNG(t, 100, 100),
+		failures:   make(map[string]error),
+	}
+	return fake, fake
+}
+
+// testPNG returns a PNG image of the given size
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// call records a call and returns the failure set for it, if any
+func (b *fakeBrowser) call(method string, args ...any) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	call := fakeCall{Method: method, Args: args}
+	b.calls = append(b.calls, call)
+	if err := b.failures[method]; err != nil {
+		return err
+	}
+	if b.failWhen != nil {
+		return b.failWhen(call)
+	}
+	return nil
+}
+
+func (b *fakeBrowser) GetURL() (string, error) {
+	if err := b.call("GetURL"); err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.history[b.current], nil
+}
+
+func (b *fakeBrowser) Screenshot() ([]byte, error) {
+	if err := b.call("Screenshot"); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.screenshot, nil
+}
+
+func (b *fakeBrowser) Close() error {
+	return b.call("Close")
+}
+
+func (b *fakeBrowser) Navigate(url string) error {
+	if err := b.call("Navigate", url); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.history = append(b.history[:b.current+1], url)
+	b.current++
+	return nil
+}
+
+func (b *fakeBrowser) GoBack() error {
+	if err := b.call("GoBack"); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = max(b.current-1, 0)
+	return nil
+}
+
+func (b *fakeBrowser) GoForward() error {
+	if err := b.call("GoForward"); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = min(b.current+1, len(b.history)-1)
+	return nil
+}
+
+func (b *fakeBrowser) Search() error {
+	return b.Navigate(fakeSearchURL)
+}
+
+func (b *fakeBrowser) ClickAt(x, y int) error {
+	return b.call("ClickAt", x, y)
+}
+
+func (b *fakeBrowser) HoverAt(x, y int) error {
+	return b.call("HoverAt", x, y)
+}
+
+func (b *fakeBrowser) TypeTextAt(x, y int, text string, clearBefore, pressEnter bool) error {
+	return b.call("TypeTextAt", x, y, text, clearBefore, pressEnter)
+}
+
+func (b *fakeBrowser) Key(keys ...string) error {
+	return b.call("Key", strings.Join(keys, "+"))
+}
+
+func (b *fakeBrowser) Scroll(direction string, amount int) error {
+	return b.call("Scroll", direction, amount)
+}
+
+func (b *fakeBrowser) ScrollAt(x, y int, direction string, magnitude int) error {
+	return b.call("ScrollAt", x, y, direction, magnitude)
+}
+
+func (b *fakeBrowser) ClickDrag(fromX, fromY, toX, toY int) error {
+	return b.call("ClickDrag", fromX, fromY, toX, toY)
+}
+
+func (b *fakeBrowser) MouseDown(x, y int) error {
+	return b.call("MouseDown", x, y)
+}
+
+func (b *fakeBrowser) MouseUp(x, y int) error {
+	return b.call("MouseUp", x, y)
+}
+
+func (b *fakeBrowser) MouseMove(x, y int) error {
+	return b.call("MouseMove", x, y)
+}
+
+// fail makes calls of a method return err, nil to let them succeed again
+func (b *fakeBrowser) fail(method string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.failures, method)
+		return
+	}
+	b.failures[method] = err
+}
+
+// failCalls makes the calls for which check returns an error fail with it
+func (b *fakeBrowser) failCalls(check func(call fakeCall) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failWhen = check
+}
+
+// setURL changes the URL reported for the page
+func (b *fakeBrowser) setURL(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.history[b.current] = url
+}
+
+// setScreenshot changes the image returned for screenshots
+func (b *fakeBrowser) setScreenshot(screenshot []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.screenshot = screenshot
+}
+
+// recorded returns the calls made so far to one of methods, all calls if none are given
+func (b *fakeBrowser) recorded(methods ...string) []fakeCall {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var calls []fakeCall
+	for _, call := range b.calls {
+		if len(methods) == 0 || slices.Contains(methods, call.Method) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// actions returns the methods called so far that act on the page, leaving out GetURL, Screenshot and Close
+func (b *fakeBrowser) actions() []string {
+	var methods []string
+	for _, call := range b.recorded() {
+		switch call.Method {
+		case "GetURL", "Screenshot", "Close":
+		default:
+			methods = append(methods, call.Method)
+		}
+	}
+	return methods
+}
+
+// typedText returns the text typed so far
+func (b *fakeBrowser) typedText() string {
+	var text strings.Builder
+	for _, call := range b.recorded("TypeTextAt") {
+		typed, _ := call.Args[2].(string)
+		text.WriteString(typed)
+	}
+	return text.String()
+}
+
+// fakeClock is a Clock whose Sleep returns at once, advancing the time
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.slept = append(c.slept, d)
+	return nil
+}
+
+// advance moves the time forward
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// sleeps returns the durations slept so far
+func (c *fakeClock) sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.slept...)
+}
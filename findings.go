@@ -0,0 +1,52 @@
+package geminirod
+
+import (
+	"maps"
+
+	"google.golang.org/genai"
+)
+
+// findingToolName is the name of the intermediate findings tool offered to the model
+const findingToolName = "report_finding"
+
+// findingDeclaration declares the findings tool to the model
+var findingDeclaration = &genai.FunctionDeclaration{
+	Name: findingToolName,
+	Description: "Record an intermediate result as soon as you find it, e.g. a price on one of several sites. " +
+		"Reporting a key again replaces its value. This does not touch the browser and returns no screenshot.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"key":   {Type: genai.TypeString, Description: "Short identifier of the finding, e.g. \"price_site_a\"."},
+		"value": {Type: genai.TypeString, Description: "The finding itself."},
+		"note":  {Type: genai.TypeString, Description: "Optional context, such as where it was found."},
+	}, "key", "value"),
+}
+
+// Finding is an intermediate result reported by the model
+type Finding struct {
+	Value string
+	Note  string
+}
+
+// recordFinding stores the finding reported by a call and returns the event and the function response part
+func recordFinding(findings map[string]Finding, args map[string]any) (FindingEvent, *genai.Part, error) {
+	key, _ := args["key"].(string)
+	if key == "" {
+		return FindingEvent{}, nil, newToolError(ToolErrorInvalidArgument, "key argument must be a non-empty string")
+	}
+	value, ok := args["value"].(string)
+	if !ok {
+		return FindingEvent{}, nil, newToolError(ToolErrorInvalidArgument, "value argument must be a string")
+	}
+	note, _ := args["note"].(string)
+
+	findings[key] = Finding{Value: value, Note: note}
+	event := FindingEvent{
+		Key:      key,
+		Finding:  findings[key],
+		Findings: maps.Clone(findings),
+	}
+	part := genai.NewPartFromFunctionResponse(findingToolName, map[string]any{
+		"recorded": key,
+	})
+	return event, part, nil
+}
@@ -0,0 +1,96 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRecordFinding(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		want    Finding
+		wantErr bool
+	}{
+		{name: "with note", args: map[string]any{"key": "price", "value": "12 EUR", "note": "site A"}, want: Finding{Value: "12 EUR", Note: "site A"}},
+		{name: "without note", args: map[string]any{"key": "price", "value": "12 EUR"}, want: Finding{Value: "12 EUR"}},
+		{name: "empty value", args: map[string]any{"key": "price", "value": ""}, want: Finding{}},
+		{name: "missing key", args: map[string]any{"value": "12 EUR"}, wantErr: true},
+		{name: "value not a string", args: map[string]any{"key": "price", "value": 12.0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := make(map[string]Finding)
+			event, part, err := recordFinding(findings, tt.args)
+			if tt.wantErr {
+				var toolErr *ToolError
+				if !errors.As(err, &toolErr) || toolErr.Code != ToolErrorInvalidArgument {
+					t.Fatalf("error = %v, want an invalid argument ToolError", err)
+				}
+				if len(findings) != 0 {
+					t.Errorf("findings = %v, want nothing recorded", findings)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("recordFinding failed: %v", err)
+			}
+			if findings["price"] != tt.want || event.Key != "price" || event.Finding != tt.want {
+				t.Errorf("recorded %+v, event %+v, want %+v under price", findings, event, tt.want)
+			}
+			if part.FunctionResponse.Name != findingToolName || part.FunctionResponse.Response["recorded"] != "price" {
+				t.Errorf("response = %s %v, want the key acknowledged", part.FunctionResponse.Name, part.FunctionResponse.Response)
+			}
+		})
+	}
+}
+
+func TestFindingsInLoop(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(
+			call(findingToolName, map[string]any{"key": "price_a", "value": "12 EUR"}),
+			call(findingToolName, map[string]any{"key": "price_b", "value": "15 EUR"}),
+		)},
+		fakeReply{Response: callResponse(call(findingToolName, map[string]any{"key": "price_a", "value": "11 EUR", "note": "after discount"}))},
+	)
+	config, browser := testConfig(t, backend)
+	config.EnableFindings = true
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if !slices.Contains(backend.calls()[0].declaredFunctions(), findingToolName) {
+		t.Errorf("%s not declared with EnableFindings", findingToolName)
+	}
+	if actions := browser.actions(); len(actions) != 0 {
+		t.Errorf("browser actions = %v, want none for findings", actions)
+	}
+
+	found := eventsOf[FindingEvent](events)
+	if len(found) != 3 {
+		t.Fatalf("got %d FindingEvents, want 3", len(found))
+	}
+	// Each event carries the findings so far, a key reported again replaces its value
+	if len(found[0].Findings) != 1 || len(found[1].Findings) != 2 {
+		t.Errorf("findings = %v then %v, want them accumulated", found[0].Findings, found[1].Findings)
+	}
+	last := found[2]
+	if last.Findings["price_a"] != (Finding{Value: "11 EUR", Note: "after discount"}) || last.Findings["price_b"].Value != "15 EUR" {
+		t.Errorf("findings = %v, want price_a replaced and price_b kept", last.Findings)
+	}
+	if found[0].Findings["price_a"].Value != "12 EUR" {
+		t.Errorf("first event findings = %v, changed by a later report", found[0].Findings)
+	}
+}
+
+func TestFindingsNotOfferedByDefault(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+	collectEvents(t, context.Background(), config, nil)
+	if slices.Contains(backend.calls()[0].declaredFunctions(), findingToolName) {
+		t.Errorf("%s declared without EnableFindings", findingToolName)
+	}
+}
@@ -0,0 +1,91 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTurnGateLockstep(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("open_web_browser", nil))},
+		fakeReply{Response: textResponse("done")},
+	)
+	config, _ := testConfig(t, backend)
+	gate := make(chan struct{})
+	config.TurnGate = gate
+
+	var waited []int
+	for event := range StartLoop(context.Background(), config) {
+		switch e := event.(type) {
+		case WaitingForGateEvent:
+			waited = append(waited, e.Turn)
+			if got := len(backend.calls()); got != e.Turn-1 {
+				t.Errorf("turn %d waiting after %d model calls, want %d", e.Turn, got, e.Turn-1)
+			}
+			gate <- struct{}{}
+		case ErrorEvent:
+			t.Fatalf("run failed: %v", e.Err)
+		}
+	}
+	if len(waited) != 2 || waited[0] != 1 || waited[1] != 2 {
+		t.Errorf("waited for turns %v, want [1 2]", waited)
+	}
+}
+
+func TestTurnGateTickReadyAhead(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+	gate := make(chan struct{}, 1)
+	gate <- struct{}{}
+	config.TurnGate = gate
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := len(eventsOf[WaitingForGateEvent](events)); got != 0 {
+		t.Errorf("got %d WaitingForGateEvents, want none with a tick ready", got)
+	}
+}
+
+func TestTurnGateClosed(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("open_web_browser", nil))},
+	)
+	config, _ := testConfig(t, backend)
+	gate := make(chan struct{})
+	close(gate)
+	config.TurnGate = gate
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := len(backend.calls()); got != 2 {
+		t.Errorf("got %d model calls, want the run to go through a closed gate", got)
+	}
+}
+
+func TestTurnGateCancelled(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+	config.TurnGate = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var events []Event
+	for event := range StartLoop(ctx, config) {
+		events = append(events, event)
+		if _, ok := event.(WaitingForGateEvent); ok {
+			cancel()
+		}
+	}
+	// Events after the cancellation may be dropped, but an error can only be the cancellation
+	if err := finalError(events); err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if got := len(backend.calls()); got != 0 {
+		t.Errorf("got %d model calls, want none", got)
+	}
+}
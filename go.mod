@@ -4,6 +4,7 @@ go 1.25.3
 
 require (
 	github.com/PeronGH/computer-use-lib v0.0.0-20251019230448-f96c2c5bee7a
+	github.com/go-rod/rod v0.116.2
 	google.golang.org/genai v1.31.0
 )
 
@@ -11,7 +12,6 @@ require (
 	cloud.google.com/go v0.116.0 // indirect
 	cloud.google.com/go/auth v0.9.3 // indirect
 	cloud.google.com/go/compute/metadata v0.5.0 // indirect
-	github.com/go-rod/rod v0.116.2 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/google/s2a-go v0.1.8 // indirect
@@ -0,0 +1,155 @@
+package geminirod
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// LoopGroup runs several loops and shuts them down together, e.g. when a service receives SIGTERM.
+// The zero value is ready to use.
+type LoopGroup struct {
+	mu      sync.Mutex
+	members []*groupMember
+}
+
+// GroupedLoop describes a loop started in a LoopGroup
+type GroupedLoop struct {
+	Name   string
+	Config StartLoopConfig
+
+	// Handle receives every event of the run on the loop's own goroutine, and must answer the calls needing action
+	// and the safety confirmations. Nil = custom tool calls are rejected and safety confirmations denied
+	Handle func(Event)
+
+	// OnFinish is called once the run has ended, e.g. to flush its transcript, before the session is closed
+	OnFinish func(LoopReport)
+
+	// Close Config.ComputerUseSession after OnFinish
+	CloseSession bool
+}
+
+// LoopReport describes how a loop of a LoopGroup ended
+type LoopReport struct {
+	Name       string
+	Completion CompletionEvent
+	Final      Event // Event that ended the run early, such as an ErrorEvent, see RunResult.Final. Nil if the model finished
+	Forced     bool  // The run did not stop within the grace period and was cancelled
+	CloseErr   error // Error closing the session, if CloseSession is set
+}
+
+type groupMember struct {
+	loop   *Loop
+	cancel context.CancelFunc
+	forced atomic.Bool
+	done   chan struct{} // Closed once OnFinish returned and the session is closed
+	report LoopReport    // Written by the loop's goroutine, read after done
+}
+
+// Go starts a managed loop in the group and returns its handle. Its events are consumed by the group,
+// see GroupedLoop.Handle; do not read Events from the returned handle.
+func (g *LoopGroup) Go(ctx context.Context, spec GroupedLoop) *Loop {
+	ctx, cancel := context.WithCancel(ctx)
+	member := &groupMember{
+		loop:   StartLoopManaged(ctx, spec.Config),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		report: LoopReport{Name: spec.Name},
+	}
+
+	g.mu.Lock()
+	g.members = append(g.members, member)
+	g.mu.Unlock()
+
+	go func() {
+		defer close(member.done)
+		defer cancel()
+
+		for event := range member.loop.Events() {
+			switch e := event.(type) {
+			case CompletionEvent:
+				member.report.Completion = e
+			case ErrorEvent, MaxTurnsExceededEvent, DeadlineEvent, BlockedEvent, ActionLimitExceededEvent, BudgetExceededEvent:
+				member.report.Final = e
+			}
+
+			if spec.Handle != nil {
+				spec.Handle(event)
+				continue
+			}
+			switch e := event.(type) {
+			case ProgressEvent:
+				for _, fc := range e.FunctionCalls {
+					rejectUnhandledCall(fc)
+				}
+			case SafetyConfirmationEvent:
+				e.Deny()
+			}
+		}
+
+		// The transcript is flushed while the session is still open
+		member.report.Forced = member.forced.Load()
+		if spec.OnFinish != nil {
+			spec.OnFinish(member.report)
+		}
+		if spec.CloseSession && spec.Config.ComputerUseSession != nil {
+			member.report.CloseErr = spec.Config.ComputerUseSession.Close()
+		}
+	}()
+
+	return member.loop
+}
+
+// Wait waits for every loop started so far to end on its own and returns their reports, in start order
+func (g *LoopGroup) Wait() []LoopReport {
+	members := g.snapshot()
+	for _, member := range members {
+		<-member.done
+	}
+	return reports(members)
+}
+
+// ShutdownAll asks every loop to stop at the end of its current turn, waits for them until ctx is done,
+// then cancels the stragglers. It returns once every loop has finished, including OnFinish and closing its session,
+// with their reports in start order.
+func (g *LoopGroup) ShutdownAll(ctx context.Context) []LoopReport {
+	members := g.snapshot()
+	for _, member := range members {
+		member.loop.Stop()
+	}
+
+	for _, member := range members {
+		select {
+		case <-member.done:
+			continue
+		case <-ctx.Done():
+		}
+		// The grace period is over, unless this loop just finished
+		select {
+		case <-member.done:
+		default:
+			member.forced.Store(true)
+			member.cancel()
+		}
+	}
+
+	for _, member := range members {
+		<-member.done
+	}
+	return reports(members)
+}
+
+func (g *LoopGroup) snapshot() []*groupMember {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*groupMember(nil), g.members...)
+}
+
+// reports collects the reports of finished members
+func reports(members []*groupMember) []LoopReport {
+	result := make([]LoopReport, len(members))
+	for i, member := range members {
+		result[i] = member.report
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package geminirod
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoopGroupConcurrentRuns(t *testing.T) {
+	const runs = 4
+	var group LoopGroup
+	var mu sync.Mutex
+	closedAtFinish := make(map[string]int)
+	browsers := make(map[string]*fakeBrowser)
+
+	for i := range runs {
+		// Each run gets two custom calls no one handles in the same turn
+		backend := newFakeBackend(t,
+			fakeReply{Response: callResponse(call("query_table", nil), call("query_users", nil))},
+		)
+		config, browser := testConfig(t, backend)
+		name := fmt.Sprint("run", i)
+		browsers[name] = browser
+		group.Go(context.Background(), GroupedLoop{
+			Name:   name,
+			Config: config,
+			OnFinish: func(report LoopReport) {
+				mu.Lock()
+				defer mu.Unlock()
+				closedAtFinish[report.Name] = len(browser.recorded("Close"))
+			},
+			CloseSession: true,
+		})
+	}
+
+	var reports []LoopReport
+	withinTimeout(t, func() { reports = group.Wait() })
+	if len(reports) != runs {
+		t.Fatalf("got %d reports, want %d", len(reports), runs)
+	}
+	for i, report := range reports {
+		if report.Name != fmt.Sprint("run", i) {
+			t.Errorf("report %d is %s, want start order", i, report.Name)
+		}
+		final, ok := report.Final.(ErrorEvent)
+		if !ok || !strings.Contains(final.Err.Error(), "has no handler") {
+			t.Errorf("%s: Final = %v, want the unhandled call rejected", report.Name, report.Final)
+		}
+		if report.Forced {
+			t.Errorf("%s: reported as forced", report.Name)
+		}
+		if closedAtFinish[report.Name] != 0 {
+			t.Errorf("%s: session closed before OnFinish", report.Name)
+		}
+		if len(browsers[report.Name].recorded("Close")) == 0 {
+			t.Errorf("%s: session not closed after OnFinish", report.Name)
+		}
+	}
+}
+
+func TestLoopGroupShutdownAll(t *testing.T) {
+	var group LoopGroup
+
+	// Finishes its turn and stops cooperatively
+	quick := newFakeBackend(t, fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))})
+	quickConfig, _ := testConfig(t, quick)
+	group.Go(context.Background(), GroupedLoop{Name: "quick", Config: quickConfig})
+
+	// Stuck in its model call until cancelled
+	release := make(chan struct{})
+	stuck := newFakeBackend(t, fakeReply{Handle: func() { <-release }})
+	t.Cleanup(func() { close(release) }) // Before the server closes
+	stuckConfig, _ := testConfig(t, stuck)
+	group.Go(context.Background(), GroupedLoop{Name: "stuck", Config: stuckConfig})
+
+	// Let both runs reach their model call
+	for len(quick.calls()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Long enough for the quick run, whose screenshot takes half a second
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var reports []LoopReport
+	withinTimeout(t, func() { reports = group.ShutdownAll(ctx) })
+
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2", len(reports))
+	}
+	if reports[0].Forced || reports[0].Final != nil {
+		t.Errorf("quick: Forced = %v, Final = %v, want a clean stop", reports[0].Forced, reports[0].Final)
+	}
+	if !reports[1].Forced {
+		t.Error("stuck: not reported as forced")
+	}
+}
@@ -0,0 +1,57 @@
+package geminirod
+
+import "context"
+
+// Handlers are the callbacks of StartLoopWithHandlers. Any of them may be nil.
+type Handlers struct {
+	// OnProgress receives each model turn and must answer the calls that need action.
+	// Nil = custom tool calls are rejected, ending the run
+	OnProgress func(ProgressEvent)
+	// OnError receives the error ending the run, which is also returned by StartLoopWithHandlers
+	OnError func(ErrorEvent)
+	// OnSafetyConfirmation must approve or deny the action. Nil = actions requiring confirmation are denied
+	OnSafetyConfirmation func(*SafetyConfirmationEvent)
+	// OnComplete receives the CompletionEvent, the last event of every run
+	OnComplete func(CompletionEvent)
+	// OnEvent receives every other event, such as WarningEvent or PruneEvent
+	OnEvent func(Event)
+}
+
+// StartLoopWithHandlers runs a loop to completion, calling handlers for its events in order on the calling goroutine.
+// It is a thin layer over StartLoop: the run behaves the same, only the event delivery differs.
+// The returned error is the one of the final ErrorEvent, if any.
+func StartLoopWithHandlers(ctx context.Context, config StartLoopConfig, handlers Handlers) error {
+	var runErr error
+	for event := range StartLoop(ctx, config) {
+		switch e := event.(type) {
+		case ProgressEvent:
+			if handlers.OnProgress != nil {
+				handlers.OnProgress(e)
+				continue
+			}
+			for _, fc := range e.FunctionCalls {
+				rejectUnhandledCall(fc)
+			}
+		case ErrorEvent:
+			runErr = e.Err
+			if handlers.OnError != nil {
+				handlers.OnError(e)
+			}
+		case SafetyConfirmationEvent:
+			if handlers.OnSafetyConfirmation != nil {
+				handlers.OnSafetyConfirmation(&e)
+			} else {
+				e.Deny()
+			}
+		case CompletionEvent:
+			if handlers.OnComplete != nil {
+				handlers.OnComplete(e)
+			}
+		default:
+			if handlers.OnEvent != nil {
+				handlers.OnEvent(event)
+			}
+		}
+	}
+	return runErr
+}
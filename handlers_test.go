@@ -0,0 +1,84 @@
+package geminirod
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStartLoopWithHandlersRejectsUnhandledCalls(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("query_table", nil), call("query_users", nil))},
+	)
+	config, _ := testConfig(t, backend)
+
+	var handled error
+	var completed bool
+	var err error
+	withinTimeout(t, func() {
+		err = StartLoopWithHandlers(context.Background(), config, Handlers{
+			OnError:    func(e ErrorEvent) { handled = e.Err },
+			OnComplete: func(CompletionEvent) { completed = true },
+		})
+	})
+	if err == nil || !strings.Contains(err.Error(), `"query_table" has no handler`) {
+		t.Fatalf("error = %v, want the first custom call rejected", err)
+	}
+	if handled != err || !completed {
+		t.Errorf("OnError got %v, OnComplete called = %v, want the returned error and the completion", handled, completed)
+	}
+}
+
+func TestStartLoopWithHandlersAnswersCalls(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("query_table", nil), call("query_users", nil))},
+	)
+	config, _ := testConfig(t, backend)
+
+	var order []string
+	err := StartLoopWithHandlers(context.Background(), config, Handlers{
+		OnProgress: func(e ProgressEvent) {
+			order = append(order, "progress")
+			// Answered from the last, which must not block the handler
+			for i := len(e.FunctionCalls) - 1; i >= 0; i-- {
+				e.FunctionCalls[i].Respond(map[string]any{"ok": true})
+			}
+		},
+		OnComplete: func(CompletionEvent) { order = append(order, "complete") },
+	})
+	if err != nil {
+		t.Fatalf("StartLoopWithHandlers: %v", err)
+	}
+	if strings.Join(order, ",") != "progress,progress,complete" {
+		t.Errorf("callbacks = %v, want both turns then the completion", order)
+	}
+}
+
+func TestStartLoopWithHandlersSafetyConfirmation(t *testing.T) {
+	args := map[string]any{"x": 10.0, "y": 10.0, "safety_decision": map[string]any{"decision": "require_confirmation", "explanation": "buying"}}
+	tests := []struct {
+		name        string
+		handler     func(*SafetyConfirmationEvent)
+		wantClicked bool
+	}{
+		{name: "denied by default"},
+		{name: "approved", handler: func(e *SafetyConfirmationEvent) { e.Approve() }, wantClicked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(call("click_at", args))})
+			config, browser := testConfig(t, backend)
+
+			var err error
+			withinTimeout(t, func() {
+				err = StartLoopWithHandlers(context.Background(), config, Handlers{OnSafetyConfirmation: tt.handler})
+			})
+			if err != nil {
+				t.Fatalf("StartLoopWithHandlers: %v", err)
+			}
+			if clicked := len(browser.recorded("ClickAt")) > 0; clicked != tt.wantClicked {
+				t.Errorf("clicked = %v, want %v", clicked, tt.wantClicked)
+			}
+		})
+	}
+}
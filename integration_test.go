@@ -0,0 +1,141 @@
+//go:build integration
+
+// Integration tests run the built-in tools against a real headless browser and the fixtures of internal/testpages.
+// They need Chrome or Chromium, which rod downloads if none is found: go test -tags integration ./...
+
+package geminirod
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	computeruse "github.com/PeronGH/computer-use-lib"
+	"github.com/PeronGH/gemini-rod/internal/testpages"
+)
+
+// openFixture starts a browser on a fixture page, closed at the end of the test
+func openFixture(t *testing.T, page string) (*computeruse.Session, *testpages.Server) {
+	t.Helper()
+	server := testpages.Start()
+	t.Cleanup(server.Close)
+
+	session, _, err := NewBrowserSession(context.Background(), BrowserConfig{
+		Width:      1000,
+		Height:     1000,
+		InitialURL: server.PageURL(page),
+	})
+	if err != nil {
+		t.Fatalf("starting the browser: %v", err)
+	}
+	t.Cleanup(func() { _ = session.Close() })
+	return session, server
+}
+
+// execute runs a built-in call and returns the page's URL fragment afterwards
+func execute(t *testing.T, session *computeruse.Session, name string, args map[string]any) string {
+	t.Helper()
+	if _, _, err := ExecuteBuiltIn(context.Background(), session, call(name, args)); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return fragment(t, session)
+}
+
+// fragment returns the URL fragment of the current page, where the fixtures report what happened
+func fragment(t *testing.T, session *computeruse.Session) string {
+	t.Helper()
+	current, err := session.GetURL()
+	if err != nil {
+		t.Fatalf("reading the URL: %v", err)
+	}
+	parsed, err := url.Parse(current)
+	if err != nil {
+		t.Fatalf("parsing the URL %q: %v", current, err)
+	}
+	return parsed.Fragment
+}
+
+func TestIntegrationClick(t *testing.T) {
+	session, _ := openFixture(t, testpages.Click)
+	if got := execute(t, session, "click_at", map[string]any{"x": 500.0, "y": 500.0}); got != "clicked" {
+		t.Errorf("fragment = %q, want clicked", got)
+	}
+}
+
+func TestIntegrationType(t *testing.T) {
+	session, _ := openFixture(t, testpages.Type)
+	got := execute(t, session, "type_text_at", map[string]any{"x": 500.0, "y": 500.0, "text": "hello world"})
+	if got != "typed=hello%20world" {
+		t.Errorf("fragment = %q, want the prefilled text replaced and the form submitted", got)
+	}
+}
+
+func TestIntegrationHover(t *testing.T) {
+	session, _ := openFixture(t, testpages.Hover)
+	if got := execute(t, session, "hover_at", map[string]any{"x": 500.0, "y": 250.0}); got != "hovered" {
+		t.Errorf("fragment = %q, want hovered", got)
+	}
+}
+
+// scrollOffsets parses the scroll fixture's "page=N,box=M" fragment
+func scrollOffsets(t *testing.T, fragment string) (page, box int) {
+	t.Helper()
+	for field := range strings.SplitSeq(fragment, ",") {
+		key, value, _ := strings.Cut(field, "=")
+		n, _ := strconv.Atoi(value)
+		switch key {
+		case "page":
+			page = n
+		case "box":
+			box = n
+		}
+	}
+	return page, box
+}
+
+func TestIntegrationScroll(t *testing.T) {
+	session, _ := openFixture(t, testpages.Scroll)
+
+	page, box := scrollOffsets(t, execute(t, session, "scroll_document", map[string]any{"direction": "down"}))
+	if page == 0 || box != 0 {
+		t.Errorf("after scroll_document: page = %d, box = %d, want only the page scrolled", page, box)
+	}
+
+	_, box = scrollOffsets(t, execute(t, session, "scroll_at", map[string]any{"x": 750.0, "y": 350.0, "direction": "down", "magnitude": 300.0}))
+	if box == 0 {
+		t.Error("after scroll_at over the container: box not scrolled")
+	}
+}
+
+func TestIntegrationDrag(t *testing.T) {
+	session, _ := openFixture(t, testpages.Drag)
+	// The handle starts at the left of a track spanning 10% to 90% of the viewport
+	got := execute(t, session, "drag_and_drop", map[string]any{
+		"x": 120.0, "y": 500.0, "destination_x": 500.0, "destination_y": 500.0, "steps": 5.0,
+	})
+	percent, err := strconv.Atoi(strings.TrimPrefix(got, "slider="))
+	if err != nil || percent < 45 || percent > 55 {
+		t.Errorf("fragment = %q, want the slider near 50%%", got)
+	}
+}
+
+func TestIntegrationNavigate(t *testing.T) {
+	session, server := openFixture(t, testpages.Navigate)
+
+	execute(t, session, "navigate", map[string]any{"url": server.PageURL(testpages.Click)})
+	if current, _ := session.GetURL(); current != server.PageURL(testpages.Click) {
+		t.Errorf("after navigate: URL = %q, want the click fixture", current)
+	}
+
+	execute(t, session, "go_back", nil)
+	if current, _ := session.GetURL(); current != server.PageURL(testpages.Navigate) {
+		t.Errorf("after go_back: URL = %q, want the navigate fixture", current)
+	}
+
+	execute(t, session, "go_forward", nil)
+	if current, _ := session.GetURL(); current != server.PageURL(testpages.Click) {
+		t.Errorf("after go_forward: URL = %q, want the click fixture", current)
+	}
+}
@@ -0,0 +1,138 @@
+// Package respparse interprets model responses of the computer use API.
+// The API is in preview and its response shapes change; every assumption the loop makes about them lives here,
+// so an SDK or API change means adjusting one parser rather than debugging live runs.
+package respparse
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// SafetyDecisionField is the function call argument carrying the safety decision of an action
+const SafetyDecisionField = "safety_decision"
+
+// DecisionRequireConfirmation is the safety decision asking the user to confirm an action before it is executed
+const DecisionRequireConfirmation = "require_confirmation"
+
+// PromptBlocked reports whether the API refused to answer because of the prompt
+func PromptBlocked(resp *genai.GenerateContentResponse) bool {
+	return resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" &&
+		resp.PromptFeedback.BlockReason != genai.BlockedReasonUnspecified
+}
+
+// Empty reports whether resp has neither function calls nor non-whitespace answer text
+func Empty(resp *genai.GenerateContentResponse) bool {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return true
+	}
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.FunctionCall != nil || (!part.Thought && strings.TrimSpace(part.Text) != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// Text returns the text of a model content, thought summaries included
+func Text(content *genai.Content) string {
+	if content == nil {
+		return ""
+	}
+	var text string
+	for _, part := range content.Parts {
+		if part.Text != "" {
+			text += part.Text
+		}
+	}
+	return text
+}
+
+// TextAndThoughts splits the text of a model content into answer text and thought summaries
+func TextAndThoughts(content *genai.Content) (text, thoughts string) {
+	if content == nil {
+		return "", ""
+	}
+	for _, part := range content.Parts {
+		if part.Thought {
+			thoughts += part.Text
+		} else {
+			text += part.Text
+		}
+	}
+	return text, thoughts
+}
+
+// SafetyDecision returns the explanation of a call's safety decision and whether it requires confirmation.
+// The decision arrives as {"safety_decision": {"decision": "require_confirmation", "explanation": "..."}}.
+func SafetyDecision(args map[string]any) (string, bool) {
+	decision, ok := args[SafetyDecisionField].(map[string]any)
+	if !ok {
+		return "", false
+	}
+	if kind, _ := decision["decision"].(string); kind != DecisionRequireConfirmation {
+		return "", false
+	}
+	explanation, _ := decision["explanation"].(string)
+	return explanation, true
+}
+
+// Warnings describes what in resp the parsers do not recognize, an early sign that the API changed.
+// Nothing is returned for a response of a known shape.
+func Warnings(resp *genai.GenerateContentResponse) []string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return nil
+	}
+
+	var warnings []string
+	for i, part := range resp.Candidates[0].Content.Parts {
+		if kind := unexpectedPartKind(part); kind != "" {
+			warnings = append(warnings, fmt.Sprintf("part %d: unexpected %s in a model turn", i, kind))
+		}
+		if part.FunctionCall == nil {
+			continue
+		}
+		if part.FunctionCall.Name == "" {
+			warnings = append(warnings, fmt.Sprintf("part %d: function call without a name", i))
+		}
+		if warning := safetyDecisionWarning(part.FunctionCall.Args); warning != "" {
+			warnings = append(warnings, fmt.Sprintf("part %d: %s call: %s", i, part.FunctionCall.Name, warning))
+		}
+	}
+	return warnings
+}
+
+// unexpectedPartKind names the content of a model part other than text, thoughts and function calls, if any
+func unexpectedPartKind(part *genai.Part) string {
+	switch {
+	case part.FunctionResponse != nil:
+		return "function response"
+	case part.InlineData != nil:
+		return "inline data"
+	case part.FileData != nil:
+		return "file data"
+	case part.ExecutableCode != nil:
+		return "executable code"
+	case part.CodeExecutionResult != nil:
+		return "code execution result"
+	default:
+		return ""
+	}
+}
+
+// safetyDecisionWarning describes a safety decision of an unknown shape, empty if it is absent or known
+func safetyDecisionWarning(args map[string]any) string {
+	raw, ok := args[SafetyDecisionField]
+	if !ok {
+		return ""
+	}
+	decision, ok := raw.(map[string]any)
+	if !ok {
+		return fmt.Sprintf("%s is a %T, not an object", SafetyDecisionField, raw)
+	}
+	if _, ok := decision["decision"].(string); !ok {
+		return fmt.Sprintf("%s has no decision string", SafetyDecisionField)
+	}
+	return ""
+}
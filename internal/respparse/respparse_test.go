@@ -0,0 +1,158 @@
+package respparse
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// loadFixture decodes a response recorded from the API in testdata
+func loadFixture(t *testing.T, name string) *genai.GenerateContentResponse {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading the fixture: %v", err)
+	}
+	var resp genai.GenerateContentResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+	return &resp
+}
+
+// safety is the parsed safety decision of a function call
+type safety struct {
+	explanation string
+	required    bool
+}
+
+// fixtureCase holds what the parsers must make of a fixture
+type fixtureCase struct {
+	fixture  string
+	blocked  bool
+	empty    bool
+	text     string
+	thoughts string
+	safety   []safety // Per function call, in order
+	warnings []string
+}
+
+// fixtureCases lists the expectations of every fixture in testdata
+var fixtureCases = []fixtureCase{
+	{
+		fixture: "text_answer.json",
+		text:    "The cheapest flight is on Tuesday.",
+	},
+	{
+		fixture:  "function_calls.json",
+		text:     "Opening the search page.",
+		thoughts: "I will search for flights.",
+		safety:   []safety{{}, {}},
+	},
+	{
+		fixture: "safety_decision.json",
+		safety:  []safety{{explanation: "Clicking this button completes a purchase.", required: true}},
+	},
+	{
+		fixture: "safety_decision_regular.json",
+		safety:  []safety{{}},
+	},
+	{
+		fixture: "safety_decision_unknown_shape.json",
+		safety:  []safety{{}, {}},
+		warnings: []string{
+			"part 0: click_at call: safety_decision is a string, not an object",
+			"part 1: click_at call: safety_decision has no decision string",
+		},
+	},
+	{
+		fixture: "prompt_blocked.json",
+		blocked: true,
+		empty:   true,
+	},
+	{
+		fixture: "block_reason_unspecified.json",
+		text:    "Done.",
+	},
+	{
+		fixture: "no_candidates.json",
+		empty:   true,
+	},
+	{
+		fixture: "candidate_without_content.json",
+		empty:   true,
+	},
+	{
+		fixture:  "thoughts_only.json",
+		empty:    true,
+		text:     "  \n",
+		thoughts: "Considering where to click next.",
+	},
+	{
+		fixture: "unexpected_parts.json",
+		text:    "Continuing.",
+		safety:  []safety{{}},
+		warnings: []string{
+			"part 0: unexpected inline data in a model turn",
+			"part 1: unexpected executable code in a model turn",
+			"part 2: function call without a name",
+		},
+	},
+}
+
+func TestFixtures(t *testing.T) {
+	for _, tt := range fixtureCases {
+		t.Run(tt.fixture, func(t *testing.T) {
+			resp := loadFixture(t, tt.fixture)
+
+			if got := PromptBlocked(resp); got != tt.blocked {
+				t.Errorf("PromptBlocked = %v, want %v", got, tt.blocked)
+			}
+			if got := Empty(resp); got != tt.empty {
+				t.Errorf("Empty = %v, want %v", got, tt.empty)
+			}
+			if got := Warnings(resp); !slices.Equal(got, tt.warnings) {
+				t.Errorf("Warnings = %q, want %q", got, tt.warnings)
+			}
+
+			var content *genai.Content
+			if len(resp.Candidates) > 0 {
+				content = resp.Candidates[0].Content
+			}
+			text, thoughts := TextAndThoughts(content)
+			if text != tt.text || thoughts != tt.thoughts {
+				t.Errorf("TextAndThoughts = %q, %q, want %q, %q", text, thoughts, tt.text, tt.thoughts)
+			}
+			// The fixtures put thoughts before the answer
+			if got := Text(content); got != tt.thoughts+tt.text {
+				t.Errorf("Text = %q, want %q", got, tt.thoughts+tt.text)
+			}
+
+			var decisions []safety
+			for _, call := range resp.FunctionCalls() {
+				explanation, required := SafetyDecision(call.Args)
+				decisions = append(decisions, safety{explanation, required})
+			}
+			if !slices.Equal(decisions, tt.safety) {
+				t.Errorf("SafetyDecision = %+v, want %+v", decisions, tt.safety)
+			}
+		})
+	}
+}
+
+func TestEveryFixtureTested(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("listing the fixtures: %v", err)
+	}
+	for _, fixture := range fixtures {
+		name := filepath.Base(fixture)
+		if !slices.ContainsFunc(fixtureCases, func(c fixtureCase) bool { return c.fixture == name }) {
+			t.Errorf("%s has no expectations in fixtureCases", name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package testpages serves small self-contained HTML fixtures exercising the built-in tools.
+// Each page reports the outcome of the interaction in its title and URL fragment,
+// which the session can read back with GetURL or a screenshot.
+package testpages
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+)
+
+//go:embed pages/*.html
+var pages embed.FS
+
+// Fixture page names, one per built-in tool family
+const (
+	Click    = "click.html"
+	Type     = "type.html"
+	Scroll   = "scroll.html"
+	Hover    = "hover.html"
+	Drag     = "drag.html"
+	Navigate = "navigate.html"
+)
+
+// Server serves the fixture pages
+type Server struct {
+	*httptest.Server
+}
+
+// Start starts a server for the fixture pages. Close it when done.
+func Start() *Server {
+	root, err := fs.Sub(pages, "pages")
+	if err != nil {
+		panic(err) // The embedded directory always exists
+	}
+	return &Server{httptest.NewServer(http.FileServerFS(root))}
+}
+
+// PageURL returns the URL of a fixture page
+func (s *Server) PageURL(page string) string {
+	return s.URL + "/" + page
+}
@@ -0,0 +1,30 @@
+package geminirod
+
+import (
+	"context"
+	"iter"
+)
+
+// Events runs a loop and returns its events as an iterator, for use with range:
+//
+//	for event := range geminirod.Events(ctx, config) { ... }
+//
+// Breaking out of the range cancels the run and waits for it to finish, so nothing is left running.
+// A run that ends on its own yields every event, including a final ErrorEvent and the CompletionEvent.
+func Events(ctx context.Context, config StartLoopConfig) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		events := StartLoop(ctx, config)
+		for event := range events {
+			if !yield(event) {
+				// The loop gives up waiting for pending calls once cancelled, then closes the channel
+				cancel()
+				for range events {
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package geminirod
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrActionLimitExceeded is returned when an action would exceed MaxTypedCharacters or MaxClicks
+var ErrActionLimitExceeded = errors.New("action limit exceeded")
+
+// actionCounter tallies typed characters and clicks across a run and enforces the configured limits
+type actionCounter struct {
+	maxTypedCharacters int // 0 = unlimited
+	maxClicks          int // 0 = unlimited
+
+	typedCharacters int
+	clicks          int
+}
+
+// actionCost returns the typed characters and clicks an action would add
+func actionCost(name string, args map[string]any) (int, int) {
+	switch name {
+	case "type_text_at":
+		text, _ := args["text"].(string)
+		return utf8.RuneCountInString(text), 0
+	case "click_at":
+		return 0, 1
+	default:
+		return 0, 0
+	}
+}
+
+// admit checks that the action stays within the limits, before it is executed
+func (c *actionCounter) admit(name string, args map[string]any) error {
+	chars, clicks := actionCost(name, args)
+	if c.maxTypedCharacters > 0 && c.typedCharacters+chars > c.maxTypedCharacters {
+		return fmt.Errorf("%w: typing %d more characters would exceed MaxTypedCharacters (%d)", ErrActionLimitExceeded, chars, c.maxTypedCharacters)
+	}
+	if c.maxClicks > 0 && c.clicks+clicks > c.maxClicks {
+		return fmt.Errorf("%w: another click would exceed MaxClicks (%d)", ErrActionLimitExceeded, c.maxClicks)
+	}
+	return nil
+}
+
+// record counts an executed action
+func (c *actionCounter) record(name string, args map[string]any) {
+	chars, clicks := actionCost(name, args)
+	c.typedCharacters += chars
+	c.clicks += clicks
+}
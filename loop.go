@@ -2,252 +2,1110 @@ package geminirod
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
-	computeruse "github.com/PeronGH/computer-use-lib"
+	"github.com/PeronGH/gemini-rod/internal/respparse"
 	"google.golang.org/genai"
 )
 
 type StartLoopConfig struct {
 	GenaiClient            *genai.Client
-	ComputerUseSession     *computeruse.Session
+	ComputerUseSession     BrowserSession // Browser the tools act on, e.g. from NewBrowserSession
 	ExtraTools             []*genai.Tool
-	Prompt                 string
-	Model                  string // Default: "gemini-2.5-computer-use-preview-10-2025"
-	MaxRecentScreenshots   int    // Maximum number of recent screenshots to keep in history. Default: 3, -1 = unlimited
-	SkipSafetyConfirmation bool   // Skip safety confirmations, for test purposes only, may violate terms of service
+	Tools                  *ToolRegistry    // Custom tools executed by the loop, declared to the model automatically
+	Prompt                 string           // Task, or follow-up appended as a new user turn when InitialHistory is set
+	InitialHistory         []*genai.Content // Earlier conversation to continue, e.g. CompletionEvent.History. Not modified
+	Demonstrations         []*genai.Content // Few-shot demonstrations inserted after the prompt, see NewDemonstration
+	Model                  string           // Full name or alias such as "computer-use", see CanonicalModel. Default: DefaultModel
+	StrictModelCheck       bool             // Fail validation unless ListSupportedModels lists Model
+	MaxRecentScreenshots   int              // Maximum number of recent screenshots to keep in history. Default: 3, -1 = unlimited
+	SkipSafetyConfirmation bool             // Skip safety confirmations, for test purposes only, may violate terms of service
+	SkipPreflight          bool             // Skip validating credentials, model and browser session before the first turn
+	IncludeThoughts        *bool            // Request thought summaries. Default: true for computer-use models, false otherwise
+	RetainNoScreenshots    bool             // Strip screenshots from all past turns, keeping only the latest one. Overrides MaxRecentScreenshots
+	SkipInitialScreenshot  bool             // Do not attach a screenshot and the URL of the page to the prompt
+	PinInitialScreenshot   bool             // Never prune the screenshot attached to the prompt
+
+	MaxTurns                int // Maximum number of model calls, ending the run with a MaxTurnsExceededEvent. 0 = unlimited
+	MaxRetries              int // Retries of rate-limited or failed model calls (429, 5xx). Default: 3, -1 = no retries
+	MaxEmptyResponseRetries int // Retries when the model returns no text and no function calls. Default: 2, -1 = no retries
+
+	// Backoff between MaxRetries retries, a delay requested by the API takes precedence
+	InitialBackoff time.Duration // Default: 1s
+	MaxBackoff     time.Duration // Default: 30s
+
+	// Generation settings, nil = the model's default unless noted
+	Temperature     *float32 // Default: 0.2
+	TopP            *float32
+	MaxOutputTokens *int32
+	ThinkingBudget  *int32 // Tokens the model may spend thinking, for models that think
+	Seed            *int32 // Fixed seed for more reproducible runs
+
+	// ConfigureRequest adjusts the request config once it is built, e.g. to set SafetySettings, Labels or HTTPOptions.
+	// It is called once per run and its changes apply to every turn; the loop may still turn thoughts off for
+	// models without them. Removing the computer use tool ends the run with an error unless AllowRemovingBrowserTool is set
+	ConfigureRequest         func(*genai.GenerateContentConfig)
+	AllowRemovingBrowserTool bool
+
+	// Token budget, checked after each turn: once reached, the run ends with a BudgetExceededEvent
+	// before the next model call. 0 = unlimited
+	MaxTotalTokens   int
+	MaxEstimatedCost float64 // Limit on Usage.EstimatedCost at Pricing, requires Pricing
+	Pricing          Pricing
+
+	// Blunt guards against runaway behavior, 0 = unlimited
+	MaxTypedCharacters int // Maximum characters typed by type_text_at over the run
+	MaxClicks          int // Maximum clicks over the run
+
+	// Per-tool screenshot rules for built-in responses, keyed by tool name. Tools not listed use DefaultScreenshotRule.
+	// When set, the take_screenshot tool is offered so the model can ask for a skipped screenshot. Nil = always include
+	ScreenshotPolicy      map[string]ScreenshotRule
+	DefaultScreenshotRule ScreenshotRule
+
+	// Coordinate hints drawn on the screenshots sent to the model, off by default
+	ScreenshotGridOverlay GridOverlay
+
+	// Offer the report_finding tool, letting the model record intermediate results as FindingEvents
+	EnableFindings bool
+
+	// Offer the capture_element_screenshot tool, letting the model read small text in a native-resolution crop
+	EnableElementCapture bool
+
+	// Offer the undo_last_action tool, letting the model revert recent navigation and scrolling
+	EnableUndo bool
+
+	// Stream the model's output, sending its text as TextDeltaEvents while a turn is generated.
+	// Function calls are still only executed once the turn is complete
+	Stream bool
+
+	// Send a ScreenshotEvent with the screenshot taken after each executed built-in tool, e.g. for a live view
+	EmitScreenshots bool
+
+	// Waiting
+	DefaultWaitDuration time.Duration // Duration of wait_5_seconds when the model gives none. Default: 5s
+	MaxWaitDuration     time.Duration // Cap on the duration the model may ask wait_5_seconds for. Default: 30s
+
+	// Check built-in calls for arguments their declarations do not list, e.g. a misspelled "press_entr"
+	StrictArgs       bool
+	StrictArgsMode   StrictArgsMode // What to do with unknown arguments. Default: StrictArgsWarn
+	AllowUnknownArgs []string       // Built-in tools exempt from StrictArgs, for model-side fields newer than the declarations
+
+	// Built-in tool failures
+	ToolErrorMode            ToolErrorMode // Default: ToolErrorModeAbort, ending the run on the first failure
+	MaxConsecutiveToolErrors int           // Failures in a row reported to the model before the run ends. Default: 3
+
+	// Retry clicks, typing, key presses and drags on transient browser errors too. Navigation, scrolling and hovering
+	// are always retried; enabling this risks repeating actions such as submitting a form twice
+	RetryNonIdempotent bool
+
+	// Declare built-in tools as regular functions instead of the computer use tool, for models without native computer use
+	DeclareBuiltInsExplicitly bool
+
+	// Built-in tools the model may not use, e.g. "drag_and_drop", see BuiltInToolNames. The model is told not to use them
+	// and calls to them are answered with an error instead of being executed
+	ExcludedTools []string
+
+	// Handlers replacing built-in tools by name, e.g. a navigate enforcing a domain allowlist. Responses still get
+	// the screenshot and the page URL. Wrap BuiltInToolHandler to keep the default behavior around extra checks
+	ToolOverrides map[string]ToolHandler
+
+	// Wrappers around every built-in tool execution, overrides included, the first one outermost.
+	// See LoggingMiddleware and DelayMiddleware
+	ToolMiddleware []ToolMiddleware
+
+	FunctionResponseRole string // Role of the turns carrying function responses, for backends expecting e.g. "function". Default: "user"
+
+	// Tool batching
+	ReadOnlyTools         []string // Names of ExtraTools functions without side effects, such as lookups
+	EncourageToolBatching bool     // Tell the model it may call several read-only tools in one turn, saving turns
+
+	ResponseLanguage string // Language of the final answer, e.g. "Japanese". Browsing behavior is unaffected
+
+	// Standing instructions sent as the system instruction of every request, e.g. "never log into accounts".
+	// Instructions implied by other fields, such as ResponseLanguage, follow it
+	SystemInstruction string
+
+	// Scrolling
+	CoordinateSpace        CoordinateSpace // Viewport metadata from NewBrowserSession, used to report scrolled pixels
+	ScrollDocumentFraction float64         // Default scroll_document distance as a fraction of the viewport, 0 = page up/down. Needs CoordinateSpace
+	ScrollOverlap          float64         // Fraction of the previous view kept visible by a default scroll_document
+
+	// Report sensitive arguments such as typed text unmasked in events
+	LogSensitiveArgs bool
+
+	// Execute identical calls (same name and args) within one turn only once, answering the repeats as duplicates
+	DeduplicateIdenticalCallsInTurn bool
+
+	// Run deadline
+	RunDeadline    time.Time     // Stop the run on a turn boundary once this time has passed, zero = no deadline
+	MaxRunDuration time.Duration // Same as a RunDeadline this long after the run starts, the earlier of the two applies. 0 = no limit
+	WrapUpMargin   time.Duration // Ask the model to wrap up once less than this remains before RunDeadline
+	WrapUpMessage  string        // Message asking the model to wrap up. Default: a generic "finish up and report" message
+	WrapUpTurns    int           // Maximum number of turns after the wrap-up message. Default: 2
+
+	// Lockstep orchestration: before each model call the loop waits for a value on TurnGate, emitting a
+	// WaitingForGateEvent unless one is already available. Send ticks without blocking into a channel with a buffer of
+	// one to keep at most one tick that arrives mid-turn; close it to let the loop run freely. Nil = no gate
+	TurnGate <-chan struct{}
+
+	Clock Clock // Time source for deadlines, backoff and waits, e.g. to simulate time. Default: the system clock
+
+	// Emergency compaction: when a request exceeds the model's input limit, the loop drops all screenshots but
+	// the latest, summarizes the oldest turns with CompactionModel and retries once
+	DisableEmergencyCompaction bool
+	CompactionModel            string  // Model writing the summary. Default: DefaultCompactionModel
+	CompactionFraction         float64 // Share of the turns after the prompt and demonstrations that are summarized, up to 1. Default: 0.5
+
+	// Dynamic context
+	TurnContext      func(turn int) []string // Extra context appended to the user turn before each model call, nil = nothing added
+	PruneTurnContext bool                    // Remove previously injected turn context whenever new context is injected
 }
 
 func StartLoop(ctx context.Context, config StartLoopConfig) <-chan Event {
+	return startLoop(ctx, config, nil)
+}
+
+// startLoop runs the loop, controlled by a Loop handle if control is not nil
+func startLoop(ctx context.Context, config StartLoopConfig, control *loopControl) <-chan Event {
 	eventChan := make(chan Event)
 
 	// Apply defaults
 	if config.Model == "" {
-		config.Model = "gemini-2.5-computer-use-preview-10-2025"
+		config.Model = DefaultModel
 	}
-	if config.MaxRecentScreenshots == 0 {
+	config.Model = CanonicalModel(config.Model)
+	if config.RetainNoScreenshots {
+		// The latest turn's screenshot is the model's only view of the page, so it is always kept
+		config.MaxRecentScreenshots = 1
+	} else if config.MaxRecentScreenshots == 0 {
 		config.MaxRecentScreenshots = 3
 	}
+	if config.WrapUpMessage == "" {
+		config.WrapUpMessage = "You have limited time left. Finish up now and report what you have accomplished so far."
+	}
+	if config.WrapUpTurns == 0 {
+		config.WrapUpTurns = 2
+	}
+	if config.FunctionResponseRole == "" {
+		config.FunctionResponseRole = genai.RoleUser
+	}
+	config.Clock = clockOrSystem(config.Clock)
+	if config.MaxConsecutiveToolErrors == 0 {
+		config.MaxConsecutiveToolErrors = 3
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+	if config.InitialBackoff == 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.CompactionModel == "" {
+		config.CompactionModel = DefaultCompactionModel
+	}
+	if config.CompactionFraction <= 0 {
+		config.CompactionFraction = 0.5
+	}
+	config.CompactionFraction = min(config.CompactionFraction, 1)
+	if config.MaxEmptyResponseRetries == 0 {
+		config.MaxEmptyResponseRetries = 2
+	}
+	if config.Temperature == nil {
+		config.Temperature = genai.Ptr[float32](0.2)
+	}
+	if config.IncludeThoughts == nil {
+		config.IncludeThoughts = genai.Ptr(strings.Contains(config.Model, "computer-use"))
+	}
 
 	go func() {
 		defer close(eventChan)
 
-		history := []*genai.Content{
-			{
-				Role: genai.RoleUser,
-				Parts: []*genai.Part{
-					{Text: config.Prompt},
-				},
+		// Events are dropped once ctx is done and the consumer stops reading, so the goroutine always exits
+		emit := func(event Event) {
+			emitEvent(ctx, eventChan, event)
+		}
+
+		// Report the outcome of the run however it ends, with whatever history exists by then
+		startTime := config.Clock.Now()
+		var history []*genai.Content
+		var finalText string
+		completedTurns, callsExecuted := 0, 0
+		var usage, turnUsage Usage
+		actions := &actionCounter{
+			maxTypedCharacters: config.MaxTypedCharacters,
+			maxClicks:          config.MaxClicks,
+		}
+		defer func() {
+			control.publish(history)
+			emit(CompletionEvent{
+				Text:            finalText,
+				History:         history,
+				Turns:           completedTurns,
+				FunctionCalls:   callsExecuted,
+				TypedCharacters: actions.typedCharacters,
+				Clicks:          actions.clicks,
+				Duration:        config.Clock.Now().Sub(startTime),
+				Usage:           usage,
+			})
+		}()
+
+		// MaxRunDuration counts from the start of the run and ends it like RunDeadline
+		if config.MaxRunDuration > 0 {
+			if deadline := startTime.Add(config.MaxRunDuration); config.RunDeadline.IsZero() || deadline.Before(config.RunDeadline) {
+				config.RunDeadline = deadline
+			}
+		}
+
+		if err := config.Validate(ctx); err != nil {
+			emit(ErrorEvent{Err: err})
+			return
+		}
+
+		// Fail fast on bad credentials, unknown models or a dead browser
+		var checkedPage *pageState
+		if !config.SkipPreflight {
+			page, err := preflight(ctx, config.GenaiClient, config.Model, config.ComputerUseSession)
+			if err != nil {
+				emit(ErrorEvent{Err: err})
+				return
+			}
+			checkedPage = &page
+		}
+
+		// Continue an earlier conversation if given, copied since pruning modifies contents in place
+		history = cloneHistory(config.InitialHistory)
+		prompt := &genai.Content{
+			Role: genai.RoleUser,
+		}
+		if config.Prompt != "" || len(history) == 0 {
+			prompt.Parts = append(prompt.Parts, &genai.Part{Text: config.Prompt})
+		}
+		// Show the model the page up front, sparing it a first turn spent only looking.
+		// The page checked by preflight is reused rather than captured a second time.
+		if !config.SkipInitialScreenshot {
+			if checkedPage == nil {
+				page, err := capturePage(config.ComputerUseSession)
+				if err != nil {
+					emit(WarningEvent{Message: fmt.Sprintf("failed to capture the initial page, continuing without it: %v", err)})
+				} else {
+					checkedPage = &page
+				}
+			}
+			if checkedPage != nil {
+				prompt.Parts = append(prompt.Parts, initialPageParts(*checkedPage, config.ScreenshotGridOverlay)...)
+			}
+		}
+		promptIndex := -1
+		if len(prompt.Parts) > 0 {
+			promptIndex = len(history)
+			history = append(history, prompt)
+		}
+		// Demonstrations carry no screenshots, so pruning never touches them
+		history = append(history, config.Demonstrations...)
+		// Compaction only summarizes the turns of this run, never the contents they follow
+		compactionKeep := len(history)
+
+		browserTool := &genai.Tool{
+			ComputerUse: &genai.ComputerUse{
+				Environment:                 genai.EnvironmentBrowser,
+				ExcludedPredefinedFunctions: config.ExcludedTools,
 			},
 		}
+		if config.DeclareBuiltInsExplicitly {
+			// Built-ins are executed the same way, only the way they are declared changes
+			browserTool = &genai.Tool{
+				FunctionDeclarations: slices.DeleteFunc(BuiltInToolDeclarations(), func(declaration *genai.FunctionDeclaration) bool {
+					return slices.Contains(config.ExcludedTools, declaration.Name)
+				}),
+			}
+		}
 
 		generateContentConfig := &genai.GenerateContentConfig{
-			Temperature: genai.Ptr[float32](0.2),
-			Tools: append(config.ExtraTools, &genai.Tool{
-				ComputerUse: &genai.ComputerUse{
-					Environment: genai.EnvironmentBrowser,
-				},
-			}),
-			ThinkingConfig: &genai.ThinkingConfig{
-				IncludeThoughts: true,
-			},
+			Temperature: config.Temperature,
+			TopP:        config.TopP,
+			Seed:        config.Seed,
+			Tools:       append(config.ExtraTools, browserTool),
+		}
+		if config.MaxOutputTokens != nil {
+			generateContentConfig.MaxOutputTokens = *config.MaxOutputTokens
+		}
+		if instructions := systemInstructions(config); len(instructions) > 0 {
+			generateContentConfig.SystemInstruction = &genai.Content{
+				Parts: instructions,
+			}
 		}
 
+		opts := toolOptions{
+			coordinateSpace:    config.CoordinateSpace,
+			scrollFraction:     config.ScrollDocumentFraction,
+			scrollOverlap:      config.ScrollOverlap,
+			actions:            actions,
+			retryNonIdempotent: config.RetryNonIdempotent,
+			rejectUnknownArgs:  config.StrictArgs && config.StrictArgsMode == StrictArgsReject,
+			allowUnknownArgs:   config.AllowUnknownArgs,
+			waitDefault:        config.DefaultWaitDuration,
+			waitMax:            config.MaxWaitDuration,
+			gridOverlay:        config.ScreenshotGridOverlay,
+			clock:              config.Clock,
+		}
+		if config.ToolErrorMode == ToolErrorModeReport {
+			opts.toolErrors = &toolErrorBudget{max: config.MaxConsecutiveToolErrors}
+		}
+		opts.emitScreenshots = config.EmitScreenshots
+		opts.logSensitiveArgs = config.LogSensitiveArgs
+		opts.excludedTools = config.ExcludedTools
+		opts.overrides = config.ToolOverrides
+		opts.middleware = config.ToolMiddleware
+		opts.registry = config.Tools
+
+		// Let the model fetch screenshots the policy skipped
+		if config.ScreenshotPolicy != nil {
+			opts.screenshots = &screenshotPolicy{
+				rules:       config.ScreenshotPolicy,
+				defaultRule: config.DefaultScreenshotRule,
+			}
+			generateContentConfig.Tools = append(generateContentConfig.Tools, &genai.Tool{
+				FunctionDeclarations: []*genai.FunctionDeclaration{screenshotDeclaration},
+			})
+		}
+
+		// Offer undo of recent navigation and scrolling
+		var undo *undoStack
+		if config.EnableUndo {
+			undo = &undoStack{}
+			generateContentConfig.Tools = append(generateContentConfig.Tools, &genai.Tool{
+				FunctionDeclarations: []*genai.FunctionDeclaration{undoDeclaration},
+			})
+		}
+		// Offer crisp captures of small page regions
+		if config.EnableElementCapture {
+			opts.elementCapture = true
+			generateContentConfig.Tools = append(generateContentConfig.Tools, &genai.Tool{
+				FunctionDeclarations: []*genai.FunctionDeclaration{captureDeclaration},
+			})
+		}
+
+		// Offer recording of intermediate results
+		var findings map[string]Finding
+		if config.EnableFindings {
+			findings = make(map[string]Finding)
+			generateContentConfig.Tools = append(generateContentConfig.Tools, &genai.Tool{
+				FunctionDeclarations: []*genai.FunctionDeclaration{findingDeclaration},
+			})
+		}
+
+		// Declare the custom tools the loop executes itself
+		if tool := config.Tools.tool(); tool != nil {
+			generateContentConfig.Tools = append(generateContentConfig.Tools, tool)
+		}
+
+		handledByLoop := func(name string) bool {
+			_, registered := config.Tools.lookup(name)
+			return IsBuiltInTool(name) || registered ||
+				(opts.elementCapture && name == captureToolName) ||
+				(undo != nil && name == undoToolName) ||
+				(findings != nil && name == findingToolName) ||
+				(opts.screenshots != nil && name == screenshotToolName)
+		}
+
+		if *config.IncludeThoughts || config.ThinkingBudget != nil {
+			generateContentConfig.ThinkingConfig = &genai.ThinkingConfig{
+				IncludeThoughts: *config.IncludeThoughts,
+				ThinkingBudget:  config.ThinkingBudget,
+			}
+		}
+
+		// Let the caller adjust the request, keeping the browser tool unless removing it is intended
+		if config.ConfigureRequest != nil {
+			config.ConfigureRequest(generateContentConfig)
+			if !config.AllowRemovingBrowserTool && !hasBrowserTool(generateContentConfig.Tools) {
+				emit(ErrorEvent{Err: errors.New("ConfigureRequest removed the computer use tool, set AllowRemovingBrowserTool if this is intended")})
+				return
+			}
+		}
+
+		// callModel sends one request, streamed if enabled
+		turn := 0
+		callModel := func() (*genai.GenerateContentResponse, error) {
+			if !config.Stream {
+				return config.GenaiClient.Models.GenerateContent(ctx, config.Model, history, generateContentConfig)
+			}
+			return generateStream(ctx, config.GenaiClient, config.Model, history, generateContentConfig, func(text string, thought bool) {
+				emit(TextDeltaEvent{
+					Turn:    turn,
+					Text:    text,
+					Thought: thought,
+				})
+			})
+		}
+
+		// generate sends the request, retrying once without thoughts if the model does not support them
+		// and keeping them off for the rest of the run. Transient API failures are retried with backoff.
+		generate := func() (*genai.GenerateContentResponse, error) {
+			resp, err := callModel()
+			if err != nil && generateContentConfig.ThinkingConfig != nil && isThinkingUnsupportedError(err) {
+				generateContentConfig.ThinkingConfig = nil
+				emit(WarningEvent{Message: fmt.Sprintf("model %s does not support thoughts, continuing without them: %v", config.Model, err)})
+				resp, err = callModel()
+			}
+			for retry := 1; err != nil && isRetryableAPIError(err) && retry <= config.MaxRetries; retry++ {
+				delay := backoffDelay(err, retry, config.InitialBackoff, config.MaxBackoff)
+				emit(RetryEvent{
+					Attempt:    retry,
+					MaxRetries: config.MaxRetries,
+					Delay:      delay,
+					Err:        err,
+				})
+				if sleepErr := config.Clock.Sleep(ctx, delay); sleepErr != nil {
+					return nil, sleepErr
+				}
+				resp, err = callModel()
+			}
+			if err == nil {
+				usage.add(resp.UsageMetadata)
+				turnUsage.add(resp.UsageMetadata)
+			}
+			return resp, err
+		}
+
+		wrapUpTurn := 0
+		var lastContext injectedContext
 		for {
+			turn++
+
 			// Check context cancellation
 			select {
 			case <-ctx.Done():
-				eventChan <- ErrorEvent{Err: ctx.Err()}
+				emit(ErrorEvent{Err: ctx.Err()})
 				return
 			default:
 			}
 
+			// Stop cleanly on a turn boundary when asked to
+			if control.stopRequested() {
+				return
+			}
+
+			// Stop once the turn budget is spent, before calling the model again
+			if config.MaxTurns > 0 && turn > config.MaxTurns {
+				emit(MaxTurnsExceededEvent{
+					Turns:   turn - 1,
+					History: history,
+				})
+				return
+			}
+
+			// Stop before calling the model again once the budget is spent
+			if reason := budgetExceeded(usage, config.MaxTotalTokens, config.MaxEstimatedCost, config.Pricing); reason != "" {
+				emit(BudgetExceededEvent{
+					Reason:        reason,
+					Usage:         usage,
+					EstimatedCost: usage.EstimatedCost(config.Pricing),
+				})
+				return
+			}
+
+			// Stop on a turn boundary once the deadline passed or the wrap-up turns are used up
+			if !config.RunDeadline.IsZero() {
+				now := config.Clock.Now()
+				if !now.Before(config.RunDeadline) || (wrapUpTurn > 0 && turn-wrapUpTurn >= config.WrapUpTurns) {
+					emit(DeadlineEvent{
+						Elapsed: now.Sub(startTime),
+						Turns:   turn - 1,
+					})
+					return
+				}
+				if wrapUpTurn == 0 && config.RunDeadline.Sub(now) <= config.WrapUpMargin {
+					wrapUpTurn = turn
+					target := latestUserContent(history, turn, promptIndex)
+					target.Parts = append(target.Parts, &genai.Part{Text: config.WrapUpMessage})
+				}
+			}
+
+			// Inject dynamic context into the latest user turn
+			if config.TurnContext != nil {
+				if texts := config.TurnContext(turn); len(texts) > 0 {
+					if config.PruneTurnContext && lastContext.content != nil {
+						lastContext.remove()
+					}
+					lastContext = injectTurnContext(latestUserContent(history, turn, promptIndex), texts)
+				}
+			}
+
+			// Hold the turn until the orchestrator lets it through
+			if config.TurnGate != nil {
+				select {
+				case <-config.TurnGate:
+				default:
+					emit(WaitingForGateEvent{Turn: turn})
+					select {
+					case <-ctx.Done():
+						emit(ErrorEvent{Err: ctx.Err()})
+						return
+					case <-config.TurnGate:
+					}
+				}
+			}
+
+			// Hold the turn while paused, a stop requested meanwhile takes effect here
+			if err := control.waitWhilePaused(ctx); err != nil {
+				emit(ErrorEvent{Err: err})
+				return
+			}
+			if control.stopRequested() {
+				return
+			}
+
+			// Add messages injected since the last turn, after its function responses
+			if messages := control.takeMessages(); len(messages) > 0 {
+				content := &genai.Content{Role: genai.RoleUser}
+				for _, message := range messages {
+					content.Parts = append(content.Parts, &genai.Part{Text: message})
+				}
+				history = append(history, content)
+				emit(UserMessageEvent{
+					Turn:     turn,
+					Messages: messages,
+				})
+			}
+
 			// Send the request
-			resp, err := config.GenaiClient.Models.GenerateContent(ctx, config.Model, history, generateContentConfig)
+			turnUsage = Usage{}
+			resp, err := generate()
+
+			// Last resort when the conversation outgrew the input limit: compact it and retry once
+			if isInputTooLargeError(err) {
+				if config.DisableEmergencyCompaction {
+					emit(ErrorEvent{Err: fmt.Errorf("%w: %w", ErrContextTooLarge, err)})
+					return
+				}
+				var description string
+				history, description = compactHistory(ctx, config.GenaiClient, config.CompactionModel, config.CompactionFraction, history, compactionKeep)
+				emit(WarningEvent{Message: fmt.Sprintf("conversation exceeded the input limit, compacted it: %s", description)})
+				resp, err = generate()
+				if isInputTooLargeError(err) {
+					emit(ErrorEvent{Err: fmt.Errorf("%w, even after compaction: %w", ErrContextTooLarge, err)})
+					return
+				}
+			}
+
+			// A blocked prompt stays blocked, so it is reported rather than retried
+			if err == nil && respparse.PromptBlocked(resp) {
+				feedback := resp.PromptFeedback
+				emit(BlockedEvent{
+					Reason:        string(feedback.BlockReason),
+					Message:       feedback.BlockReasonMessage,
+					SafetyRatings: feedback.SafetyRatings,
+				})
+				return
+			}
+
+			// Empty responses are not added to history, the turn is retried instead
+			for attempt := 1; err == nil && respparse.Empty(resp) && attempt <= config.MaxEmptyResponseRetries; attempt++ {
+				emit(WarningEvent{Message: fmt.Sprintf("model returned an empty response, retrying (%d/%d)", attempt, config.MaxEmptyResponseRetries)})
+				resp, err = generate()
+			}
+			if isModelNotFoundError(err) {
+				emit(ErrorEvent{Err: fmt.Errorf("%w: %s (%s): %w", ErrModelNotFound, config.Model, modelNotFoundHint, err)})
+				return
+			}
 			if err != nil {
-				eventChan <- ErrorEvent{Err: fmt.Errorf("error during generating content: %w", err)}
+				emit(ErrorEvent{Err: fmt.Errorf("error during generating content: %w", err)})
+				return
+			}
+			if respparse.Empty(resp) {
+				emit(ErrorEvent{Err: ErrEmptyResponse})
 				return
 			}
 
+			// Unrecognized response shapes are the first sign the preview API changed
+			for _, warning := range respparse.Warnings(resp) {
+				emit(WarningEvent{Message: "unrecognized model response: " + warning})
+			}
+
 			// Update history with newly generated message
 			history = append(history, resp.Candidates[0].Content)
+			completedTurns++
 
 			// Extract text and function calls from response
-			text := extractText(resp.Candidates[0].Content)
+			text := respparse.Text(resp.Candidates[0].Content)
 			functionCalls := resp.FunctionCalls()
 
+			finalText = text
+
 			// If there is no function call, end the loop
 			if len(functionCalls) == 0 {
-				eventChan <- ProgressEvent{
+				emit(ProgressEvent{
 					Text:          text,
 					FunctionCalls: nil,
-				}
+					Usage:         turnUsage,
+				})
 				break
 			}
 
+			// Repair args delivered as a JSON string blob or in the text of the turn
+			if repaired := normalizeFunctionCalls(functionCalls, resp.Candidates[0].Content); len(repaired) > 0 {
+				emit(WarningEvent{Message: fmt.Sprintf("repaired malformed arguments of %s", strings.Join(repaired, ", "))})
+			}
+
+			// Report arguments the built-in handlers would silently ignore
+			if config.StrictArgs && config.StrictArgsMode == StrictArgsWarn {
+				for _, fc := range functionCalls {
+					if unknown := checkUnknownArgs(fc.Name, fc.Args, config.AllowUnknownArgs); len(unknown) > 0 {
+						emit(WarningEvent{Message: unknownArgsMessage(fc.Name, unknown)})
+					}
+				}
+			}
+
+			// Execute byte-identical calls only once, the model's batch is answered in full below
+			allFunctionCalls := functionCalls
+			var dedup callDeduplication
+			if config.DeduplicateIdenticalCallsInTurn {
+				functionCalls, dedup = deduplicateFunctionCalls(functionCalls)
+				if n := dedup.count(); n > 0 {
+					emit(WarningEvent{Message: fmt.Sprintf("skipped %d duplicate function call(s) in turn %d", n, turn)})
+				}
+			}
+
+			// Snapshot the run state for the calls of this turn
+			info := LoopInfo{
+				Turn:           turn,
+				RemainingTurns: -1,
+				Deadline:       config.RunDeadline,
+			}
+			if config.MaxTurns > 0 {
+				info.RemainingTurns = config.MaxTurns - turn
+			}
+			if url, err := config.ComputerUseSession.GetURL(); err == nil {
+				info.CurrentURL = url
+			}
+
 			// Create function call events and prepare for responses
-			callEvents, pendingResponses := createFunctionCallEvents(functionCalls)
+			callEvents, pendingResponses := createFunctionCallEvents(functionCalls, handledByLoop)
+			for _, callEvent := range callEvents {
+				callEvent.Info = info
+			}
+
+			// Mask sensitive arguments in events, execution below still uses the real ones
+			if !config.LogSensitiveArgs {
+				for _, callEvent := range callEvents {
+					callEvent.Args = maskSensitiveArgs(callEvent.FunctionName, callEvent.Args)
+				}
+			}
 
 			// Send progress event
-			eventChan <- ProgressEvent{
+			emit(ProgressEvent{
 				Text:          text,
 				FunctionCalls: callEvents,
-			}
+				Usage:         turnUsage,
+			})
 
 			// Execute function calls and collect responses
-			responseParts, err := executeFunctionCalls(ctx, eventChan, config.ComputerUseSession, functionCalls, pendingResponses, config.SkipSafetyConfirmation)
+			responseParts, err := executeFunctionCalls(ContextWithLoopInfo(ctx, info), eventChan, config.ComputerUseSession, functionCalls, pendingResponses, config.SkipSafetyConfirmation, undo, findings, opts)
+			if errors.Is(err, ErrActionLimitExceeded) {
+				emit(ActionLimitExceededEvent{
+					Reason:          err.Error(),
+					TypedCharacters: opts.actions.typedCharacters,
+					Clicks:          opts.actions.clicks,
+				})
+				return
+			}
 			if err != nil {
-				eventChan <- ErrorEvent{Err: err}
+				emit(ErrorEvent{Err: err})
 				return
 			}
+			callsExecuted += len(functionCalls)
+			if len(functionCalls) != len(allFunctionCalls) {
+				responseParts = dedup.expand(responseParts)
+			}
 
 			// Add function responses to history
 			history = append(history, &genai.Content{
-				Role:  genai.RoleUser,
+				Role:  config.FunctionResponseRole,
 				Parts: responseParts,
 			})
 
 			// Prune old screenshots to keep context size manageable (-1 means unlimited)
-			if config.MaxRecentScreenshots > 0 {
-				pruneOldScreenshots(history, config.MaxRecentScreenshots)
+			pruned := PruneHistory(history, PrunePolicy{
+				MaxRecentScreenshots: config.MaxRecentScreenshots,
+				PinInitialScreenshot: config.PinInitialScreenshot,
+				PromptIndex:          promptIndex,
+			})
+			if pruned.ScreenshotsRemoved > 0 {
+				pruned.TurnIndex = turn
+				emit(pruned)
 			}
+			control.publish(history)
 		}
 	}()
 
 	return eventChan
 }
 
-// extractText extracts all text parts from a content
-func extractText(content *genai.Content) string {
-	var text string
-	for _, part := range content.Parts {
-		if part.Text != "" {
-			text += part.Text
+// emitEvent sends an event, giving up if ctx is done and the consumer is not ready to receive it.
+// It reports whether the event was delivered.
+func emitEvent(ctx context.Context, eventChan chan<- Event, event Event) bool {
+	// Prefer delivery when the consumer is already waiting, even if ctx is done
+	select {
+	case eventChan <- event:
+		return true
+	default:
+	}
+
+	select {
+	case eventChan <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// latestUserContent returns the user content the next model call answers: the prompt on the first turn,
+// the function responses afterwards. Without a prompt, the first turn answers the end of the initial history.
+func latestUserContent(history []*genai.Content, turn int, promptIndex int) *genai.Content {
+	if turn == 1 && promptIndex >= 0 {
+		return history[promptIndex]
+	}
+	return history[len(history)-1]
+}
+
+// hasBrowserTool reports whether tools include the computer use tool, or the built-ins declared as functions
+func hasBrowserTool(tools []*genai.Tool) bool {
+	for _, tool := range tools {
+		if tool == nil {
+			continue
 		}
+		if tool.ComputerUse != nil {
+			return true
+		}
+		for _, declaration := range tool.FunctionDeclarations {
+			if IsBuiltInTool(declaration.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// systemInstructions builds the system instruction parts implied by the config
+func systemInstructions(config StartLoopConfig) []*genai.Part {
+	var parts []*genai.Part
+	if config.SystemInstruction != "" {
+		parts = append(parts, &genai.Part{Text: config.SystemInstruction})
+	}
+	if config.ResponseLanguage != "" {
+		// Scoped to the final report so the model keeps searching and typing in the language sites expect
+		parts = append(parts, &genai.Part{Text: fmt.Sprintf(
+			"When you have finished the task and report back, write your final answer in %s. "+
+				"This applies only to the final report: browse, search and type in whatever language the task and the websites require.",
+			config.ResponseLanguage,
+		)})
+	}
+	if config.EncourageToolBatching && len(config.ReadOnlyTools) > 0 {
+		parts = append(parts, &genai.Part{Text: fmt.Sprintf(
+			"The following tools have no side effects: %s. "+
+				"When you need several of their results, call them together in a single turn instead of one per turn.",
+			strings.Join(config.ReadOnlyTools, ", "),
+		)})
+	}
+	return parts
+}
+
+// initialPageParts returns the URL and screenshot of page, to attach to the prompt
+func initialPageParts(page pageState, overlay GridOverlay) []*genai.Part {
+	return []*genai.Part{
+		{Text: "Current URL: " + page.URL},
+		genai.NewPartFromBytes(overlay.apply(page.Screenshot), "image/png"),
+	}
+}
+
+// pageState is the URL and a screenshot of the page at one moment
+type pageState struct {
+	URL        string
+	Screenshot []byte
+}
+
+// capturePage returns the current URL and a screenshot of the page
+func capturePage(session BrowserSession) (pageState, error) {
+	url, err := session.GetURL()
+	if err != nil {
+		return pageState{}, err
+	}
+	screenshot, err := session.Screenshot()
+	if err != nil {
+		return pageState{}, err
+	}
+	return pageState{URL: url, Screenshot: screenshot}, nil
+}
+
+// isThinkingUnsupportedError reports whether err is the API rejecting the thinking config
+func isThinkingUnsupportedError(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
 	}
-	return text
+	return apiErr.Code == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "thinking")
 }
 
-// pendingResponse holds the channels for communicating with custom tool handlers
+// ErrEmptyResponse is reported when the model keeps returning responses without text or function calls
+var ErrEmptyResponse = errors.New("model returned an empty response")
+
+// pendingResponse holds the channel for communicating with custom tool handlers
 type pendingResponse struct {
-	funcCall   *genai.FunctionCall
-	respChan   chan map[string]any
-	rejectChan chan error
+	funcCall *genai.FunctionCall
+	answer   chan pendingAnswer // Buffered, holding the first answer until the loop reaches the call
+}
+
+// pendingAnswer is a subscriber's answer to a custom tool call: a response, or the error rejecting it
+type pendingAnswer struct {
+	response map[string]any
+	err      error
+}
+
+// send records the first answer to the call without waiting for the loop; later answers are ignored.
+// Answers never block, so a subscriber may answer calls in any order, or some calls after the run ended.
+func (p *pendingResponse) send(answer pendingAnswer) {
+	select {
+	case p.answer <- answer:
+	default:
+	}
 }
 
-// createFunctionCallEvents creates FunctionCall events and prepares response channels
-func createFunctionCallEvents(functionCalls []*genai.FunctionCall) ([]*FunctionCall, []*pendingResponse) {
+// executeLoopTool runs a tool the loop offers besides the built-ins, such as capture_element_screenshot,
+// through the middleware. The middleware sees the response fields; the images of the part run returns are kept.
+func executeLoopTool(
+	ctx context.Context,
+	session BrowserSession,
+	name string,
+	args map[string]any,
+	opts toolOptions,
+	run func(ctx context.Context, session BrowserSession, args map[string]any) (*genai.Part, error),
+) (*genai.Part, error) {
+	var part *genai.Part
+	execute := chainMiddleware(func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+		var err error
+		part, err = run(ctx, session, args)
+		if err != nil {
+			return nil, err
+		}
+		return part.FunctionResponse.Response, nil
+	}, opts.middleware)
+	result, err := execute(middlewareContext(ctx, name, opts), session, args)
+	if err != nil {
+		return nil, err
+	}
+	if opts.toolErrors != nil {
+		opts.toolErrors.reset()
+	}
+	if part == nil {
+		// A middleware answered without running the tool
+		return genai.NewPartFromFunctionResponse(name, result), nil
+	}
+	part.FunctionResponse.Response = result
+	return part, nil
+}
+
+// reportToolFailure answers a failed call the loop executed with the error, the URL and a screenshot when
+// ToolErrorModeReport allows it, see toolErrorBudget. Otherwise it returns the error ending the run.
+func reportToolFailure(ctx context.Context, session BrowserSession, name string, err error, opts toolOptions) (*genai.Part, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if opts.toolErrors != nil && !errors.Is(err, ErrActionLimitExceeded) && opts.toolErrors.report(err) {
+		// Let the model see the failure and the page, and try something else
+		return toolErrorResponse(session, name, err, opts), nil
+	}
+	return nil, fmt.Errorf("error handling built-in tool %s: %w", name, err)
+}
+
+// newToolResultEvent describes the outcome of a built-in tool call, masking its sensitive arguments unless
+// LogSensitiveArgs is set
+func newToolResultEvent(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	part *genai.Part,
+	err error,
+	duration time.Duration,
+	opts toolOptions,
+) ToolResultEvent {
+	event := ToolResultEvent{
+		ToolName: name,
+		Args:     args,
+		Err:      err,
+		Duration: duration,
+	}
+	if info, ok := LoopInfoFromContext(ctx); ok {
+		event.Turn = info.Turn
+	}
+	if !opts.logSensitiveArgs {
+		event.Args = maskSensitiveArgs(name, args)
+	}
+	if err != nil {
+		var toolErr *ToolError
+		if errors.As(err, &toolErr) {
+			event.Code = toolErr.Code
+		}
+		return event
+	}
+	if part != nil && part.FunctionResponse != nil {
+		event.Response = part.FunctionResponse.Response
+	}
+	return event
+}
+
+// createFunctionCallEvents creates FunctionCall events and prepares response channels.
+// Calls for which handledByLoop returns true are executed by the loop and need no action.
+func createFunctionCallEvents(functionCalls []*genai.FunctionCall, handledByLoop func(name string) bool) ([]*FunctionCall, []*pendingResponse) {
 	var callEvents []*FunctionCall
 	var pendingResponses []*pendingResponse
 
 	for _, fc := range functionCalls {
 		funcCall := fc // capture for closure
-		isBuiltIn := IsBuiltInTool(funcCall.Name)
-
-		if isBuiltIn {
+		if handledByLoop(funcCall.Name) {
 			// Built-in tools are handled automatically
-			callEvents = append(callEvents, &FunctionCall{
-				FunctionName: funcCall.Name,
-				Args:         funcCall.Args,
-				needsAction:  false,
-				respondFunc:  nil,
-			})
+			callEvents = append(callEvents, NewFunctionCall(funcCall.Name, funcCall.Args, nil, nil))
 		} else {
 			// Custom tools need subscriber to handle
-			respChan := make(chan map[string]any)
-			rejectChan := make(chan error)
-
 			pending := &pendingResponse{
-				funcCall:   funcCall,
-				respChan:   respChan,
-				rejectChan: rejectChan,
+				funcCall: funcCall,
+				answer:   make(chan pendingAnswer, 1),
 			}
 			pendingResponses = append(pendingResponses, pending)
 
-			callEvents = append(callEvents, &FunctionCall{
-				FunctionName: funcCall.Name,
-				Args:         funcCall.Args,
-				needsAction:  true,
-				respondFunc: func(response map[string]any) {
-					respChan <- response
+			callEvents = append(callEvents, NewFunctionCall(funcCall.Name, funcCall.Args,
+				func(response map[string]any) {
+					pending.send(pendingAnswer{response: response})
 				},
-				rejectFunc: func(err error) {
-					rejectChan <- err
+				func(err error) {
+					pending.send(pendingAnswer{err: err})
 				},
-			})
+			))
 		}
 	}
 
 	return callEvents, pendingResponses
 }
 
-// handleSafetyConfirmation checks for safety decisions in a function call and requests user confirmation.
-// Returns error if context is exceeded or user denied
-func handleSafetyConfirmation(ctx context.Context, eventChan chan<- Event, fc *genai.FunctionCall) error {
-	safetyDecision, ok := fc.Args["safety_decision"].(map[string]any)
-	if !ok {
-		return nil
-	}
+// errSafetyDenied is returned by confirmSafety when the user denies the action
+var errSafetyDenied = errors.New("safety check denied by user")
 
-	decision, _ := safetyDecision["decision"].(string)
-	if decision != "require_confirmation" {
+// safetyAcknowledgement returns the response fields acknowledging an approved call that required confirmation, or nil
+func safetyAcknowledgement(args map[string]any) map[string]any {
+	if _, required := respparse.SafetyDecision(args); !required {
 		return nil
 	}
+	return map[string]any{"safety_acknowledgement": "true"}
+}
 
-	explanation, _ := safetyDecision["explanation"].(string)
-
+// confirmSafety emits a SafetyConfirmationEvent for a call requiring confirmation and waits for the user's decision.
+// Returns errSafetyDenied if the user denied, or the context error
+func confirmSafety(ctx context.Context, eventChan chan<- Event, explanation string) error {
 	// Create channels for user response
 	approveChan := make(chan struct{})
 	denyChan := make(chan struct{})
 
 	// Emit safety confirmation event
-	eventChan <- SafetyConfirmationEvent{
-		Explanation: explanation,
-		approveFunc: func() { close(approveChan) },
-		denyFunc:    func() { close(denyChan) },
-	}
+	emitEvent(ctx, eventChan, NewSafetyConfirmationEvent(explanation,
+		func() { close(approveChan) },
+		func() { close(denyChan) },
+	))
 
 	// Wait for user decision
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-approveChan:
-		// User approved
 		return nil
 	case <-denyChan:
-		// User denied, terminate
-		return fmt.Errorf("safety check denied by user")
+		return errSafetyDenied
 	}
 }
 
+// safetyDeniedResponse tells the model the user refused the action, so it does not simply retry it
+func safetyDeniedResponse(name string) *genai.Part {
+	return genai.NewPartFromFunctionResponse(name, map[string]any{
+		"denied": true,
+		"error":  "the user denied this action after a safety confirmation; do not retry it, find another way or report back",
+	})
+}
+
 // executeFunctionCalls executes all function calls (built-in and custom) and returns response parts.
 // It maintains the order of function calls to match the Python reference implementation.
 // Handles safety decisions by emitting SafetyConfirmationEvent and waiting for user response.
 func executeFunctionCalls(
 	ctx context.Context,
 	eventChan chan<- Event,
-	session *computeruse.Session,
+	session BrowserSession,
 	functionCalls []*genai.FunctionCall,
 	pendingResponses []*pendingResponse,
 	skipSafetyConfirmation bool,
+	undo *undoStack,
+	findings map[string]Finding,
+	opts toolOptions,
 ) ([]*genai.Part, error) {
 	var responseParts []*genai.Part
 	pendingIdx := 0
 
 	// Process function calls in order (built-in and custom interleaved)
 	for _, fc := range functionCalls {
-		if IsBuiltInTool(fc.Name) {
-			// Check for safety decision before executing built-in tool
-			if !skipSafetyConfirmation {
-				if err := handleSafetyConfirmation(ctx, eventChan, fc); err != nil {
+		if undo != nil && fc.Name == undoToolName {
+			part, err := undoLastAction(ctx, eventChan, session, undo, skipSafetyConfirmation, opts)
+			if err != nil {
+				return nil, err
+			}
+			responseParts = append(responseParts, part)
+		} else if findings != nil && fc.Name == findingToolName {
+			part, err := executeLoopTool(ctx, session, fc.Name, fc.Args, opts, func(ctx context.Context, session BrowserSession, args map[string]any) (*genai.Part, error) {
+				event, part, err := recordFinding(findings, args)
+				if err == nil {
+					emitEvent(ctx, eventChan, event)
+				}
+				return part, err
+			})
+			if err != nil {
+				if part, err = reportToolFailure(ctx, session, fc.Name, err, opts); err != nil {
 					return nil, err
 				}
 			}
-
-			// Handle built-in tool
-			part, err := HandleBuiltInTool(session, fc.Name, fc.Args)
+			responseParts = append(responseParts, part)
+		} else if opts.screenshots != nil && fc.Name == screenshotToolName {
+			part, err := executeLoopTool(ctx, session, fc.Name, fc.Args, opts, func(ctx context.Context, session BrowserSession, args map[string]any) (*genai.Part, error) {
+				return handleTakeScreenshot(session, opts)
+			})
+			if err != nil {
+				if part, err = reportToolFailure(ctx, session, fc.Name, err, opts); err != nil {
+					return nil, err
+				}
+			}
+			responseParts = append(responseParts, part)
+		} else if opts.elementCapture && fc.Name == captureToolName {
+			part, err := executeLoopTool(ctx, session, fc.Name, fc.Args, opts, func(ctx context.Context, session BrowserSession, args map[string]any) (*genai.Part, error) {
+				return handleCaptureElementScreenshot(session, args)
+			})
+			if err != nil {
+				if part, err = reportToolFailure(ctx, session, fc.Name, err, opts); err != nil {
+					return nil, err
+				}
+			}
+			responseParts = append(responseParts, part)
+		} else if IsBuiltInTool(fc.Name) {
+			part, err := executeBuiltInCall(ctx, eventChan, session, fc, skipSafetyConfirmation, undo, opts)
 			if err != nil {
-				return nil, fmt.Errorf("error handling built-in tool %s: %w", fc.Name, err)
+				return nil, err
+			}
+			responseParts = append(responseParts, part)
+		} else if fn, ok := opts.registry.lookup(fc.Name); ok {
+			part, err := executeRegisteredTool(ctx, fn, fc.Name, fc.Args)
+			if err != nil {
+				return nil, err
 			}
 			responseParts = append(responseParts, part)
 		} else {
@@ -258,11 +1116,12 @@ func executeFunctionCalls(
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
-			case err := <-pending.rejectChan:
-				return nil, fmt.Errorf("function call %s rejected: %w", pending.funcCall.Name, err)
-			case response := <-pending.respChan:
+			case answer := <-pending.answer:
+				if answer.err != nil {
+					return nil, fmt.Errorf("function call %s rejected: %w", pending.funcCall.Name, answer.err)
+				}
 				// Create function response part
-				part := genai.NewPartFromFunctionResponse(pending.funcCall.Name, response)
+				part := genai.NewPartFromFunctionResponse(pending.funcCall.Name, answer.response)
 				responseParts = append(responseParts, part)
 			}
 		}
@@ -271,42 +1130,155 @@ func executeFunctionCalls(
 	return responseParts, nil
 }
 
+// executeBuiltInCall executes a built-in call as the model issued it: ExcludedTools, StrictArgs and safety
+// confirmations apply, the middleware runs and a ToolResultEvent is emitted. Failures are answered as
+// ToolErrorMode says, see reportToolFailure. The inverse of a successful call is recorded on undo unless it is nil.
+func executeBuiltInCall(
+	ctx context.Context,
+	eventChan chan<- Event,
+	session BrowserSession,
+	fc *genai.FunctionCall,
+	skipSafetyConfirmation bool,
+	undo *undoStack,
+	opts toolOptions,
+) (*genai.Part, error) {
+	// Never execute tools disabled by ExcludedTools, even if the model calls them anyway
+	if slices.Contains(opts.excludedTools, fc.Name) {
+		return excludedToolResponse(fc.Name), nil
+	}
+
+	// Reject calls with unknown arguments, see StrictArgs
+	if opts.rejectUnknownArgs {
+		if unknown := checkUnknownArgs(fc.Name, fc.Args, opts.allowUnknownArgs); len(unknown) > 0 {
+			return unknownArgsResponse(fc.Name, unknown), nil
+		}
+	}
+
+	// Ask the user before executing a call the model flagged, a denial is reported back to the model
+	explanation, required := respparse.SafetyDecision(fc.Args)
+	if required && !skipSafetyConfirmation {
+		err := confirmSafety(ctx, eventChan, explanation)
+		if errors.Is(err, errSafetyDenied) {
+			return safetyDeniedResponse(fc.Name), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	started := clockOrSystem(opts.clock).Now()
+	part, attempts, err := executeBuiltInTool(ctx, session, fc.Name, fc.Args, safetyAcknowledgement(fc.Args), opts)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	outcome := newToolResultEvent(ctx, fc.Name, fc.Args, part, err, clockOrSystem(opts.clock).Now().Sub(started), opts)
+	outcome.Attempts = attempts
+	if err != nil {
+		emitEvent(ctx, eventChan, outcome)
+		return reportToolFailure(ctx, session, fc.Name, err, opts)
+	}
+	if opts.toolErrors != nil {
+		opts.toolErrors.reset()
+	}
+	if undo != nil {
+		undo.record(fc.Name, fc.Args)
+	}
+	if opts.emitScreenshots {
+		if result := toolResultFromPart(part); result.Screenshot != nil {
+			url, _ := result.Response["url"].(string)
+			emitEvent(ctx, eventChan, ScreenshotEvent{
+				ToolName:   fc.Name,
+				Args:       outcome.Args,
+				URL:        url,
+				Screenshot: result.Screenshot,
+				Time:       clockOrSystem(opts.clock).Now(),
+			})
+		}
+	}
+	emitEvent(ctx, eventChan, outcome)
+	return part, nil
+}
+
 // pruneOldScreenshots removes screenshot images from old turns to keep context size manageable.
-// It keeps only the most recent maxTurns turns that contain screenshots.
-func pruneOldScreenshots(history []*genai.Content, maxTurns int) {
+// It keeps only the most recent maxTurns turns that contain screenshots, either in function responses
+// or as image parts such as the initial screenshot attached to a prompt. The content at pinIndex
+// is always kept and not counted, -1 pins nothing.
+// The returned event describes what was removed, without its TurnIndex.
+func pruneOldScreenshots(history []*genai.Content, maxTurns int, pinIndex int) PruneEvent {
+	var pruned PruneEvent
 	turnsWithScreenshotsFound := 0
 
 	// Iterate through history in reverse to find turns with screenshots
 	for i := len(history) - 1; i >= 0; i-- {
+		// Turns are classified by their parts, function responses may be sent under any role
 		content := history[i]
-		if content.Role != genai.RoleUser || content.Parts == nil {
+		if content.Parts == nil || i == pinIndex {
 			continue
 		}
 
-		// Check if this content has screenshots from built-in computer use functions
+		// Check if this content has screenshots from functions handled by the loop, or attached images
 		hasScreenshot := false
 		for _, part := range content.Parts {
-			if part.FunctionResponse != nil &&
-				part.FunctionResponse.Parts != nil &&
-				IsBuiltInTool(part.FunctionResponse.Name) {
+			if hasScreenshotParts(part) || isInlineImage(part) {
 				hasScreenshot = true
 				break
 			}
 		}
+		if !hasScreenshot {
+			continue
+		}
 
-		if hasScreenshot {
-			turnsWithScreenshotsFound++
-			// Remove screenshot images if we exceed the limit
-			if turnsWithScreenshotsFound > maxTurns {
-				for _, part := range content.Parts {
-					if part.FunctionResponse != nil &&
-						part.FunctionResponse.Parts != nil &&
-						IsBuiltInTool(part.FunctionResponse.Name) {
-						// Remove the screenshot parts but keep the function response
-						part.FunctionResponse.Parts = nil
+		turnsWithScreenshotsFound++
+		// Remove screenshot images if we exceed the limit
+		if turnsWithScreenshotsFound > maxTurns {
+			for _, part := range content.Parts {
+				if hasScreenshotParts(part) {
+					for _, screenshot := range part.FunctionResponse.Parts {
+						if screenshot.InlineData != nil {
+							pruned.BytesFreed += len(screenshot.InlineData.Data)
+						}
 					}
+					pruned.ScreenshotsRemoved += len(part.FunctionResponse.Parts)
+
+					// Remove the screenshot parts but keep the function response
+					part.FunctionResponse.Parts = nil
 				}
 			}
+			removed := pruneInlineImages(content)
+			pruned.ScreenshotsRemoved += removed.ScreenshotsRemoved
+			pruned.BytesFreed += removed.BytesFreed
+			pruned.AffectedTurns = append(pruned.AffectedTurns, i)
 		}
 	}
+
+	// History was walked backwards, report affected turns in order
+	slices.Reverse(pruned.AffectedTurns)
+	return pruned
+}
+
+// pruneInlineImages removes the image parts of a content, keeping its text
+func pruneInlineImages(content *genai.Content) PruneEvent {
+	var pruned PruneEvent
+	parts := content.Parts[:0]
+	for _, part := range content.Parts {
+		if isInlineImage(part) {
+			pruned.ScreenshotsRemoved++
+			pruned.BytesFreed += len(part.InlineData.Data)
+			continue
+		}
+		parts = append(parts, part)
+	}
+	content.Parts = parts
+	return pruned
+}
+
+// isInlineImage reports whether part is an image attached directly to a content, such as the initial screenshot
+func isInlineImage(part *genai.Part) bool {
+	return part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/")
+}
+
+// hasScreenshotParts reports whether part is a function response carrying screenshots.
+// Only responses from built-in tools, undo and region captures carry them.
+func hasScreenshotParts(part *genai.Part) bool {
+	return part.FunctionResponse != nil && part.FunctionResponse.Parts != nil
 }
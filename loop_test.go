@@ -0,0 +1,63 @@
+package geminirod
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// countImages returns the number of screenshots in the function responses of contents
+func countImages(contents []*genai.Content) int {
+	images := 0
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			if part.FunctionResponse == nil {
+				continue
+			}
+			for _, responsePart := range part.FunctionResponse.Parts {
+				if responsePart.InlineData != nil {
+					images++
+				}
+			}
+		}
+	}
+	return images
+}
+
+func TestFunctionResponseRole(t *testing.T) {
+	for _, role := range []string{"", "function"} {
+		backend := newFakeBackend(t,
+			fakeReply{Response: callResponse(call("open_web_browser", nil))},
+			fakeReply{Response: callResponse(call("open_web_browser", nil))},
+			fakeReply{Response: callResponse(call("open_web_browser", nil))},
+		)
+		config, _ := testConfig(t, backend)
+		config.FunctionResponseRole = role
+		config.MaxRecentScreenshots = 1
+
+		events := collectEvents(t, context.Background(), config, nil)
+		if err := finalError(events); err != nil {
+			t.Fatalf("role %q: run failed: %v", role, err)
+		}
+
+		want := role
+		if want == "" {
+			want = genai.RoleUser
+		}
+		calls := backend.calls()
+		if len(calls) != 4 {
+			t.Fatalf("role %q: got %d model calls, want 4", role, len(calls))
+		}
+		last := calls[3].Contents
+		for i, content := range last {
+			if len(functionResponses(content)) > 0 && content.Role != want {
+				t.Errorf("role %q: content %d has role %q, want %q", role, i, content.Role, want)
+			}
+		}
+		// Pruning finds the screenshot turns whatever their role
+		if got := countImages(last); got != 1 {
+			t.Errorf("role %q: last request has %d screenshots, want 1", role, got)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package geminirod
+
+import (
+	"context"
+	"time"
+)
+
+// LoopInfo describes the state of a run when a function call was made.
+// It is a snapshot: it does not change as the run goes on.
+type LoopInfo struct {
+	Turn           int       // Turn the call was made in, starting from 1
+	CurrentURL     string    // Page URL before the turn's calls were executed, empty if unknown
+	RemainingTurns int       // Model turns left under MaxTurns, -1 = unlimited
+	Deadline       time.Time // RunDeadline, zero = no deadline
+}
+
+type loopInfoKey struct{}
+
+// ContextWithLoopInfo returns a copy of ctx carrying info, see LoopInfoFromContext.
+// Use it to hand a FunctionCall's Info to tool handlers that only receive a context.
+func ContextWithLoopInfo(ctx context.Context, info LoopInfo) context.Context {
+	return context.WithValue(ctx, loopInfoKey{}, info)
+}
+
+// LoopInfoFromContext returns the loop state carried by ctx, if any
+func LoopInfoFromContext(ctx context.Context) (LoopInfo, bool) {
+	info, ok := ctx.Value(loopInfoKey{}).(LoopInfo)
+	return info, ok
+}
@@ -0,0 +1,75 @@
+package geminirod
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestLoopInfoFromContext(t *testing.T) {
+	if _, ok := LoopInfoFromContext(context.Background()); ok {
+		t.Error("LoopInfo found in a bare context")
+	}
+	info := LoopInfo{Turn: 2, CurrentURL: "https://example.com/", RemainingTurns: -1}
+	if got, ok := LoopInfoFromContext(ContextWithLoopInfo(context.Background(), info)); !ok || got != info {
+		t.Errorf("LoopInfoFromContext = %+v, %v, want %+v", got, ok, info)
+	}
+}
+
+func TestLoopInfoInLoop(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("navigate", map[string]any{"url": "https://example.com/next"}))},
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}), call("lookup", nil))},
+	)
+	config, _ := testConfig(t, backend)
+	config.MaxTurns = 5
+	config.RunDeadline = config.Clock.Now().Add(time.Hour)
+
+	// Built-in tools and registered tools see the snapshot of their turn in their context
+	var seen []LoopInfo
+	config.ToolMiddleware = []ToolMiddleware{func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			info, _ := LoopInfoFromContext(ctx)
+			seen = append(seen, info)
+			return next(ctx, session, args)
+		}
+	}}
+	registry := NewToolRegistry()
+	err := registry.Register(&genai.FunctionDeclaration{Name: "lookup"}, func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		info, _ := LoopInfoFromContext(ctx)
+		seen = append(seen, info)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("registering lookup: %v", err)
+	}
+	config.Tools = registry
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	first := LoopInfo{Turn: 1, CurrentURL: "https://start.example/", RemainingTurns: 4, Deadline: config.RunDeadline}
+	second := LoopInfo{Turn: 2, CurrentURL: "https://example.com/next", RemainingTurns: 3, Deadline: config.RunDeadline}
+	want := []LoopInfo{first, second, second}
+	if len(seen) != len(want) {
+		t.Fatalf("tools saw %d snapshots, want %d", len(seen), len(want))
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("snapshot %d = %+v, want %+v", i, seen[i], want[i])
+		}
+	}
+
+	// Subscribers get the same snapshot on the calls of the ProgressEvent
+	for _, progress := range eventsOf[ProgressEvent](events) {
+		for _, fc := range progress.FunctionCalls {
+			if fc.Info.Turn == 0 || fc.Info.Deadline != config.RunDeadline {
+				t.Errorf("call %s has info %+v, want the snapshot of its turn", fc.FunctionName, fc.Info)
+			}
+		}
+	}
+}
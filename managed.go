@@ -0,0 +1,190 @@
+package geminirod
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+// Loop is a handle on a run started with StartLoopManaged
+type Loop struct {
+	events  <-chan Event
+	control *loopControl
+}
+
+// StartLoopManaged is StartLoop returning a handle that can stop, pause and inspect the run
+func StartLoopManaged(ctx context.Context, config StartLoopConfig) *Loop {
+	control := &loopControl{}
+	return &Loop{
+		events:  startLoop(ctx, config, control),
+		control: control,
+	}
+}
+
+// Events returns the event channel, as returned by StartLoop
+func (l *Loop) Events() <-chan Event {
+	return l.events
+}
+
+// Stop ends the run once the current turn, including its tool calls, is finished.
+// The run closes cleanly: no ErrorEvent is sent, only the CompletionEvent.
+func (l *Loop) Stop() {
+	l.control.stop()
+}
+
+// Pause holds the run before its next model call. A tool call in progress is not interrupted
+func (l *Loop) Pause() {
+	l.control.pause()
+}
+
+// Resume lets a paused run continue
+func (l *Loop) Resume() {
+	l.control.resume()
+}
+
+// Inject sends a message to the model without interrupting the run, e.g. to correct its course.
+// Pending messages are added to the history as one user turn, in the order they were injected,
+// right before the next model call; a UserMessageEvent reports them. It is safe to call from any goroutine.
+func (l *Loop) Inject(text string) {
+	l.control.inject(text)
+}
+
+// History returns a copy of the conversation as of the end of the last completed turn.
+// It can be modified freely without affecting the run.
+func (l *Loop) History() []*genai.Content {
+	return l.control.snapshot()
+}
+
+// loopControl is shared between a Loop handle and its run. A nil loopControl does nothing.
+type loopControl struct {
+	mu      sync.Mutex
+	stopped bool
+	resumed chan struct{} // Non-nil while paused, closed on Resume or Stop
+	history []*genai.Content
+	pending []string // Messages from Inject not yet added to the history
+}
+
+func (c *loopControl) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+func (c *loopControl) pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed == nil && !c.stopped {
+		c.resumed = make(chan struct{})
+	}
+}
+
+func (c *loopControl) resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+func (c *loopControl) inject(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, text)
+}
+
+// takeMessages returns and clears the pending injected messages
+func (c *loopControl) takeMessages() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	messages := c.pending
+	c.pending = nil
+	return messages
+}
+
+// stopRequested reports whether Stop was called
+func (c *loopControl) stopRequested() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+// waitWhilePaused blocks while the run is paused, returning the context error if ctx is done first
+func (c *loopControl) waitWhilePaused(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	resumed := c.resumed
+	c.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumed:
+		return nil
+	}
+}
+
+// publish records the history for History. The loop modifies contents in place when pruning,
+// so they are copied here, on the loop's goroutine.
+func (c *loopControl) publish(history []*genai.Content) {
+	if c == nil {
+		return
+	}
+	copied := cloneHistory(history)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = copied
+}
+
+func (c *loopControl) snapshot() []*genai.Content {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cloneHistory(c.history)
+}
+
+// cloneHistory copies contents, their parts and the function calls and responses in them.
+// Inline data bytes are shared, the loop never modifies them.
+func cloneHistory(history []*genai.Content) []*genai.Content {
+	cloned := make([]*genai.Content, len(history))
+	for i, content := range history {
+		parts := make([]*genai.Part, len(content.Parts))
+		for j, part := range content.Parts {
+			p := *part
+			if part.FunctionCall != nil {
+				fc := *part.FunctionCall
+				fc.Args = maps.Clone(fc.Args)
+				p.FunctionCall = &fc
+			}
+			if part.FunctionResponse != nil {
+				fr := *part.FunctionResponse
+				fr.Response = maps.Clone(fr.Response)
+				fr.Parts = slices.Clone(fr.Parts)
+				p.FunctionResponse = &fr
+			}
+			parts[j] = &p
+		}
+		cloned[i] = &genai.Content{
+			Role:  content.Role,
+			Parts: parts,
+		}
+	}
+	return cloned
+}
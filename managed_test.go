@@ -0,0 +1,161 @@
+package geminirod
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestLoopStop(t *testing.T) {
+	// The handle is only set once the loop started, the first model call waits for it
+	var loop *Loop
+	started := make(chan struct{})
+	backend := newFakeBackend(t,
+		fakeReply{
+			Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0})),
+			Handle:   func() { <-started; loop.Stop() },
+		},
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+	)
+	config, browser := testConfig(t, backend)
+	loop = StartLoopManaged(context.Background(), config)
+	close(started)
+
+	// The turn in progress is finished, then the run ends cleanly
+	var events []Event
+	withinTimeout(t, func() { events = drain(loop.Events()) })
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := len(backend.calls()); got != 1 {
+		t.Errorf("got %d model calls, want none after Stop", got)
+	}
+	if clicks := len(browser.recorded("ClickAt")); clicks != 1 {
+		t.Errorf("clicked %d times, want the calls of the stopped turn executed", clicks)
+	}
+	completions := eventsOf[CompletionEvent](events)
+	if len(completions) != 1 || completions[0].Turns != 1 {
+		t.Errorf("CompletionEvents = %+v, want one after a single turn", completions)
+	}
+}
+
+func TestLoopPause(t *testing.T) {
+	tests := []struct {
+		name      string
+		stop      bool
+		wantCalls int
+	}{
+		{name: "resumed", wantCalls: 2},
+		{name: "stopped while paused", stop: true, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var loop *Loop
+			started := make(chan struct{})
+			backend := newFakeBackend(t, fakeReply{
+				Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0})),
+				Handle:   func() { <-started; loop.Pause() },
+			})
+			config, _ := testConfig(t, backend)
+			loop = StartLoopManaged(context.Background(), config)
+			close(started)
+
+			var events []Event
+			withinTimeout(t, func() {
+				for event := range loop.Events() {
+					events = append(events, event)
+					if _, ok := event.(ToolResultEvent); !ok {
+						continue
+					}
+					// Give the run time to reach the next model call, it must hold there
+					time.Sleep(50 * time.Millisecond)
+					if got := len(backend.calls()); got != 1 {
+						t.Errorf("got %d model calls while paused, want 1", got)
+					}
+					if tt.stop {
+						loop.Stop()
+					} else {
+						loop.Resume()
+					}
+				}
+			})
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if got := len(backend.calls()); got != tt.wantCalls {
+				t.Errorf("got %d model calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLoopHistory(t *testing.T) {
+	backend := newFakeBackend(t, fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))})
+	config, _ := testConfig(t, backend)
+	loop := StartLoopManaged(context.Background(), config)
+
+	var events []Event
+	withinTimeout(t, func() { events = drain(loop.Events()) })
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	completion := eventsOf[CompletionEvent](events)[0]
+	history := loop.History()
+	if len(history) != len(completion.History) {
+		t.Fatalf("History() has %d contents, want the %d of the completed run", len(history), len(completion.History))
+	}
+
+	// The copy is detached from the run's history
+	history[0].Parts[0].Text = "changed"
+	history[1].Parts[0].FunctionCall.Args["x"] = 99.0
+	if completion.History[0].Parts[0].Text != config.Prompt || completion.History[1].Parts[0].FunctionCall.Args["x"] != 10.0 {
+		t.Error("modifying History() changed the run's history")
+	}
+	if again := loop.History(); again[0].Parts[0].Text != config.Prompt || again[1].Parts[0].FunctionCall.Args["x"] != 10.0 {
+		t.Error("modifying History() changed later snapshots")
+	}
+}
+
+func TestLoopInject(t *testing.T) {
+	var loop *Loop
+	started := make(chan struct{})
+	backend := newFakeBackend(t, fakeReply{
+		Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0})),
+		Handle: func() {
+			<-started
+			loop.Inject("use the second result instead")
+			loop.Inject("and hurry")
+		},
+	})
+	config, _ := testConfig(t, backend)
+	loop = StartLoopManaged(context.Background(), config)
+	close(started)
+
+	var events []Event
+	withinTimeout(t, func() { events = drain(loop.Events()) })
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// Messages injected during a turn are sent as one user turn after its function responses
+	calls := backend.calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d model calls, want 2", len(calls))
+	}
+	history := calls[1].Contents
+	injected := history[len(history)-1]
+	if injected.Role != genai.RoleUser || len(injected.Parts) != 2 ||
+		injected.Parts[0].Text != "use the second result instead" || injected.Parts[1].Text != "and hurry" {
+		t.Errorf("last content = %+v, want the injected messages in order", injected)
+	}
+	if functionResponses(history[len(history)-2])["click_at"] == nil {
+		t.Error("injected messages not placed after the function responses")
+	}
+
+	messages := eventsOf[UserMessageEvent](events)
+	if len(messages) != 1 || messages[0].Turn != 2 || len(messages[0].Messages) != 2 {
+		t.Errorf("UserMessageEvents = %+v, want both messages reported before turn 2", messages)
+	}
+}
@@ -0,0 +1,76 @@
+package geminirod
+
+import (
+	"context"
+	"time"
+)
+
+// ToolMiddleware wraps the execution of built-in tools, e.g. for audit logging, timing or argument sanitization.
+// The tools the loop offers itself, such as capture_element_screenshot and report_finding, are wrapped too.
+// It may call next with modified args, or skip it and return its own result or error.
+// ToolNameFromContext gives the name of the tool being executed, ClockFromContext the clock of the run.
+type ToolMiddleware func(next ToolHandler) ToolHandler
+
+type toolNameKey struct{}
+
+type clockKey struct{}
+
+// ToolNameFromContext returns the name of the tool a middleware is executing, if any
+func ToolNameFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(toolNameKey{}).(string)
+	return name, ok
+}
+
+// ClockFromContext returns the clock of the run a middleware is executing in, see StartLoopConfig.Clock.
+// Outside a run, it is the system clock.
+func ClockFromContext(ctx context.Context) Clock {
+	clock, _ := ctx.Value(clockKey{}).(Clock)
+	return clockOrSystem(clock)
+}
+
+// middlewareContext returns the context middleware run with, carrying the tool name and the clock
+func middlewareContext(ctx context.Context, name string, opts toolOptions) context.Context {
+	ctx = context.WithValue(ctx, toolNameKey{}, name)
+	return context.WithValue(ctx, clockKey{}, clockOrSystem(opts.clock))
+}
+
+// chainMiddleware wraps handler so the first middleware runs first
+func chainMiddleware(handler ToolHandler, middleware []ToolMiddleware) ToolHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// LoggingMiddleware logs each built-in tool call with its duration and error, through a function such as log.Printf.
+// Arguments are not logged, they may hold typed text.
+func LoggingMiddleware(logf func(format string, args ...any)) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			name, _ := ToolNameFromContext(ctx)
+			clock := ClockFromContext(ctx)
+			start := clock.Now()
+			result, err := next(ctx, session, args)
+			elapsed := clock.Now().Sub(start).Round(time.Millisecond)
+			if err != nil {
+				logf("%s failed after %s: %v", name, elapsed, err)
+			} else {
+				logf("%s took %s", name, elapsed)
+			}
+			return result, err
+		}
+	}
+}
+
+// DelayMiddleware waits d before each built-in tool call, e.g. to be polite to the sites visited.
+// It waits on the run's clock, see ClockFromContext.
+func DelayMiddleware(d time.Duration) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			if err := ClockFromContext(ctx).Sleep(ctx, d); err != nil {
+				return nil, err
+			}
+			return next(ctx, session, args)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package geminirod
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelayMiddlewareUsesRunClock(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+	)
+	config, _ := testConfig(t, backend)
+	clock := newFakeClock()
+	config.Clock = clock
+	// An hour on the system clock would time the test out
+	config.ToolMiddleware = []ToolMiddleware{DelayMiddleware(time.Hour)}
+
+	var events []Event
+	withinTimeout(t, func() { events = collectEvents(t, context.Background(), config, nil) })
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if !slices.Contains(clock.sleeps(), time.Hour) {
+		t.Errorf("slept %v on the run's clock, want the delay", clock.sleeps())
+	}
+}
+
+func TestLoggingMiddlewareUsesRunClock(t *testing.T) {
+	session, _ := newFakeSession(t)
+	clock := newFakeClock()
+	var logged []string
+	logf := func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+	opts := toolOptions{
+		clock: clock,
+		// The delay runs inside the logged call, so it is part of the duration logged
+		middleware: []ToolMiddleware{LoggingMiddleware(logf), DelayMiddleware(2 * time.Second)},
+	}
+
+	if _, err := handleBuiltInTool(context.Background(), session, "click_at", map[string]any{"x": 10.0, "y": 10.0}, nil, opts); err != nil {
+		t.Fatalf("handleBuiltInTool: %v", err)
+	}
+	if len(logged) != 1 || logged[0] != "click_at took 2s" {
+		t.Errorf("logged %q, want the tool and its duration on the run's clock", logged)
+	}
+}
+
+func TestClockFromContext(t *testing.T) {
+	if _, ok := ClockFromContext(context.Background()).(systemClock); !ok {
+		t.Error("ClockFromContext outside a run is not the system clock")
+	}
+
+	clock := newFakeClock()
+	var got Clock
+	var name string
+	spy := func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			got = ClockFromContext(ctx)
+			name, _ = ToolNameFromContext(ctx)
+			return next(ctx, session, args)
+		}
+	}
+	session, _ := newFakeSession(t)
+	opts := toolOptions{clock: clock, middleware: []ToolMiddleware{spy}}
+	if _, err := handleBuiltInTool(context.Background(), session, "go_back", nil, nil, opts); err != nil {
+		t.Fatalf("handleBuiltInTool: %v", err)
+	}
+	if got != Clock(clock) || name != "go_back" {
+		t.Errorf("middleware saw clock %T and tool %q, want the run's clock and go_back", got, name)
+	}
+}
+
+func TestMiddlewareOrderAndShortCircuit(t *testing.T) {
+	session, browser := newFakeSession(t)
+	var order []string
+	trace := func(label string) ToolMiddleware {
+		return func(next ToolHandler) ToolHandler {
+			return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+				order = append(order, label)
+				return next(ctx, session, args)
+			}
+		}
+	}
+	block := func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			return map[string]any{"skipped": true}, nil
+		}
+	}
+	opts := toolOptions{clock: newFakeClock(), middleware: []ToolMiddleware{trace("outer"), trace("inner"), block}}
+
+	part, err := handleBuiltInTool(context.Background(), session, "click_at", map[string]any{"x": 10.0, "y": 10.0}, nil, opts)
+	if err != nil {
+		t.Fatalf("handleBuiltInTool: %v", err)
+	}
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Errorf("middleware ran in order %v, want the first outermost", order)
+	}
+	if len(browser.recorded("ClickAt")) != 0 || part.FunctionResponse.Response["skipped"] != true {
+		t.Errorf("response = %v, want the middleware's answer without clicking", part.FunctionResponse.Response)
+	}
+}
@@ -0,0 +1,103 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// DefaultModel is the model used when StartLoopConfig.Model is empty
+const DefaultModel = "gemini-2.5-computer-use-preview-10-2025"
+
+// modelNotFoundHint is appended to model not found errors
+const modelNotFoundHint = "use ListSupportedModels to see the available computer use models"
+
+// modelAliases maps common shorthands and truncations to full model names
+var modelAliases = map[string]string{
+	"computer-use":                    DefaultModel,
+	"gemini-computer-use":             DefaultModel,
+	"gemini-2.5-computer-use":         DefaultModel,
+	"gemini-2.5-computer-use-preview": DefaultModel,
+}
+
+// CanonicalModel resolves a model alias to its full name, stripping a "models/" prefix.
+// Unknown names are returned unchanged, so newer models keep working.
+func CanonicalModel(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "models/")
+	if canonical, ok := modelAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// ListSupportedModels lists the models available to the client that support computer use, sorted by name
+func ListSupportedModels(ctx context.Context, client *genai.Client) ([]string, error) {
+	var names []string
+	for model, err := range client.Models.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+		name := strings.TrimPrefix(model.Name, "models/")
+		if !strings.Contains(name, "computer-use") {
+			continue
+		}
+		if len(model.SupportedActions) > 0 && !slices.Contains(model.SupportedActions, "generateContent") {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Validate checks the config before a run and resolves Model aliases in place, see CanonicalModel.
+// With StrictModelCheck, the model must also be listed by ListSupportedModels. StartLoop calls it before the first turn.
+func (c *StartLoopConfig) Validate(ctx context.Context) error {
+	if c.GenaiClient == nil {
+		return errors.New("GenaiClient is required")
+	}
+	if c.ComputerUseSession == nil {
+		return errors.New("ComputerUseSession is required")
+	}
+
+	for _, name := range c.ExcludedTools {
+		if !IsBuiltInTool(name) {
+			return fmt.Errorf("ExcludedTools: %q is not a built-in tool, see BuiltInToolNames", name)
+		}
+	}
+	for name := range c.ToolOverrides {
+		if !IsBuiltInTool(name) {
+			return fmt.Errorf("ToolOverrides: %q is not a built-in tool, see BuiltInToolNames", name)
+		}
+	}
+	if c.MaxEstimatedCost > 0 && c.Pricing == (Pricing{}) {
+		return errors.New("MaxEstimatedCost requires Pricing")
+	}
+
+	if c.Model == "" {
+		c.Model = DefaultModel
+	}
+	c.Model = CanonicalModel(c.Model)
+
+	if c.StrictModelCheck {
+		supported, err := ListSupportedModels(ctx, c.GenaiClient)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(supported, c.Model) {
+			return fmt.Errorf("%w: %s is not a supported computer use model, %s", ErrModelNotFound, c.Model, modelNotFoundHint)
+		}
+	}
+	return nil
+}
+
+// isModelNotFoundError reports whether err is the API rejecting an unknown model
+func isModelNotFoundError(err error) bool {
+	var apiErr genai.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
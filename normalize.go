@@ -0,0 +1,97 @@
+package geminirod
+
+import (
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// normalizeArgs repairs args delivered as a single string field holding a JSON object,
+// e.g. {"__raw": "{\"x\":500,\"y\":300}"}, by parsing that object in their place.
+// It only applies to built-in tools, and only when the field is not one of the tool's arguments;
+// otherwise args are returned unchanged. Custom tools may legitimately take JSON strings.
+func normalizeArgs(name string, args map[string]any) (map[string]any, bool) {
+	if !IsBuiltInTool(name) || len(args) != 1 {
+		return args, false
+	}
+
+	for key, val := range args {
+		raw, ok := val.(string)
+		if !ok || isDeclaredArg(name, key) {
+			return args, false
+		}
+
+		var parsed map[string]any
+		if err := json.Unmarshal([]byte(raw), &parsed); err != nil || parsed == nil {
+			return args, false
+		}
+		return parsed, true
+	}
+	return args, false
+}
+
+// isDeclaredArg reports whether key is a declared argument of a built-in tool
+func isDeclaredArg(name, key string) bool {
+	declaration, ok := builtInToolDeclarations[name]
+	if !ok || declaration.Parameters == nil {
+		return false
+	}
+	_, ok = declaration.Parameters.Properties[key]
+	return ok
+}
+
+// argsFromText parses args a model put in its text instead of the call, e.g. "```json\n{\"x\":500,\"y\":300}\n```".
+// The answer text of content, thoughts excluded, must be one JSON object and nothing else.
+func argsFromText(content *genai.Content) (map[string]any, bool) {
+	if content == nil {
+		return nil, false
+	}
+	var text strings.Builder
+	for _, part := range content.Parts {
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+
+	payload := strings.TrimSpace(text.String())
+	if fenced, ok := strings.CutPrefix(payload, "```"); ok {
+		fenced = strings.TrimPrefix(fenced, "json")
+		payload = strings.TrimSpace(strings.TrimSuffix(fenced, "```"))
+	}
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(payload), &parsed); err != nil || len(parsed) == 0 {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// needsArgs reports whether a built-in tool has required arguments
+func needsArgs(name string) bool {
+	declaration, ok := builtInToolDeclarations[name]
+	return ok && declaration.Parameters != nil && len(declaration.Parameters.Required) > 0
+}
+
+// normalizeFunctionCalls repairs the args of each call, see normalizeArgs.
+// A lone built-in call missing the arguments it requires takes them from the text of content, see argsFromText.
+// Repaired calls are copied so the model's turn in history is left as it was.
+// It returns the names of the repaired calls.
+func normalizeFunctionCalls(functionCalls []*genai.FunctionCall, content *genai.Content) []string {
+	var repaired []string
+	for i, fc := range functionCalls {
+		args, ok := normalizeArgs(fc.Name, fc.Args)
+		if !ok && len(functionCalls) == 1 && len(fc.Args) == 0 && needsArgs(fc.Name) {
+			args, ok = argsFromText(content)
+		}
+		if !ok {
+			continue
+		}
+		functionCalls[i] = &genai.FunctionCall{
+			ID:   fc.ID,
+			Name: fc.Name,
+			Args: args,
+		}
+		repaired = append(repaired, fc.Name)
+	}
+	return repaired
+}
@@ -0,0 +1,115 @@
+package geminirod
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestNormalizeArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     string
+		args     map[string]any
+		want     map[string]any
+		repaired bool
+	}{
+		{
+			name: "JSON string blob", tool: "click_at",
+			args: map[string]any{"__raw": `{"x":500,"y":300}`},
+			want: map[string]any{"x": 500.0, "y": 300.0}, repaired: true,
+		},
+		{
+			name: "declared string argument", tool: "navigate",
+			args: map[string]any{"url": `{"x":1}`},
+			want: map[string]any{"url": `{"x":1}`},
+		},
+		{
+			name: "custom tool", tool: "lookup",
+			args: map[string]any{"query": `{"x":1}`},
+			want: map[string]any{"query": `{"x":1}`},
+		},
+		{
+			name: "not JSON", tool: "click_at",
+			args: map[string]any{"__raw": "x=500"},
+			want: map[string]any{"__raw": "x=500"},
+		},
+		{
+			name: "several fields", tool: "click_at",
+			args: map[string]any{"x": 1.0, "y": 2.0},
+			want: map[string]any{"x": 1.0, "y": 2.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repaired := normalizeArgs(tt.tool, tt.args)
+			if repaired != tt.repaired || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeArgs = %v, %v, want %v, %v", got, repaired, tt.want, tt.repaired)
+			}
+		})
+	}
+}
+
+func TestNormalizeFunctionCallsArgsInText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		calls []*genai.FunctionCall
+		want  map[string]any // Args of the first call after repair, nil if it is left alone
+	}{
+		{name: "plain JSON", text: `{"x": 500, "y": 300}`, calls: []*genai.FunctionCall{{Name: "click_at"}},
+			want: map[string]any{"x": 500.0, "y": 300.0}},
+		{name: "fenced JSON", text: "```json\n{\"url\": \"https://example.com\"}\n```", calls: []*genai.FunctionCall{{Name: "navigate"}},
+			want: map[string]any{"url": "https://example.com"}},
+		{name: "prose", text: "I will click the button.", calls: []*genai.FunctionCall{{Name: "click_at"}}},
+		{name: "tool without required args", text: `{"x": 1}`, calls: []*genai.FunctionCall{{Name: "go_back"}}},
+		{name: "custom tool", text: `{"x": 1}`, calls: []*genai.FunctionCall{{Name: "lookup"}}},
+		{name: "several calls", text: `{"x": 1, "y": 2}`, calls: []*genai.FunctionCall{{Name: "click_at"}, {Name: "go_back"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.calls[0]
+			content := &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+				{Text: "thinking about " + `{"y": 9}`, Thought: true},
+				{Text: tt.text},
+			}}
+			repaired := normalizeFunctionCalls(tt.calls, content)
+			if tt.want == nil {
+				if len(repaired) != 0 || tt.calls[0] != original {
+					t.Errorf("repaired %v, want the calls left alone", repaired)
+				}
+				return
+			}
+			if len(repaired) != 1 || !reflect.DeepEqual(tt.calls[0].Args, tt.want) {
+				t.Errorf("repaired %v with args %v, want %v", repaired, tt.calls[0].Args, tt.want)
+			}
+			if original.Args != nil {
+				t.Error("the call in history was modified")
+			}
+		})
+	}
+}
+
+func TestLoopRepairsArgsInText(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: modelResponse(
+			&genai.Part{Text: `{"url": "https://example.com/"}`},
+			&genai.Part{FunctionCall: &genai.FunctionCall{Name: "navigate"}},
+		)},
+	)
+	config, browser := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := len(eventsOf[WarningEvent](events)); got != 1 {
+		t.Errorf("got %d warnings, want one about the repair", got)
+	}
+	navigations := browser.recorded("Navigate")
+	if len(navigations) != 1 || navigations[0].Args[0] != "https://example.com/" {
+		t.Errorf("navigations = %+v, want one to the URL from the text", navigations)
+	}
+}
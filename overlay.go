@@ -0,0 +1,124 @@
+package geminirod
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+)
+
+// GridOverlayMode selects the coordinate hints drawn on screenshots sent to the model
+type GridOverlayMode int
+
+const (
+	GridOverlayOff     GridOverlayMode = iota // Screenshots are sent unchanged
+	GridOverlayCorners                        // Ruler ticks and labels along the edges only
+	GridOverlayFull                           // Faint grid lines across the page, labeled along the top and left edges
+)
+
+// GridOverlay configures coordinate hints drawn on screenshots, labeled in the 0-999 units the model outputs.
+// Only the copy sent to the model is changed, compare runs with and without it to measure its effect
+type GridOverlay struct {
+	Mode    GridOverlayMode
+	Spacing int // Distance between grid lines on the 0-999 grid. Default: 100
+}
+
+// Overlay drawing parameters
+const (
+	defaultGridSpacing = 100
+	overlayTickLength  = 8
+	overlayGlyphScale  = 2
+)
+
+var (
+	overlayLineColor  = color.NRGBA{R: 255, G: 0, B: 255, A: 70}
+	overlayTickColor  = color.NRGBA{R: 255, G: 0, B: 255, A: 200}
+	overlayLabelColor = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	overlayLabelBack  = color.NRGBA{R: 128, G: 0, B: 128, A: 200}
+)
+
+// overlayDigits are 3x5 bitmaps of the digits, one row per string
+var overlayDigits = [10][5]string{
+	{"###", "#.#", "#.#", "#.#", "###"},
+	{".#.", "##.", ".#.", ".#.", "###"},
+	{"###", "..#", "###", "#..", "###"},
+	{"###", "..#", "###", "..#", "###"},
+	{"#.#", "#.#", "###", "..#", "..#"},
+	{"###", "#..", "###", "..#", "###"},
+	{"###", "#..", "###", "#.#", "###"},
+	{"###", "..#", ".#.", ".#.", ".#."},
+	{"###", "#.#", "###", "#.#", "###"},
+	{"###", "#.#", "###", "..#", "###"},
+}
+
+// apply draws the overlay on a PNG screenshot and returns the new PNG.
+// The screenshot is returned unchanged when the overlay is off or the image cannot be processed.
+func (o GridOverlay) apply(screenshot []byte) []byte {
+	if o.Mode == GridOverlayOff {
+		return screenshot
+	}
+	spacing := o.Spacing
+	if spacing <= 0 {
+		spacing = defaultGridSpacing
+	}
+
+	src, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return screenshot
+	}
+	bounds := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Src)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	for unit := spacing; unit < normalizedGridSize; unit += spacing {
+		x := unit * width / normalizedGridSize
+		y := unit * height / normalizedGridSize
+		if o.Mode == GridOverlayFull {
+			fillRect(img, image.Rect(x, 0, x+1, height), overlayLineColor)
+			fillRect(img, image.Rect(0, y, width, y+1), overlayLineColor)
+		}
+
+		// Ticks on all four edges, labels on the top and left ones
+		fillRect(img, image.Rect(x, 0, x+1, overlayTickLength), overlayTickColor)
+		fillRect(img, image.Rect(x, height-overlayTickLength, x+1, height), overlayTickColor)
+		fillRect(img, image.Rect(0, y, overlayTickLength, y+1), overlayTickColor)
+		fillRect(img, image.Rect(width-overlayTickLength, y, width, y+1), overlayTickColor)
+		drawLabel(img, x+2, overlayTickLength, strconv.Itoa(unit))
+		drawLabel(img, overlayTickLength, y+2, strconv.Itoa(unit))
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return screenshot
+	}
+	return buf.Bytes()
+}
+
+// fillRect blends c over the rectangle
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r.Intersect(img.Bounds()), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// drawLabel draws a number with its top left corner at x, y on a contrasting background
+func drawLabel(img draw.Image, x, y int, text string) {
+	const glyphWidth, glyphHeight = 3 * overlayGlyphScale, 5 * overlayGlyphScale
+	const advance = glyphWidth + overlayGlyphScale
+
+	fillRect(img, image.Rect(x-1, y-1, x+len(text)*advance, y+glyphHeight+1), overlayLabelBack)
+	for i, digit := range text {
+		glyph := overlayDigits[digit-'0']
+		for row, line := range glyph {
+			for col, pixel := range line {
+				if pixel != '#' {
+					continue
+				}
+				px := x + i*advance + col*overlayGlyphScale
+				py := y + row*overlayGlyphScale
+				fillRect(img, image.Rect(px, py, px+overlayGlyphScale, py+overlayGlyphScale), overlayLabelColor)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package geminirod
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestGridOverlayApply(t *testing.T) {
+	screenshot := testPNG(t, 200, 200)
+	src, _ := png.Decode(bytes.NewReader(screenshot))
+
+	// Grid lines every 100 units fall every 20 pixels of a 200 pixel screenshot
+	tick := image.Pt(20, 0)        // Ruler tick on the top edge
+	line := image.Pt(60, 130)      // On a vertical grid line, away from the edges and labels
+	untouched := image.Pt(50, 130) // Between grid lines
+	tests := []struct {
+		name          string
+		overlay       GridOverlay
+		wantChanged   []image.Point
+		wantUnchanged []image.Point
+	}{
+		{name: "corners", overlay: GridOverlay{Mode: GridOverlayCorners}, wantChanged: []image.Point{tick}, wantUnchanged: []image.Point{line, untouched}},
+		{name: "full", overlay: GridOverlay{Mode: GridOverlayFull}, wantChanged: []image.Point{tick, line}, wantUnchanged: []image.Point{untouched}},
+		{name: "custom spacing", overlay: GridOverlay{Mode: GridOverlayFull, Spacing: 250}, wantChanged: []image.Point{image.Pt(50, 130)}, wantUnchanged: []image.Point{line}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := png.Decode(bytes.NewReader(tt.overlay.apply(screenshot)))
+			if err != nil {
+				t.Fatalf("overlaid screenshot is not a PNG: %v", err)
+			}
+			if img.Bounds() != src.Bounds() {
+				t.Errorf("bounds = %v, want the screenshot's %v", img.Bounds(), src.Bounds())
+			}
+			for _, p := range tt.wantChanged {
+				if img.At(p.X, p.Y) == src.At(p.X, p.Y) {
+					t.Errorf("pixel %v unchanged, want it drawn on", p)
+				}
+			}
+			for _, p := range tt.wantUnchanged {
+				if img.At(p.X, p.Y) != src.At(p.X, p.Y) {
+					t.Errorf("pixel %v changed, want it left as is", p)
+				}
+			}
+		})
+	}
+}
+
+func TestGridOverlayLeavesScreenshotUnchanged(t *testing.T) {
+	screenshot := testPNG(t, 50, 50)
+	if got := (GridOverlay{}).apply(screenshot); !bytes.Equal(got, screenshot) {
+		t.Error("overlay off changed the screenshot")
+	}
+	notPNG := []byte("not an image")
+	if got := (GridOverlay{Mode: GridOverlayFull}).apply(notPNG); !bytes.Equal(got, notPNG) {
+		t.Error("undecodable screenshot changed")
+	}
+}
+
+func TestGridOverlayInLoop(t *testing.T) {
+	backend := newFakeBackend(t, fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))})
+	config, browser := testConfig(t, backend)
+	screenshot := testPNG(t, 200, 200)
+	browser.setScreenshot(screenshot)
+	config.SkipInitialScreenshot = false
+	config.ScreenshotGridOverlay = GridOverlay{Mode: GridOverlayCorners}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// Both the initial screenshot and the one of the action are sent with the overlay
+	want := config.ScreenshotGridOverlay.apply(screenshot)
+	var sent [][]byte
+	for _, content := range backend.calls()[1].Contents {
+		for _, part := range content.Parts {
+			if isInlineImage(part) {
+				sent = append(sent, part.InlineData.Data)
+			}
+			if part.FunctionResponse != nil {
+				for _, attached := range part.FunctionResponse.Parts {
+					sent = append(sent, attached.InlineData.Data)
+				}
+			}
+		}
+	}
+	if len(sent) != 2 {
+		t.Fatalf("got %d screenshots, want the initial one and the click's", len(sent))
+	}
+	for i, data := range sent {
+		if !bytes.Equal(data, want) {
+			t.Errorf("screenshot %d sent without the overlay", i)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// allowlistNavigate overrides navigate to refuse hosts other than example.com
+func allowlistNavigate(t *testing.T) ToolHandler {
+	t.Helper()
+	navigate, ok := BuiltInToolHandler("navigate")
+	if !ok {
+		t.Fatal("BuiltInToolHandler(navigate) not found")
+	}
+	return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+		target, _ := args["url"].(string)
+		if parsed, err := url.Parse(target); err != nil || parsed.Host != "example.com" {
+			return nil, newToolError(ToolErrorInvalidArgument, "%s is not allowed", target)
+		}
+		return navigate(ctx, session, args)
+	}
+}
+
+func TestToolOverride(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantError bool
+	}{
+		{name: "allowed", url: "https://example.com/page"},
+		{name: "refused", url: "https://evil.example/", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, browser := newFakeSession(t)
+			opts := toolOptions{clock: newFakeClock(), overrides: map[string]ToolHandler{"navigate": allowlistNavigate(t)}}
+
+			part, err := handleBuiltInTool(context.Background(), session, "navigate", map[string]any{"url": tt.url}, nil, opts)
+			navigated := len(browser.recorded("Navigate")) > 0
+			if tt.wantError {
+				if err == nil || !strings.Contains(err.Error(), "is not allowed") {
+					t.Fatalf("error = %v, want the override's refusal", err)
+				}
+				if navigated {
+					t.Error("refused URL was navigated to")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handleBuiltInTool: %v", err)
+			}
+			if !navigated {
+				t.Error("the wrapped default handler did not navigate")
+			}
+			response := part.FunctionResponse
+			if response.Response["url"] != tt.url || len(response.Parts) != 1 {
+				t.Errorf("response = %+v, want the URL and the screenshot", response)
+			}
+		})
+	}
+}
+
+func TestToolOverrideGetsURLAndScreenshot(t *testing.T) {
+	session, browser := newFakeSession(t)
+	browser.setURL("https://example.com/current")
+	called := false
+	opts := toolOptions{clock: newFakeClock(), overrides: map[string]ToolHandler{
+		"click_at": func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			called = true
+			return map[string]any{"note": "logged"}, nil
+		},
+	}}
+
+	part, err := handleBuiltInTool(context.Background(), session, "click_at", map[string]any{"x": 10.0, "y": 10.0}, nil, opts)
+	if err != nil {
+		t.Fatalf("handleBuiltInTool: %v", err)
+	}
+	if !called || len(browser.recorded("ClickAt")) != 0 {
+		t.Error("override not used instead of the default click")
+	}
+	response := part.FunctionResponse
+	if response.Response["note"] != "logged" || response.Response["url"] != "https://example.com/current" || len(response.Parts) != 1 {
+		t.Errorf("response = %+v, want the override's fields, the URL and the screenshot", response)
+	}
+}
+
+func TestToolOverrideUnknownName(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+	config.ToolOverrides = map[string]ToolHandler{
+		"teleport": func(context.Context, BrowserSession, map[string]any) (map[string]any, error) {
+			return nil, errors.New("never called")
+		},
+	}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err == nil || !strings.Contains(err.Error(), `ToolOverrides: "teleport" is not a built-in tool`) {
+		t.Fatalf("error = %v, want the unknown override refused", err)
+	}
+	if calls := len(backend.calls()); calls != 0 {
+		t.Errorf("got %d model calls, want the run refused before the first", calls)
+	}
+	if _, ok := BuiltInToolHandler("teleport"); ok {
+		t.Error("BuiltInToolHandler found an unknown tool")
+	}
+}
+
+func TestToolOverrideInLoop(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("navigate", map[string]any{"url": "https://evil.example/"}))},
+	)
+	config, browser := testConfig(t, backend)
+	config.ToolOverrides = map[string]ToolHandler{"navigate": allowlistNavigate(t)}
+	config.ToolErrorMode = ToolErrorModeReport
+	config.MaxConsecutiveToolErrors = 3
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(browser.recorded("Navigate")) != 0 {
+		t.Error("refused URL was navigated to")
+	}
+	calls := backend.calls()
+	response := functionResponses(calls[1].Contents[len(calls[1].Contents)-1])["navigate"]
+	if response == nil || response.Response["code"] != ToolErrorInvalidArgument {
+		t.Errorf("navigate response = %+v, want the override's error reported", response)
+	}
+}
@@ -0,0 +1,52 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Preflight errors, reported in an ErrorEvent before the first turn
+var (
+	ErrInvalidCredentials = errors.New("invalid API credentials")
+	ErrModelNotFound      = errors.New("model not found")
+	ErrSessionDead        = errors.New("browser session is not usable")
+)
+
+// preflight validates credentials, model availability and the browser session
+// without spending any GenerateContent tokens. It returns the page it checked, reused as the initial screenshot.
+func preflight(ctx context.Context, client *genai.Client, model string, session BrowserSession) (pageState, error) {
+	if _, err := client.Models.Get(ctx, model, nil); err != nil {
+		return pageState{}, classifyPreflightError(model, err)
+	}
+
+	page, err := capturePage(session)
+	if err != nil {
+		return pageState{}, fmt.Errorf("%w: %v", ErrSessionDead, err)
+	}
+	return page, nil
+}
+
+// classifyPreflightError maps an API error from the models endpoint to a preflight error
+func classifyPreflightError(model string, err error) error {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("preflight check failed: %w", err)
+	}
+
+	switch {
+	case apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	// The Developer API reports a bad key as 400 INVALID_ARGUMENT
+	case apiErr.Code == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "api key"):
+		return fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	case apiErr.Code == http.StatusNotFound:
+		return fmt.Errorf("%w: %s (%s): %v", ErrModelNotFound, model, modelNotFoundHint, err)
+	default:
+		return fmt.Errorf("preflight check failed: %w", err)
+	}
+}
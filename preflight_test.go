@@ -0,0 +1,52 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPreflightScreenshotReusedForPrompt(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, browser := testConfig(t, backend)
+	config.SkipPreflight = false
+	config.SkipInitialScreenshot = false
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if got := len(browser.recorded("Screenshot")); got != 1 {
+		t.Errorf("took %d screenshots, want 1 shared by preflight and the prompt", got)
+	}
+	calls := backend.calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d model calls, want 1", len(calls))
+	}
+	prompt := calls[0].Contents[0]
+	var images int
+	for _, part := range prompt.Parts {
+		if part.InlineData != nil {
+			images++
+		}
+	}
+	if images != 1 {
+		t.Errorf("prompt has %d images, want the initial screenshot", images)
+	}
+}
+
+func TestPreflightDeadSession(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, browser := testConfig(t, backend)
+	config.SkipPreflight = false
+	browser.fail("Screenshot", errors.New("websocket: close 1006"))
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); !errors.Is(err, ErrSessionDead) {
+		t.Fatalf("error = %v, want ErrSessionDead", err)
+	}
+	if got := len(backend.calls()); got != 0 {
+		t.Errorf("got %d model calls, want none", got)
+	}
+}
@@ -0,0 +1,121 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+// CustomToolFunc executes a custom tool and returns its response fields
+type CustomToolFunc func(ctx context.Context, args map[string]any) (map[string]any, error)
+
+// ToolRegistry holds custom tools executed by the loop itself, see StartLoopConfig.Tools.
+// Its tools are declared to the model automatically; calls to them need no action from the subscriber
+// and get no screenshot. It is safe for concurrent use.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+}
+
+type registeredTool struct {
+	declaration *genai.FunctionDeclaration
+	fn          CustomToolFunc
+}
+
+// NewToolRegistry creates an empty ToolRegistry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]registeredTool)}
+}
+
+// loopToolNames are the tools the loop offers itself when enabled, which it dispatches before registered tools
+var loopToolNames = []string{undoToolName, findingToolName, captureToolName, screenshotToolName}
+
+// Register adds a tool under its declaration's name. Names of built-in tools, of tools the loop offers such as
+// report_finding, and names already registered are refused.
+func (r *ToolRegistry) Register(declaration *genai.FunctionDeclaration, fn CustomToolFunc) error {
+	if declaration == nil || declaration.Name == "" {
+		return errors.New("tool declaration must have a name")
+	}
+	if fn == nil {
+		return fmt.Errorf("tool %s has no function", declaration.Name)
+	}
+	if IsBuiltInTool(declaration.Name) {
+		return fmt.Errorf("tool %s is a built-in tool, use ToolOverrides to replace it", declaration.Name)
+	}
+	if slices.Contains(loopToolNames, declaration.Name) {
+		return fmt.Errorf("tool %s is offered by the loop and would never run", declaration.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[declaration.Name]; exists {
+		return fmt.Errorf("tool %s is already registered", declaration.Name)
+	}
+	r.tools[declaration.Name] = registeredTool{declaration: declaration, fn: fn}
+	return nil
+}
+
+// Declarations returns the declarations of the registered tools, sorted by name
+func (r *ToolRegistry) Declarations() []*genai.FunctionDeclaration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	declarations := make([]*genai.FunctionDeclaration, 0, len(names))
+	for _, name := range names {
+		declarations = append(declarations, r.tools[name].declaration)
+	}
+	return declarations
+}
+
+// Tool returns the registered tools as one genai.Tool, nil if there are none
+func (r *ToolRegistry) Tool() *genai.Tool {
+	declarations := r.Declarations()
+	if len(declarations) == 0 {
+		return nil
+	}
+	return &genai.Tool{FunctionDeclarations: declarations}
+}
+
+// tool is Tool for the loop, a nil registry has no tools
+func (r *ToolRegistry) tool() *genai.Tool {
+	if r == nil {
+		return nil
+	}
+	return r.Tool()
+}
+
+// lookup returns the function of a registered tool. A nil registry has no tools.
+func (r *ToolRegistry) lookup(name string) (CustomToolFunc, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool.fn, ok
+}
+
+// executeRegisteredTool runs a registered tool and returns its function response.
+// A failure is reported to the model rather than ending the run, unless the run was cancelled.
+func executeRegisteredTool(ctx context.Context, fn CustomToolFunc, name string, args map[string]any) (*genai.Part, error) {
+	response, err := fn(ctx, args)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		return genai.NewPartFromFunctionResponse(name, map[string]any{"error": err.Error()}), nil
+	}
+	if response == nil {
+		response = make(map[string]any)
+	}
+	return genai.NewPartFromFunctionResponse(name, response), nil
+}
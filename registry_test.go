@@ -0,0 +1,144 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// echoTool returns its args as the response
+func echoTool(ctx context.Context, args map[string]any) (map[string]any, error) {
+	return args, nil
+}
+
+func TestToolRegistryRefusesNames(t *testing.T) {
+	type refusal struct {
+		name        string
+		declaration *genai.FunctionDeclaration
+		fn          CustomToolFunc
+		wantErr     string
+	}
+	tests := []refusal{
+		{name: "no declaration", fn: echoTool, wantErr: "must have a name"},
+		{name: "no name", declaration: &genai.FunctionDeclaration{}, fn: echoTool, wantErr: "must have a name"},
+		{name: "no function", declaration: &genai.FunctionDeclaration{Name: "lookup"}, wantErr: "has no function"},
+		{name: "built-in tool", declaration: &genai.FunctionDeclaration{Name: "click_at"}, fn: echoTool, wantErr: "built-in tool"},
+		{name: "duplicate", declaration: &genai.FunctionDeclaration{Name: "lookup"}, fn: echoTool, wantErr: "already registered"},
+	}
+	for _, name := range loopToolNames {
+		tests = append(tests, refusal{name: "loop tool " + name, declaration: &genai.FunctionDeclaration{Name: name}, fn: echoTool, wantErr: "offered by the loop"})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewToolRegistry()
+			if err := registry.Register(&genai.FunctionDeclaration{Name: "lookup"}, echoTool); err != nil {
+				t.Fatalf("registering lookup: %v", err)
+			}
+
+			err := registry.Register(tt.declaration, tt.fn)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+			if len(registry.Declarations()) != 1 {
+				t.Errorf("registry has %d tools after a refused one, want 1", len(registry.Declarations()))
+			}
+		})
+	}
+}
+
+func TestToolRegistryDeclarationsSorted(t *testing.T) {
+	registry := NewToolRegistry()
+	if registry.Tool() != nil {
+		t.Error("Tool() of an empty registry is not nil")
+	}
+	for _, name := range []string{"weather", "currency", "lookup"} {
+		if err := registry.Register(&genai.FunctionDeclaration{Name: name}, echoTool); err != nil {
+			t.Fatalf("registering %s: %v", name, err)
+		}
+	}
+
+	var names []string
+	for _, declaration := range registry.Tool().FunctionDeclarations {
+		names = append(names, declaration.Name)
+	}
+	if strings.Join(names, ",") != "currency,lookup,weather" {
+		t.Errorf("declarations = %v, want them sorted by name", names)
+	}
+}
+
+func TestToolRegistryInLoop(t *testing.T) {
+	tests := []struct {
+		name         string
+		fn           CustomToolFunc
+		wantResponse map[string]any
+	}{
+		{
+			name:         "dispatched",
+			fn:           echoTool,
+			wantResponse: map[string]any{"query": "rates"},
+		},
+		{
+			name: "error reported to the model",
+			fn: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+				return nil, errors.New("service unavailable")
+			},
+			wantResponse: map[string]any{"error": "service unavailable"},
+		},
+		{
+			name: "nil response",
+			fn: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+				return nil, nil
+			},
+			wantResponse: map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewToolRegistry()
+			if err := registry.Register(&genai.FunctionDeclaration{Name: "lookup"}, tt.fn); err != nil {
+				t.Fatalf("registering lookup: %v", err)
+			}
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(call("lookup", map[string]any{"query": "rates"}))})
+			config, _ := testConfig(t, backend)
+			config.Tools = registry
+
+			// Registered tools need no answer from the subscriber
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			for _, progress := range eventsOf[ProgressEvent](events) {
+				for _, fc := range progress.FunctionCalls {
+					if fc.NeedsAction() {
+						t.Errorf("call %s needs action, want it executed by the loop", fc.FunctionName)
+					}
+				}
+			}
+
+			calls := backend.calls()
+			if len(calls) != 2 {
+				t.Fatalf("got %d model calls, want 2", len(calls))
+			}
+			if !strings.Contains(strings.Join(calls[0].declaredFunctions(), ","), "lookup") {
+				t.Errorf("declared functions = %v, want lookup", calls[0].declaredFunctions())
+			}
+			history := calls[1].Contents
+			response := functionResponses(history[len(history)-1])["lookup"]
+			if response == nil {
+				t.Fatal("lookup response missing from the second request")
+			}
+			if len(response.Response) != len(tt.wantResponse) {
+				t.Fatalf("response = %v, want %v", response.Response, tt.wantResponse)
+			}
+			for key, want := range tt.wantResponse {
+				if response.Response[key] != want {
+					t.Errorf("response = %v, want %v", response.Response, tt.wantResponse)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestCustomToolRejection(t *testing.T) {
+	refusal := errors.New("not now")
+	tests := []struct {
+		name      string
+		answer    func(*FunctionCall)
+		wantFatal bool
+		wantError string
+	}{
+		{name: "reject ends the run", answer: func(fc *FunctionCall) { fc.Reject(refusal) }, wantFatal: true},
+		{name: "reject and continue", answer: func(fc *FunctionCall) { fc.RejectAndContinue(refusal) }, wantError: "rejected by user: not now"},
+		{name: "reject and continue without a reason", answer: func(fc *FunctionCall) { fc.RejectAndContinue(nil) }, wantError: "rejected by user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(call("send_email", map[string]any{"to": "team@example.com"}))})
+			config, _ := testConfig(t, backend)
+			config.ExtraTools = []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "send_email"}}}}
+
+			events := collectEvents(t, context.Background(), config, tt.answer)
+			err := finalError(events)
+			if tt.wantFatal {
+				if !errors.Is(err, refusal) {
+					t.Fatalf("error = %v, want the rejection", err)
+				}
+				if got := len(backend.calls()); got != 1 {
+					t.Errorf("got %d model calls, want the run ended after the rejection", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			// The model is told about the rejection and gets another turn
+			calls := backend.calls()
+			if len(calls) != 2 {
+				t.Fatalf("got %d model calls, want 2", len(calls))
+			}
+			history := calls[1].Contents
+			response := functionResponses(history[len(history)-1])["send_email"]
+			if response == nil || response.Response["error"] != tt.wantError {
+				t.Errorf("send_email response = %+v, want the error %q", response, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Package remotetool forwards custom tool calls to a remote worker over a caller-provided transport.
+package remotetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	geminirod "github.com/PeronGH/gemini-rod"
+)
+
+// ErrTimeout is used to reject a call when the remote worker does not answer in time
+var ErrTimeout = errors.New("remote tool call timed out")
+
+// ErrBridgeClosed is used to reject the calls in flight when Run returns, and the calls made after
+var ErrBridgeClosed = errors.New("remote tool bridge closed")
+
+// Request is the JSON message sent to the remote worker for each forwarded call
+type Request struct {
+	ID           string         `json:"id"`
+	FunctionName string         `json:"function_name"`
+	Args         map[string]any `json:"args"`
+}
+
+// Response is the JSON message the remote worker sends back.
+// A non-empty Error rejects the call; otherwise Response is passed to the model.
+type Response struct {
+	ID       string         `json:"id"`
+	Response map[string]any `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Transport carries encoded messages to and from the remote worker
+type Transport interface {
+	Send(ctx context.Context, message []byte) error
+	Receive(ctx context.Context) ([]byte, error)
+}
+
+// RemoteToolBridge forwards NeedsAction function calls to a remote worker
+// and feeds the responses back, correlating them by call ID
+type RemoteToolBridge struct {
+	transport Transport
+	timeout   time.Duration
+	mode      geminirod.ToolErrorMode
+
+	nextID  atomic.Uint64
+	mu      sync.Mutex
+	pending map[string]chan result
+	closed  error // Why Run returned, nil while it runs
+}
+
+// result is what a waiting call receives: the worker's response, or the error that closed the bridge
+type result struct {
+	resp Response
+	err  error
+}
+
+// NewRemoteToolBridge creates a bridge over transport. Calls not answered within timeout are rejected, 0 = no timeout.
+// mode selects how failed calls are rejected: ToolErrorModeReport sends the error to the model with RejectAndContinue,
+// ToolErrorModeAbort ends the run with Reject. Run must be running for responses to be delivered.
+func NewRemoteToolBridge(transport Transport, timeout time.Duration, mode geminirod.ToolErrorMode) *RemoteToolBridge {
+	return &RemoteToolBridge{
+		transport: transport,
+		timeout:   timeout,
+		mode:      mode,
+		pending:   make(map[string]chan result),
+	}
+}
+
+// Run receives responses from the transport and dispatches them to the waiting calls.
+// It returns when ctx is done or the transport fails, rejecting the calls in flight and any made afterwards.
+func (b *RemoteToolBridge) Run(ctx context.Context) error {
+	for {
+		message, err := b.transport.Receive(ctx)
+		if err != nil {
+			err = fmt.Errorf("error receiving from transport: %w", err)
+			b.close(err)
+			return err
+		}
+
+		var resp Response
+		if err := json.Unmarshal(message, &resp); err != nil {
+			// Skip malformed messages rather than tearing down the bridge
+			continue
+		}
+
+		b.mu.Lock()
+		respChan, ok := b.pending[resp.ID]
+		delete(b.pending, resp.ID)
+		b.mu.Unlock()
+
+		// Late responses for calls that already timed out are dropped
+		if ok {
+			respChan <- result{resp: resp}
+		}
+	}
+}
+
+// close fails the calls in flight with err and makes later calls fail fast
+func (b *RemoteToolBridge) close(err error) {
+	err = fmt.Errorf("%w: %w", ErrBridgeClosed, err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = err
+	for id, respChan := range b.pending {
+		respChan <- result{err: err}
+		delete(b.pending, id)
+	}
+}
+
+// Handle forwards fc to the remote worker and blocks until it is answered, rejected or timed out.
+// Transport failures and timeouts reject the call. Function calls that do not need action are ignored.
+func (b *RemoteToolBridge) Handle(ctx context.Context, fc *geminirod.FunctionCall) {
+	if !fc.NeedsAction() {
+		return
+	}
+
+	resp, err := b.call(ctx, fc)
+	if err != nil {
+		b.reject(fc, err)
+		return
+	}
+	if resp.Error != "" {
+		b.reject(fc, errors.New(resp.Error))
+		return
+	}
+	if resp.Response == nil {
+		resp.Response = map[string]any{}
+	}
+	fc.Respond(resp.Response)
+}
+
+// reject rejects fc according to the bridge's error mode
+func (b *RemoteToolBridge) reject(fc *geminirod.FunctionCall, err error) {
+	if b.mode == geminirod.ToolErrorModeReport {
+		fc.RejectAndContinue(err)
+		return
+	}
+	fc.Reject(err)
+}
+
+// call sends a request and waits for the correlated response
+func (b *RemoteToolBridge) call(ctx context.Context, fc *geminirod.FunctionCall) (Response, error) {
+	id := strconv.FormatUint(b.nextID.Add(1), 10)
+	respChan := make(chan result, 1)
+
+	b.mu.Lock()
+	if b.closed != nil {
+		err := b.closed
+		b.mu.Unlock()
+		return Response{}, err
+	}
+	b.pending[id] = respChan
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, id)
+		b.mu.Unlock()
+	}()
+
+	message, err := json.Marshal(Request{
+		ID:           id,
+		FunctionName: fc.FunctionName,
+		Args:         fc.Args,
+	})
+	if err != nil {
+		return Response{}, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+
+	if err := b.transport.Send(ctx, message); err != nil {
+		return Response{}, fmt.Errorf("error sending to transport: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Response{}, fmt.Errorf("%w: %s", ErrTimeout, fc.FunctionName)
+		}
+		return Response{}, ctx.Err()
+	case res := <-respChan:
+		return res.resp, res.err
+	}
+}
@@ -0,0 +1,228 @@
+package remotetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	geminirod "github.com/PeronGH/gemini-rod"
+)
+
+// outcome records how a function call was answered
+type outcome struct {
+	response map[string]any
+	err      error
+}
+
+// newCall creates a function call that needs action, recording its outcome
+func newCall(name string, args map[string]any) (*geminirod.FunctionCall, *outcome) {
+	out := &outcome{}
+	fc := geminirod.NewFunctionCall(name, args,
+		func(response map[string]any) { out.response = response },
+		func(err error) { out.err = err },
+	)
+	return fc, out
+}
+
+// startBridge runs a bridge over a channel transport pair and returns it with the worker's end
+func startBridge(t *testing.T, timeout time.Duration, mode geminirod.ToolErrorMode) (*RemoteToolBridge, *ChannelTransport, <-chan error) {
+	t.Helper()
+	bridgeEnd, workerEnd := NewChannelTransportPair()
+	t.Cleanup(func() { _ = workerEnd.Close() })
+
+	bridge := NewRemoteToolBridge(bridgeEnd, timeout, mode)
+	done := make(chan error, 1)
+	go func() { done <- bridge.Run(context.Background()) }()
+	return bridge, workerEnd, done
+}
+
+// serve answers each request received by the worker with reply, until the transport closes
+func serve(worker *ChannelTransport, reply func(Request) Response) {
+	ctx := context.Background()
+	for {
+		message, err := worker.Receive(ctx)
+		if err != nil {
+			return
+		}
+		var req Request
+		if err := json.Unmarshal(message, &req); err != nil {
+			return
+		}
+		resp := reply(req)
+		resp.ID = req.ID
+		encoded, _ := json.Marshal(resp)
+		if err := worker.Send(ctx, encoded); err != nil {
+			return
+		}
+	}
+}
+
+func TestRemoteToolBridgeAnswers(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         geminirod.ToolErrorMode
+		reply        Response
+		wantResponse map[string]any
+		wantErr      string // Substring of the error passed to Reject
+	}{
+		{
+			name:         "response",
+			reply:        Response{Response: map[string]any{"rows": 3.0}},
+			wantResponse: map[string]any{"rows": 3.0},
+		},
+		{
+			name:         "empty response",
+			wantResponse: map[string]any{},
+		},
+		{
+			name:         "remote error reported to the model",
+			mode:         geminirod.ToolErrorModeReport,
+			reply:        Response{Error: "table not found"},
+			wantResponse: map[string]any{"error": "rejected by user: table not found"},
+		},
+		{
+			name:    "remote error ending the run",
+			mode:    geminirod.ToolErrorModeAbort,
+			reply:   Response{Error: "table not found"},
+			wantErr: "table not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bridge, worker, _ := startBridge(t, 0, tt.mode)
+			var got Request
+			go serve(worker, func(req Request) Response {
+				got = req
+				return tt.reply
+			})
+
+			fc, out := newCall("query_table", map[string]any{"table": "users"})
+			bridge.Handle(context.Background(), fc)
+
+			if got.FunctionName != "query_table" || got.Args["table"] != "users" {
+				t.Errorf("request = %+v, want the call's name and args", got)
+			}
+			if tt.wantErr != "" {
+				if out.err == nil || !strings.Contains(out.err.Error(), tt.wantErr) {
+					t.Errorf("rejection = %v, want %q", out.err, tt.wantErr)
+				}
+				return
+			}
+			if out.err != nil || !equalResponses(out.response, tt.wantResponse) {
+				t.Errorf("outcome = %+v, want response %v", out, tt.wantResponse)
+			}
+		})
+	}
+}
+
+func TestRemoteToolBridgeTimeout(t *testing.T) {
+	bridge, worker, _ := startBridge(t, 10*time.Millisecond, geminirod.ToolErrorModeReport)
+	// The worker reads requests but never answers
+	go func() {
+		for {
+			if _, err := worker.Receive(context.Background()); err != nil {
+				return
+			}
+		}
+	}()
+
+	fc, out := newCall("query_table", nil)
+	bridge.Handle(context.Background(), fc)
+	if out.err != nil {
+		t.Fatalf("run ended with %v, want the timeout reported to the model", out.err)
+	}
+	if message, _ := out.response["error"].(string); !strings.Contains(message, ErrTimeout.Error()) {
+		t.Errorf("response = %v, want the timeout", out.response)
+	}
+}
+
+func TestRemoteToolBridgeTransportClosed(t *testing.T) {
+	tests := []struct {
+		name string
+		mode geminirod.ToolErrorMode
+	}{
+		{name: "report", mode: geminirod.ToolErrorModeReport},
+		{name: "abort", mode: geminirod.ToolErrorModeAbort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bridge, worker, done := startBridge(t, 0, tt.mode)
+			// The worker takes the requests, then the transport closes with them in flight
+			received := make(chan struct{})
+			go func() {
+				for range 2 {
+					if _, err := worker.Receive(context.Background()); err != nil {
+						return
+					}
+				}
+				close(received)
+			}()
+
+			outs := make(chan *outcome, 2)
+			for range 2 {
+				go func() {
+					fc, out := newCall("query_table", nil)
+					bridge.Handle(context.Background(), fc)
+					outs <- out
+				}()
+			}
+			<-received
+			_ = worker.Close()
+
+			if err := <-done; !errors.Is(err, ErrTransportClosed) {
+				t.Errorf("Run returned %v, want ErrTransportClosed", err)
+			}
+			for range 2 {
+				select {
+				case out := <-outs:
+					checkClosed(t, tt.mode, out)
+				case <-time.After(time.Second):
+					t.Fatal("call in flight not rejected after the transport closed")
+				}
+			}
+
+			// Calls made after Run returned fail fast
+			fc, out := newCall("query_table", nil)
+			bridge.Handle(context.Background(), fc)
+			checkClosed(t, tt.mode, out)
+		})
+	}
+}
+
+func TestRemoteToolBridgeIgnoresCallsWithoutAction(t *testing.T) {
+	bridge, _, _ := startBridge(t, 0, geminirod.ToolErrorModeAbort)
+	// Would block on Send if forwarded, as the worker never reads
+	bridge.Handle(context.Background(), geminirod.NewFunctionCall("click_at", nil, nil, nil))
+}
+
+// checkClosed checks that a call was rejected with ErrBridgeClosed according to mode
+func checkClosed(t *testing.T, mode geminirod.ToolErrorMode, out *outcome) {
+	t.Helper()
+	if mode == geminirod.ToolErrorModeAbort {
+		if !errors.Is(out.err, ErrBridgeClosed) {
+			t.Errorf("rejection = %v, want ErrBridgeClosed", out.err)
+		}
+		return
+	}
+	if out.err != nil {
+		t.Errorf("run ended with %v, want the failure reported to the model", out.err)
+	}
+	if message, _ := out.response["error"].(string); !strings.Contains(message, ErrBridgeClosed.Error()) {
+		t.Errorf("response = %v, want ErrBridgeClosed", out.response)
+	}
+}
+
+func equalResponses(a, b map[string]any) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package remotetool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTransportClosed is returned by a ChannelTransport once either end is closed
+var ErrTransportClosed = errors.New("transport closed")
+
+// ChannelTransport is an in-process Transport backed by a pair of channels
+type ChannelTransport struct {
+	send    chan<- []byte
+	receive <-chan []byte
+	done    chan struct{} // Shared by both ends
+	once    *sync.Once
+}
+
+// NewChannelTransportPair creates two connected transports: messages sent on one are received on the other.
+// Give one to the bridge and use the other to implement the worker.
+func NewChannelTransportPair() (*ChannelTransport, *ChannelTransport) {
+	a := make(chan []byte)
+	b := make(chan []byte)
+	done := make(chan struct{})
+	once := &sync.Once{}
+	return &ChannelTransport{send: a, receive: b, done: done, once: once},
+		&ChannelTransport{send: b, receive: a, done: done, once: once}
+}
+
+// Send delivers a message to the other end
+func (t *ChannelTransport) Send(ctx context.Context, message []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.done:
+		return ErrTransportClosed
+	case t.send <- message:
+		return nil
+	}
+}
+
+// Receive waits for a message from the other end
+func (t *ChannelTransport) Receive(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.done:
+		return nil, ErrTransportClosed
+	case message := <-t.receive:
+		return message, nil
+	}
+}
+
+// Close closes both ends: pending and later Send and Receive calls fail with ErrTransportClosed
+func (t *ChannelTransport) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
@@ -0,0 +1,63 @@
+package geminirod
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScreenshotRetention(t *testing.T) {
+	tests := []struct {
+		name                 string
+		maxRecentScreenshots int
+		retainNone           bool
+		want                 int
+	}{
+		{name: "default", want: 3},
+		{name: "explicit limit", maxRecentScreenshots: 2, want: 2},
+		{name: "unlimited", maxRecentScreenshots: -1, want: 5},
+		{name: "retain none", retainNone: true, want: 1},
+		{name: "retain none overrides the limit", maxRecentScreenshots: -1, retainNone: true, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var replies []fakeReply
+			for i := range 5 {
+				replies = append(replies, fakeReply{Response: callResponse(call("click_at", map[string]any{"x": float64(10 * i), "y": 10.0}))})
+			}
+			backend := newFakeBackend(t, replies...)
+			config, _ := testConfig(t, backend)
+			config.MaxRecentScreenshots = tt.maxRecentScreenshots
+			config.RetainNoScreenshots = tt.retainNone
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			// The last request follows five turns with a screenshot each, only the latest ones are kept
+			calls := backend.calls()
+			contents := calls[len(calls)-1].Contents
+			screenshots := 0
+			for _, content := range contents {
+				for _, part := range content.Parts {
+					if hasScreenshotParts(part) {
+						screenshots++
+					}
+				}
+			}
+			if screenshots != tt.want {
+				t.Errorf("last request holds %d screenshots, want %d", screenshots, tt.want)
+			}
+			if !hasScreenshotParts(contents[len(contents)-1].Parts[0]) {
+				t.Error("screenshot of the current turn dropped")
+			}
+			pruned := 0
+			for _, event := range eventsOf[PruneEvent](events) {
+				pruned += event.ScreenshotsRemoved
+			}
+			if want := 5 - tt.want; pruned != want {
+				t.Errorf("PruneEvents removed %d screenshots, want %d", pruned, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Transient error retry settings for built-in tools
+const (
+	maxTransientRetries = 2
+	transientRetryDelay = 200 * time.Millisecond
+)
+
+// idempotentTools can be repeated without changing the outcome, so they are retried on transient errors.
+// Clicks, typing, key presses and drags are not, a retried click may submit a form twice.
+var idempotentTools = map[string]bool{
+	"open_web_browser": true,
+	"wait_5_seconds":   true,
+	"go_back":          true,
+	"go_forward":       true,
+	"search":           true,
+	"navigate":         true,
+	"hover_at":         true,
+	"scroll_document":  true,
+	"scroll_at":        true,
+}
+
+// transientErrorMessages are fragments of browser errors that usually go away on an immediate retry
+var transientErrorMessages = []string{
+	"node not found",
+	"detached",
+	"execution context was destroyed",
+	"cannot find context with specified id",
+	"target closed",
+}
+
+// isTransientError reports whether err is a browser error worth retrying.
+// Tool errors are never transient: they describe the call itself, which a retry does not change.
+func isTransientError(err error) bool {
+	var toolErr *ToolError
+	if err == nil || errors.As(err, &toolErr) {
+		return false
+	}
+	return containsAny(strings.ToLower(err.Error()), transientErrorMessages)
+}
+
+// shouldRetry reports whether a failed call of the tool should be attempted again
+func shouldRetry(name string, err error, attempt int, opts toolOptions) bool {
+	if attempt > maxTransientRetries || !isTransientError(err) {
+		return false
+	}
+	return idempotentTools[name] || opts.retryNonIdempotent
+}
+
+// runWithRetry runs the handler, retrying transient failures per shouldRetry.
+// It returns the number of attempts made.
+func runWithRetry(ctx context.Context, session BrowserSession, name string, handler builtInHandler, args map[string]any, opts toolOptions) (map[string]any, int, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := handler(ctx, session, args, opts)
+		if err == nil || !shouldRetry(name, err, attempt, opts) {
+			return result, attempt, err
+		}
+		if err := opts.sleep(ctx, transientRetryDelay); err != nil {
+			return nil, attempt, err
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// failFirst makes the first n calls of a session method fail with err
+func failFirst(browser *fakeBrowser, method string, n int, err error) {
+	failed := 0
+	browser.failCalls(func(call fakeCall) error {
+		if call.Method != method || failed >= n {
+			return nil
+		}
+		failed++
+		return err
+	})
+}
+
+func TestBuiltInToolRetries(t *testing.T) {
+	transient := errors.New("Execution context was destroyed")
+	tests := []struct {
+		name               string
+		tool               string
+		args               map[string]any
+		method             string
+		failures           int
+		retryNonIdempotent bool
+		wantAttempts       int
+		wantErr            bool
+	}{
+		{name: "idempotent tool retried", tool: "navigate", args: map[string]any{"url": "https://example.com"},
+			method: "Navigate", failures: 1, wantAttempts: 2},
+		{name: "retries exhausted", tool: "navigate", args: map[string]any{"url": "https://example.com"},
+			method: "Navigate", failures: 5, wantAttempts: 1 + maxTransientRetries, wantErr: true},
+		{name: "click not retried", tool: "click_at", args: map[string]any{"x": 10.0, "y": 10.0},
+			method: "ClickAt", failures: 1, wantAttempts: 1, wantErr: true},
+		{name: "click retried when allowed", tool: "click_at", args: map[string]any{"x": 10.0, "y": 10.0},
+			method: "ClickAt", failures: 1, retryNonIdempotent: true, wantAttempts: 2},
+		{name: "tool error not retried", tool: "navigate", args: map[string]any{"url": 42}, wantAttempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, browser := newFakeSession(t)
+			if tt.method != "" {
+				failFirst(browser, tt.method, tt.failures, transient)
+			}
+			clock := newFakeClock()
+			opts := toolOptions{clock: clock, retryNonIdempotent: tt.retryNonIdempotent}
+
+			part, attempts, err := executeBuiltInTool(context.Background(), session, tt.tool, tt.args, nil, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if retries := slices.Index(clock.sleeps(), transientRetryDelay) >= 0; retries != (tt.wantAttempts > 1) {
+				t.Errorf("slept %v, want a retry delay only when retrying", clock.sleeps())
+			}
+			if err != nil {
+				return
+			}
+			got, _ := part.FunctionResponse.Response["attempts"].(int)
+			if tt.wantAttempts == 1 {
+				got = 1
+			}
+			if got != tt.wantAttempts {
+				t.Errorf("response attempts = %v, want %d", part.FunctionResponse.Response["attempts"], tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestToolResultEventAttempts(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("navigate", map[string]any{"url": "https://example.com/"}))},
+	)
+	config, browser := testConfig(t, backend)
+	failFirst(browser, "Navigate", 1, errors.New("Node not found"))
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	results := eventsOf[ToolResultEvent](events)
+	if len(results) != 1 || results[0].Attempts != 2 {
+		t.Fatalf("ToolResultEvents = %+v, want one with 2 attempts", results)
+	}
+	if results[0].Response["attempts"] != 2 {
+		t.Errorf("response = %v, want the attempts reported to the model", results[0].Response)
+	}
+}
@@ -0,0 +1,91 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+// ErrPromptBlocked is returned by Run when the API blocked the prompt, see BlockedEvent
+var ErrPromptBlocked = errors.New("prompt blocked")
+
+// RunResult is the outcome of a run executed with Run
+type RunResult struct {
+	FinalText string           // Text of the last model turn
+	History   []*genai.Content // Full conversation, partial if the run failed
+	TurnCount int              // Number of completed model turns
+	ToolCalls map[string]int   // Number of calls made by the model, per function name
+	Usage     Usage            // Tokens of all model calls
+	Final     Event            // Event that ended the run early, such as an ErrorEvent or MaxTurnsExceededEvent. Nil if the model finished
+
+	TypedCharacters int // Characters typed by type_text_at over the run
+	Clicks          int // Clicks made by click_at over the run
+}
+
+// RunOptions controls how Run answers events that need a decision
+type RunOptions struct {
+	DenySafetyConfirmations bool // Deny actions requiring a safety confirmation instead of approving them
+}
+
+// Run executes a loop to completion and returns its result, approving safety confirmations.
+// See RunWithOptions.
+func Run(ctx context.Context, config StartLoopConfig) (RunResult, error) {
+	return RunWithOptions(ctx, config, RunOptions{})
+}
+
+// RunWithOptions executes a loop to completion and returns its result, for scripts and tests
+// that do not need the event stream. Calls to custom tools are rejected, ending the run:
+// use StartLoop to handle them.
+// An ErrorEvent, including context cancellation, is returned as the error, as are a blocked prompt
+// and an exceeded action limit; the result then holds the partial run.
+func RunWithOptions(ctx context.Context, config StartLoopConfig, options RunOptions) (RunResult, error) {
+	result := RunResult{
+		ToolCalls: make(map[string]int),
+	}
+	var runErr error
+
+	for event := range StartLoop(ctx, config) {
+		switch e := event.(type) {
+		case ProgressEvent:
+			for _, fc := range e.FunctionCalls {
+				result.ToolCalls[fc.FunctionName]++
+				rejectUnhandledCall(fc)
+			}
+		case SafetyConfirmationEvent:
+			if options.DenySafetyConfirmations {
+				e.Deny()
+			} else {
+				e.Approve()
+			}
+		case ErrorEvent:
+			runErr = e.Err
+			result.Final = e
+		case BlockedEvent:
+			runErr = fmt.Errorf("%w: %s", ErrPromptBlocked, e.Reason)
+			result.Final = e
+		case ActionLimitExceededEvent:
+			runErr = fmt.Errorf("%w after %d typed characters and %d clicks", ErrActionLimitExceeded, e.TypedCharacters, e.Clicks)
+			result.Final = e
+		case MaxTurnsExceededEvent, DeadlineEvent, BudgetExceededEvent:
+			result.Final = e
+		case CompletionEvent:
+			result.FinalText = e.Text
+			result.History = e.History
+			result.TurnCount = e.Turns
+			result.Usage = e.Usage
+			result.TypedCharacters = e.TypedCharacters
+			result.Clicks = e.Clicks
+		}
+	}
+
+	return result, runErr
+}
+
+// rejectUnhandledCall rejects a custom tool call no one is there to handle, ending the run
+func rejectUnhandledCall(fc *FunctionCall) {
+	if fc.NeedsAction() {
+		fc.Reject(fmt.Errorf("custom tool %q has no handler, use StartLoop to handle it", fc.FunctionName))
+	}
+}
@@ -0,0 +1,151 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withinTimeout runs f, failing the test if it does not return in time, e.g. because of a deadlock
+func withinTimeout(t *testing.T, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out, the run is stuck")
+	}
+}
+
+func TestRunReportsActionCounts(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(
+			call("click_at", map[string]any{"x": 10.0, "y": 10.0}),
+			call("type_text_at", map[string]any{"x": 10.0, "y": 10.0, "text": "hello", "press_enter": false}),
+		)},
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+	)
+	config, _ := testConfig(t, backend)
+
+	result, err := Run(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.TypedCharacters != 5 || result.Clicks != 2 {
+		t.Errorf("TypedCharacters = %d, Clicks = %d, want 5 and 2", result.TypedCharacters, result.Clicks)
+	}
+	if result.TurnCount != 3 || result.FinalText != "done" {
+		t.Errorf("TurnCount = %d, FinalText = %q, want 3 turns ending with done", result.TurnCount, result.FinalText)
+	}
+}
+
+func TestCompletionEventActionCountsAfterLimit(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(
+			call("click_at", map[string]any{"x": 10.0, "y": 10.0}),
+			call("click_at", map[string]any{"x": 20.0, "y": 20.0}),
+		)},
+	)
+	config, _ := testConfig(t, backend)
+	config.MaxClicks = 1
+
+	result, err := Run(context.Background(), config)
+	if !errors.Is(err, ErrActionLimitExceeded) {
+		t.Fatalf("error = %v, want ErrActionLimitExceeded", err)
+	}
+	if result.Clicks != 1 {
+		t.Errorf("Clicks = %d, want the one click made before the limit", result.Clicks)
+	}
+	if _, ok := result.Final.(ActionLimitExceededEvent); !ok {
+		t.Errorf("Final = %T, want ActionLimitExceededEvent", result.Final)
+	}
+}
+
+func TestActionCountsSkipShortCircuitedCalls(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(
+			call("click_at", map[string]any{"x": 10.0, "y": 10.0}),
+			call("click_at", map[string]any{"x": 20.0, "y": 20.0}),
+			call("type_text_at", map[string]any{"x": 10.0, "y": 10.0, "text": "hello", "press_enter": false}),
+		)},
+	)
+	config, browser := testConfig(t, backend)
+	config.MaxClicks = 1
+	// Clicks are answered by the middleware and never reach the browser
+	config.ToolMiddleware = []ToolMiddleware{func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			if name, _ := ToolNameFromContext(ctx); name == "click_at" {
+				return map[string]any{"skipped": true}, nil
+			}
+			return next(ctx, session, args)
+		}
+	}}
+
+	result, err := Run(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(browser.recorded("ClickAt")) != 0 {
+		t.Fatal("short-circuited clicks reached the browser")
+	}
+	if result.Clicks != 0 || result.TypedCharacters != 5 {
+		t.Errorf("Clicks = %d, TypedCharacters = %d, want only the typing counted", result.Clicks, result.TypedCharacters)
+	}
+}
+
+func TestRunRejectsUnhandledCalls(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(
+			call("click_at", map[string]any{"x": 10.0, "y": 10.0}),
+			call("query_table", nil),
+			call("query_users", nil),
+		)},
+	)
+	config, _ := testConfig(t, backend)
+
+	var result RunResult
+	var err error
+	withinTimeout(t, func() { result, err = Run(context.Background(), config) })
+	if err == nil || !strings.Contains(err.Error(), `"query_table" has no handler`) {
+		t.Fatalf("error = %v, want the first custom call rejected", err)
+	}
+	if result.ToolCalls["query_table"] != 1 || result.ToolCalls["query_users"] != 1 {
+		t.Errorf("ToolCalls = %v, want both custom calls counted", result.ToolCalls)
+	}
+}
+
+func TestCustomCallsAnsweredOutOfOrder(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("query_table", nil), call("query_users", nil))},
+	)
+	config, _ := testConfig(t, backend)
+
+	var events []Event
+	withinTimeout(t, func() {
+		for event := range StartLoop(context.Background(), config) {
+			events = append(events, event)
+			if progress, ok := event.(ProgressEvent); ok && len(progress.FunctionCalls) == 2 {
+				// The loop waits for the first call, the answer to the second must not block meanwhile
+				progress.FunctionCalls[1].Respond(map[string]any{"users": 2.0})
+				progress.FunctionCalls[0].Respond(map[string]any{"rows": 3.0})
+				// Only the first answer counts
+				progress.FunctionCalls[0].Reject(errors.New("too late"))
+			}
+		}
+	})
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	calls := backend.calls()
+	responses := functionResponses(calls[1].Contents[len(calls[1].Contents)-1])
+	if responses["query_table"].Response["rows"] != 3.0 || responses["query_users"].Response["users"] != 2.0 {
+		t.Errorf("responses = %v, want each call's own answer", responses)
+	}
+}
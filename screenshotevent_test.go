@@ -0,0 +1,67 @@
+package geminirod
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestScreenshotEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		emit   bool
+		policy map[string]ScreenshotRule
+		want   []string // Tools of the ScreenshotEvents, in order
+	}{
+		{name: "off by default"},
+		{name: "after each action", emit: true, want: []string{"click_at", "navigate"}},
+		{name: "not for skipped screenshots", emit: true, policy: map[string]ScreenshotRule{"click_at": ScreenshotNever}, want: []string{"navigate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(
+				call("click_at", map[string]any{"x": 10.0, "y": 10.0}),
+				call("navigate", map[string]any{"url": "https://example.com/next"}),
+			)})
+			config, browser := testConfig(t, backend)
+			config.EmitScreenshots = tt.emit
+			config.ScreenshotPolicy = tt.policy
+			png := []byte("\x89PNG fake")
+			browser.setScreenshot(png)
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			screenshots := eventsOf[ScreenshotEvent](events)
+			if len(screenshots) != len(tt.want) {
+				t.Fatalf("got %d ScreenshotEvents, want %d", len(screenshots), len(tt.want))
+			}
+			for i, screenshot := range screenshots {
+				if screenshot.ToolName != tt.want[i] {
+					t.Errorf("ScreenshotEvent %d is for %s, want %s", i, screenshot.ToolName, tt.want[i])
+				}
+				if !bytes.Equal(screenshot.Screenshot, png) || screenshot.URL == "" || screenshot.Time.IsZero() {
+					t.Errorf("ScreenshotEvent %d = %+v, want the PNG, the URL and the time", i, screenshot)
+				}
+			}
+			if len(screenshots) > 0 && screenshots[len(screenshots)-1].URL != "https://example.com/next" {
+				t.Errorf("URL = %s, want the page after navigating", screenshots[len(screenshots)-1].URL)
+			}
+
+			// Screenshots follow the ProgressEvent announcing the calls and come before the next one
+			progress := 0
+			for _, event := range events {
+				switch event.(type) {
+				case ProgressEvent:
+					progress++
+				case ScreenshotEvent:
+					if progress != 1 {
+						t.Errorf("ScreenshotEvent after %d ProgressEvents, want after the first only", progress)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package geminirod
+
+import (
+	"crypto/sha256"
+
+	"google.golang.org/genai"
+)
+
+// ScreenshotRule decides whether a built-in tool's response includes a screenshot
+type ScreenshotRule int
+
+const (
+	ScreenshotAlways         ScreenshotRule = iota // Always include the screenshot
+	ScreenshotNever                                // Never include the screenshot
+	ScreenshotOnURLChange                          // Include it when the URL differs from the last screenshot sent
+	ScreenshotOnVisualChange                       // Include it when the page looks different from the last screenshot sent
+)
+
+// screenshotSkipped is the value of the screenshot response field when the policy left it out
+const screenshotSkipped = "skipped (policy)"
+
+// screenshotToolName is the name of the tool the model calls to get a screenshot the policy skipped
+const screenshotToolName = "take_screenshot"
+
+// screenshotDeclaration declares the screenshot tool to the model
+var screenshotDeclaration = &genai.FunctionDeclaration{
+	Name: screenshotToolName,
+	Description: "Returns the current URL and a screenshot of the page without doing anything else. " +
+		"Use it when a previous response says screenshot: \"" + screenshotSkipped + "\" and you need to see the page.",
+}
+
+// screenshotPolicy applies per-tool screenshot rules, remembering the last screenshot sent to the model
+type screenshotPolicy struct {
+	rules       map[string]ScreenshotRule
+	defaultRule ScreenshotRule
+
+	lastURL  string
+	lastHash [sha256.Size]byte
+}
+
+// include reports whether the screenshot taken after the tool ran goes to the model, and records it if so
+func (p *screenshotPolicy) include(name string, result map[string]any, screenshot []byte) bool {
+	rule, ok := p.rules[name]
+	if !ok {
+		rule = p.defaultRule
+	}
+
+	url, _ := result["url"].(string)
+	hash := sha256.Sum256(screenshot)
+	var include bool
+	switch rule {
+	case ScreenshotNever:
+		include = false
+	case ScreenshotOnURLChange:
+		include = url != p.lastURL
+	case ScreenshotOnVisualChange:
+		include = hash != p.lastHash
+	default:
+		include = true
+	}
+
+	if include {
+		p.lastURL = url
+		p.lastHash = hash
+	}
+	return include
+}
+
+// handleTakeScreenshot returns the current URL and screenshot, regardless of the policy
+func handleTakeScreenshot(session BrowserSession, opts toolOptions) (*genai.Part, error) {
+	result, err := getURLResponse(session)
+	if err != nil {
+		return nil, err
+	}
+	screenshot, err := session.Screenshot()
+	if err != nil {
+		return nil, wrapToolError(ToolErrorSessionDead, "failed to take screenshot", err)
+	}
+
+	if policy := opts.screenshots; policy != nil {
+		policy.lastURL, _ = result["url"].(string)
+		policy.lastHash = sha256.Sum256(screenshot)
+	}
+	return genai.NewPartFromFunctionResponseWithParts(screenshotToolName, result, []*genai.FunctionResponsePart{
+		genai.NewFunctionResponsePartFromBytes(opts.gridOverlay.apply(screenshot), "image/png"),
+	}), nil
+}
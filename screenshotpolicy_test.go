@@ -0,0 +1,127 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestScreenshotPolicyInclude(t *testing.T) {
+	first, second := []byte("first"), []byte("second")
+	policy := &screenshotPolicy{
+		rules: map[string]ScreenshotRule{
+			"wait_5_seconds": ScreenshotNever,
+			"navigate":       ScreenshotOnURLChange,
+			"scroll_at":      ScreenshotOnVisualChange,
+		},
+		defaultRule: ScreenshotAlways,
+	}
+	steps := []struct {
+		tool       string
+		url        string
+		screenshot []byte
+		want       bool
+	}{
+		{"click_at", "https://a.example/", first, true}, // Default rule
+		{"wait_5_seconds", "https://b.example/", second, false},
+		{"navigate", "https://a.example/", second, false}, // Same URL as the last screenshot sent
+		{"navigate", "https://b.example/", second, true},
+		{"scroll_at", "https://b.example/", second, false}, // Looks the same as the last one sent
+		{"scroll_at", "https://b.example/", first, true},
+		{"click_at", "https://b.example/", first, true},
+	}
+	for i, step := range steps {
+		if got := policy.include(step.tool, map[string]any{"url": step.url}, step.screenshot); got != step.want {
+			t.Errorf("step %d (%s): include = %v, want %v", i, step.tool, got, step.want)
+		}
+	}
+}
+
+func TestScreenshotPolicyInLoop(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("wait_5_seconds", nil))},
+		fakeReply{Response: callResponse(call(screenshotToolName, nil))},
+	)
+	config, _ := testConfig(t, backend)
+	config.ScreenshotPolicy = map[string]ScreenshotRule{"wait_5_seconds": ScreenshotNever}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	calls := backend.calls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d model calls, want 3", len(calls))
+	}
+	if !slices.Contains(calls[0].declaredFunctions(), screenshotToolName) {
+		t.Errorf("declared %v, want take_screenshot offered with a policy", calls[0].declaredFunctions())
+	}
+
+	wait := functionResponses(calls[1].Contents[len(calls[1].Contents)-1])["wait_5_seconds"]
+	if wait == nil || wait.Response["screenshot"] != screenshotSkipped || len(wait.Parts) != 0 {
+		t.Errorf("wait response = %+v, want the screenshot skipped", wait)
+	}
+	taken := functionResponses(calls[2].Contents[len(calls[2].Contents)-1])[screenshotToolName]
+	if taken == nil || len(taken.Parts) != 1 || taken.Response["url"] == nil {
+		t.Errorf("take_screenshot response = %+v, want the URL and a screenshot", taken)
+	}
+}
+
+func TestNoScreenshotToolWithoutPolicy(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if slices.Contains(backend.calls()[0].declaredFunctions(), screenshotToolName) {
+		t.Error("take_screenshot declared without a policy")
+	}
+}
+
+func TestTakeScreenshotFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       ToolErrorMode
+		wantReport bool
+	}{
+		{name: "reported to the model", mode: ToolErrorModeReport, wantReport: true},
+		{name: "ending the run", mode: ToolErrorModeAbort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(call(screenshotToolName, nil))})
+			config, browser := testConfig(t, backend)
+			config.ScreenshotPolicy = map[string]ScreenshotRule{}
+			config.ToolErrorMode = tt.mode
+			config.MaxConsecutiveToolErrors = 3
+			failFirst(browser, "Screenshot", 1, errors.New("Target closed"))
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if !tt.wantReport {
+				if err := finalError(events); err == nil {
+					t.Fatal("run succeeded, want the failed screenshot to end it")
+				}
+				return
+			}
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			calls := backend.calls()
+			if len(calls) != 2 {
+				t.Fatalf("got %d model calls, want 2", len(calls))
+			}
+			response := functionResponses(calls[1].Contents[len(calls[1].Contents)-1])[screenshotToolName]
+			if response == nil || response.Response["error"] == nil || response.Response["code"] == nil {
+				t.Fatalf("take_screenshot response = %+v, want the error reported", response)
+			}
+			if len(response.Parts) != 1 {
+				t.Errorf("got %d screenshots, want the fresh one of the error response", len(response.Parts))
+			}
+		})
+	}
+}
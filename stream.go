@@ -0,0 +1,95 @@
+package geminirod
+
+import (
+	"context"
+
+	"google.golang.org/genai"
+)
+
+// generateStream sends the request with GenerateContentStream, calling onDelta with each text chunk as it arrives,
+// and returns the chunks merged into one response, as GenerateContent would have returned it.
+// Function calls are only returned once the stream has completed.
+func generateStream(
+	ctx context.Context,
+	client *genai.Client,
+	model string,
+	history []*genai.Content,
+	config *genai.GenerateContentConfig,
+	onDelta func(text string, thought bool),
+) (*genai.GenerateContentResponse, error) {
+	merged := &genai.GenerateContentResponse{}
+	var candidate *genai.Candidate
+
+	for chunk, err := range client.Models.GenerateContentStream(ctx, model, history, config) {
+		if err != nil {
+			return nil, err
+		}
+		if chunk.PromptFeedback != nil {
+			merged.PromptFeedback = chunk.PromptFeedback
+		}
+		if chunk.UsageMetadata != nil {
+			// Counts are cumulative, the last chunk carries the totals
+			merged.UsageMetadata = chunk.UsageMetadata
+		}
+		if chunk.ModelVersion != "" {
+			merged.ModelVersion = chunk.ModelVersion
+		}
+		if chunk.ResponseID != "" {
+			merged.ResponseID = chunk.ResponseID
+		}
+		if len(chunk.Candidates) == 0 || chunk.Candidates[0] == nil {
+			continue
+		}
+
+		c := chunk.Candidates[0]
+		if candidate == nil {
+			candidate = &genai.Candidate{
+				Content: &genai.Content{Role: genai.RoleModel},
+			}
+		}
+		if c.FinishReason != "" {
+			candidate.FinishReason = c.FinishReason
+			candidate.FinishMessage = c.FinishMessage
+		}
+		if c.SafetyRatings != nil {
+			candidate.SafetyRatings = c.SafetyRatings
+		}
+		if c.Content == nil {
+			continue
+		}
+		for _, part := range c.Content.Parts {
+			if part.Text != "" && onDelta != nil {
+				onDelta(part.Text, part.Thought)
+			}
+			candidate.Content.Parts = appendStreamPart(candidate.Content.Parts, part)
+		}
+	}
+
+	if candidate != nil {
+		merged.Candidates = []*genai.Candidate{candidate}
+	}
+	return merged, nil
+}
+
+// appendStreamPart adds a streamed part to the parts received so far, joining consecutive text chunks
+// of the same kind into one part, as in a response that is not streamed. A thought signature ends a text part.
+func appendStreamPart(parts []*genai.Part, part *genai.Part) []*genai.Part {
+	if len(parts) > 0 && isTextOnlyPart(part) {
+		last := parts[len(parts)-1]
+		if isTextOnlyPart(last) && last.Thought == part.Thought && last.ThoughtSignature == nil {
+			joined := *last
+			joined.Text += part.Text
+			joined.ThoughtSignature = part.ThoughtSignature
+			parts[len(parts)-1] = &joined
+			return parts
+		}
+	}
+	p := *part
+	return append(parts, &p)
+}
+
+// isTextOnlyPart reports whether part carries text and nothing else but thought metadata
+func isTextOnlyPart(part *genai.Part) bool {
+	return part.Text != "" && part.FunctionCall == nil && part.FunctionResponse == nil &&
+		part.InlineData == nil && part.FileData == nil && part.ExecutableCode == nil && part.CodeExecutionResult == nil
+}
@@ -0,0 +1,108 @@
+package geminirod
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestAppendStreamPart(t *testing.T) {
+	signature := []byte("sig")
+	tests := []struct {
+		name      string
+		parts     []*genai.Part
+		wantTexts []string
+	}{
+		{name: "text chunks joined", parts: []*genai.Part{{Text: "Hel"}, {Text: "lo"}}, wantTexts: []string{"Hello"}},
+		{name: "thought kept apart from the answer", parts: []*genai.Part{{Text: "hmm", Thought: true}, {Text: "Hi"}}, wantTexts: []string{"hmm", "Hi"}},
+		{name: "signature ends a text part", parts: []*genai.Part{{Text: "a", ThoughtSignature: signature}, {Text: "b"}}, wantTexts: []string{"a", "b"}},
+		{name: "signature of the last chunk kept", parts: []*genai.Part{{Text: "a"}, {Text: "b", ThoughtSignature: signature}}, wantTexts: []string{"ab"}},
+		{
+			name:      "function call not joined",
+			parts:     []*genai.Part{{Text: "Clicking"}, {FunctionCall: call("click_at", nil)}, {Text: "done"}},
+			wantTexts: []string{"Clicking", "", "done"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parts []*genai.Part
+			var chunks []string
+			for _, part := range tt.parts {
+				chunks = append(chunks, part.Text)
+				parts = appendStreamPart(parts, part)
+			}
+			for i, part := range tt.parts {
+				if part.Text != chunks[i] {
+					t.Errorf("chunk %d changed to %q, want the streamed parts left as they are", i, part.Text)
+				}
+			}
+			if len(parts) != len(tt.wantTexts) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.wantTexts))
+			}
+			for i, part := range parts {
+				if part.Text != tt.wantTexts[i] {
+					t.Errorf("part %d = %q, want %q", i, part.Text, tt.wantTexts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamInLoop(t *testing.T) {
+	chunk := func(parts ...*genai.Part) *genai.GenerateContentResponse {
+		return modelResponse(parts...)
+	}
+	last := chunk(&genai.Part{FunctionCall: call("click_at", map[string]any{"x": 10.0, "y": 10.0})})
+	last.UsageMetadata = &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 100, CandidatesTokenCount: 20, TotalTokenCount: 120}
+	backend := newFakeBackend(t, fakeReply{Chunks: []*genai.GenerateContentResponse{
+		chunk(&genai.Part{Text: "The button ", Thought: true}),
+		chunk(&genai.Part{Text: "is blue", Thought: true}),
+		chunk(&genai.Part{Text: "Clicking "}),
+		chunk(&genai.Part{Text: "it"}),
+		last,
+	}})
+	config, browser := testConfig(t, backend)
+	config.Stream = true
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	calls := backend.calls()
+	if !calls[0].Stream {
+		t.Fatal("model called without streaming")
+	}
+
+	// Text is sent as it arrives, then the turn is reported and executed as a whole
+	want := []TextDeltaEvent{
+		{Turn: 1, Text: "The button ", Thought: true},
+		{Turn: 1, Text: "is blue", Thought: true},
+		{Turn: 1, Text: "Clicking "},
+		{Turn: 1, Text: "it"},
+		{Turn: 2, Text: "done"},
+	}
+	deltas := eventsOf[TextDeltaEvent](events)
+	if len(deltas) != len(want) {
+		t.Fatalf("got %d TextDeltaEvents, want %d", len(deltas), len(want))
+	}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Errorf("TextDeltaEvent %d = %+v, want %+v", i, deltas[i], want[i])
+		}
+	}
+	progress := eventsOf[ProgressEvent](events)[0]
+	if progress.Text != "The button is blueClicking it" || len(progress.FunctionCalls) != 1 || progress.Usage.TotalTokens != 120 {
+		t.Errorf("ProgressEvent = %+v, want the merged text with thoughts, the call and the usage of the last chunk", progress)
+	}
+	if len(browser.recorded("ClickAt")) != 1 {
+		t.Error("streamed function call not executed")
+	}
+
+	// The history holds the turn as a response that is not streamed would have
+	history := calls[1].Contents
+	parts := history[len(history)-2].Parts
+	if len(parts) != 3 || parts[0].Text != "The button is blue" || !parts[0].Thought || parts[1].Text != "Clicking it" || parts[2].FunctionCall == nil {
+		t.Errorf("model turn in history = %+v, want the thought, the text and the call merged", parts)
+	}
+}
@@ -0,0 +1,52 @@
+package geminirod
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/PeronGH/gemini-rod/internal/respparse"
+	"google.golang.org/genai"
+)
+
+// StrictArgsMode selects what StrictArgs does with unknown arguments
+type StrictArgsMode int
+
+const (
+	StrictArgsWarn   StrictArgsMode = iota // Emit a WarningEvent listing them and execute the call anyway
+	StrictArgsReject                       // Do not execute the call, answer the model with an INVALID_ARGUMENT error
+)
+
+// unknownArgs returns the sorted argument names of a built-in call that its declaration does not list.
+// The model's safety_decision is not an argument but is always accepted.
+func unknownArgs(name string, args map[string]any) []string {
+	var unknown []string
+	for key := range args {
+		if key != respparse.SafetyDecisionField && !isDeclaredArg(name, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// checkUnknownArgs returns the unknown arguments of a built-in call, unless the tool accepts unknown arguments
+func checkUnknownArgs(name string, args map[string]any, allowUnknown []string) []string {
+	if !IsBuiltInTool(name) || slices.Contains(allowUnknown, name) {
+		return nil
+	}
+	return unknownArgs(name, args)
+}
+
+// unknownArgsMessage describes the unknown arguments of a call
+func unknownArgsMessage(name string, unknown []string) string {
+	return fmt.Sprintf("%s does not accept the argument(s) %s", name, strings.Join(unknown, ", "))
+}
+
+// unknownArgsResponse answers a call rejected for its unknown arguments
+func unknownArgsResponse(name string, unknown []string) *genai.Part {
+	return genai.NewPartFromFunctionResponse(name, map[string]any{
+		"error": unknownArgsMessage(name, unknown) + "; the call was not executed, retry without them",
+		"code":  ToolErrorInvalidArgument,
+	})
+}
@@ -0,0 +1,89 @@
+package geminirod
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheckUnknownArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		tool         string
+		args         map[string]any
+		allowUnknown []string
+		want         []string
+	}{
+		{name: "known args", tool: "click_at", args: map[string]any{"x": 1.0, "y": 2.0}},
+		{name: "extra field", tool: "click_at", args: map[string]any{"x": 1.0, "y": 2.0, "duration": 3.0}, want: []string{"duration"}},
+		{
+			name: "misspelled field", tool: "type_text_at",
+			args: map[string]any{"x": 1.0, "y": 2.0, "text": "hi", "press_entr": true, "clear_before_typing": false},
+			want: []string{"press_entr"},
+		},
+		{name: "several sorted", tool: "navigate", args: map[string]any{"url": "https://example.com", "wait": true, "tab": 1.0}, want: []string{"tab", "wait"}},
+		{name: "safety decision accepted", tool: "click_at", args: map[string]any{"x": 1.0, "y": 2.0, "safety_decision": map[string]any{}}},
+		{name: "tool exempt", tool: "click_at", args: map[string]any{"x": 1.0, "y": 2.0, "duration": 3.0}, allowUnknown: []string{"click_at"}},
+		{name: "custom tool ignored", tool: "query_table", args: map[string]any{"anything": 1.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkUnknownArgs(tt.tool, tt.args, tt.allowUnknown); !slices.Equal(got, tt.want) {
+				t.Errorf("checkUnknownArgs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictArgsInLoop(t *testing.T) {
+	args := map[string]any{"x": 10.0, "y": 10.0, "duration": 3.0}
+	tests := []struct {
+		name         string
+		strict       bool
+		mode         StrictArgsMode
+		allowUnknown []string
+		wantWarning  bool
+		wantExecuted bool
+	}{
+		{name: "lenient by default", wantExecuted: true},
+		{name: "warn", strict: true, mode: StrictArgsWarn, wantWarning: true, wantExecuted: true},
+		{name: "reject", strict: true, mode: StrictArgsReject},
+		{name: "reject with the tool exempt", strict: true, mode: StrictArgsReject, allowUnknown: []string{"click_at"}, wantExecuted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, fakeReply{Response: callResponse(call("click_at", args))})
+			config, browser := testConfig(t, backend)
+			config.StrictArgs = tt.strict
+			config.StrictArgsMode = tt.mode
+			config.AllowUnknownArgs = tt.allowUnknown
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			warned := slices.ContainsFunc(eventsOf[WarningEvent](events), func(w WarningEvent) bool {
+				return strings.Contains(w.Message, "duration")
+			})
+			if warned != tt.wantWarning {
+				t.Errorf("warning about duration = %v, want %v", warned, tt.wantWarning)
+			}
+			if executed := len(browser.recorded("ClickAt")) > 0; executed != tt.wantExecuted {
+				t.Errorf("click executed = %v, want %v", executed, tt.wantExecuted)
+			}
+
+			calls := backend.calls()
+			history := calls[len(calls)-1].Contents
+			response := functionResponses(history[len(history)-1])["click_at"]
+			if response == nil {
+				t.Fatal("click_at response missing from the next request")
+			}
+			rejected := response.Response["code"] == ToolErrorInvalidArgument
+			if rejected == tt.wantExecuted {
+				t.Errorf("response = %v, want rejected = %v", response.Response, !tt.wantExecuted)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package geminirod
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSystemInstructionEveryTurn(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		want     []string // Substrings of the system instruction parts, in order
+	}{
+		{name: "instruction only", want: []string{"never log into accounts"}},
+		{name: "composed with the response language", language: "German", want: []string{"never log into accounts", "final answer in German"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t,
+				fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+				fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+			)
+			config, _ := testConfig(t, backend)
+			config.SystemInstruction = "never log into accounts"
+			config.ResponseLanguage = tt.language
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			calls := backend.calls()
+			if len(calls) != 3 {
+				t.Fatalf("got %d model calls, want 3", len(calls))
+			}
+			for i, request := range calls {
+				instruction := request.SystemInstruction
+				if instruction == nil || len(instruction.Parts) != len(tt.want) {
+					t.Fatalf("call %d: system instruction = %+v, want %d parts", i, instruction, len(tt.want))
+				}
+				for j, want := range tt.want {
+					if !strings.Contains(instruction.Parts[j].Text, want) {
+						t.Errorf("call %d: part %d = %q, want %q", i, j, instruction.Parts[j].Text, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNoSystemInstructionByDefault(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if instruction := backend.calls()[0].SystemInstruction; instruction != nil {
+		t.Errorf("system instruction = %+v, want none", instruction)
+	}
+}
@@ -0,0 +1,91 @@
+package geminirod
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestIncludeThoughtsDefault(t *testing.T) {
+	tests := []struct {
+		name            string
+		model           string
+		includeThoughts *bool
+		want            bool
+	}{
+		{name: "computer-use model", model: DefaultModel, want: true},
+		{name: "other model", model: "gemini-2.5-flash", want: false},
+		{name: "disabled explicitly", model: DefaultModel, includeThoughts: genai.Ptr(false), want: false},
+		{name: "enabled explicitly", model: "gemini-2.5-flash", includeThoughts: genai.Ptr(true), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t)
+			config, _ := testConfig(t, backend)
+			config.Model = tt.model
+			config.IncludeThoughts = tt.includeThoughts
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			calls := backend.calls()
+			if len(calls) != 1 {
+				t.Fatalf("got %d model calls, want 1", len(calls))
+			}
+			thinking, _ := calls[0].GenerationConfig["thinkingConfig"].(map[string]any)
+			if got := thinking["includeThoughts"] == true; got != tt.want {
+				t.Errorf("includeThoughts sent = %v, want %v (generationConfig %v)", got, tt.want, calls[0].GenerationConfig)
+			}
+		})
+	}
+}
+
+func TestThoughtsDroppedWhenUnsupported(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Status: http.StatusBadRequest, Message: "Thinking is not supported by this model."},
+		fakeReply{Response: callResponse(call("wait_5_seconds", nil))},
+		fakeReply{Response: textResponse("done")},
+	)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	warnings := eventsOf[WarningEvent](events)
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "does not support thoughts") {
+		t.Errorf("warnings = %v, want one about thoughts", warnings)
+	}
+	calls := backend.calls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d model calls, want 3", len(calls))
+	}
+	if _, ok := calls[0].GenerationConfig["thinkingConfig"]; !ok {
+		t.Error("first call sent no thinking config")
+	}
+	for i, call := range calls[1:] {
+		if _, ok := call.GenerationConfig["thinkingConfig"]; ok {
+			t.Errorf("call %d sent a thinking config after it was rejected", i+2)
+		}
+	}
+}
+
+func TestOtherBadRequestNotRetriedWithoutThoughts(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Status: http.StatusBadRequest, Message: "Request contains an invalid argument."},
+	)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err == nil {
+		t.Fatal("run succeeded, want the bad request to end it")
+	}
+	if got := len(backend.calls()); got != 1 {
+		t.Errorf("got %d model calls, want 1", got)
+	}
+}
@@ -0,0 +1,161 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Tool error codes
+const (
+	ToolErrorInvalidArgument = "INVALID_ARGUMENT" // The model supplied a missing or malformed argument
+	ToolErrorOutOfBounds     = "OUT_OF_BOUNDS"    // A coordinate or magnitude is outside the allowed range
+	ToolErrorTimeout         = "TIMEOUT"          // The action did not finish in time
+	ToolErrorPolicyDenied    = "POLICY_DENIED"    // The action was refused by configuration or policy
+	ToolErrorSessionDead     = "SESSION_DEAD"     // The browser session is no longer usable
+	ToolErrorUnsupported     = "UNSUPPORTED"      // The tool or operation is not supported
+	ToolErrorActionFailed    = "ACTION_FAILED"    // The browser could not perform the action, e.g. the element detached
+)
+
+// ToolError is a structured error returned by tool handlers.
+// Use errors.As to branch on its Code.
+type ToolError struct {
+	Code      string // One of the ToolError* codes
+	Message   string // Human readable message, suitable for the model
+	Retryable bool   // Whether retrying the same call may succeed
+	err       error
+}
+
+func (e *ToolError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the underlying error, if any
+func (e *ToolError) Unwrap() error {
+	return e.err
+}
+
+// newToolError creates a ToolError with a formatted message
+func newToolError(code string, format string, args ...any) *ToolError {
+	return &ToolError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// wrapToolError wraps err in a ToolError, unless it already is one or the run was cancelled.
+// Errors from the browser session are classified by their message, code applies to the others.
+func wrapToolError(code string, message string, err error) error {
+	var toolErr *ToolError
+	if err == nil || errors.As(err, &toolErr) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	code, retryable := classifySessionError(code, err)
+	return &ToolError{
+		Code:      code,
+		Message:   message,
+		Retryable: retryable,
+		err:       err,
+	}
+}
+
+// deadSessionMessages are fragments of errors meaning the browser or its connection is gone
+var deadSessionMessages = []string{
+	"use of closed network connection",
+	"websocket: close",
+	"connection refused",
+	"broken pipe",
+	"no target with given id",
+	"session with given id not found",
+}
+
+// classifySessionError returns the code of an error returned by the browser session, fallback if it is not recognized,
+// and whether retrying the call may succeed
+func classifySessionError(fallback string, err error) (string, bool) {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ToolErrorTimeout, true
+	case containsAny(msg, deadSessionMessages):
+		return ToolErrorSessionDead, false
+	case isTransientError(err):
+		return ToolErrorActionFailed, true
+	case strings.Contains(msg, "invalid scroll direction"):
+		return ToolErrorInvalidArgument, false
+	default:
+		return fallback, false
+	}
+}
+
+// containsAny reports whether s contains one of the fragments
+func containsAny(s string, fragments []string) bool {
+	for _, fragment := range fragments {
+		if strings.Contains(s, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolErrorMode selects what the loop does when a built-in tool fails
+type ToolErrorMode int
+
+const (
+	ToolErrorModeAbort  ToolErrorMode = iota // End the run with an ErrorEvent
+	ToolErrorModeReport                      // Send the error and a fresh screenshot to the model so it can try something else
+)
+
+// toolErrorBudget counts consecutive built-in tool failures reported to the model
+type toolErrorBudget struct {
+	max         int
+	consecutive int
+}
+
+// report records a failure and reports whether it may be sent to the model rather than ending the run.
+// A dead session always ends the run, no other action can succeed.
+func (b *toolErrorBudget) report(err error) bool {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) && toolErr.Code == ToolErrorSessionDead {
+		return false
+	}
+	b.consecutive++
+	return b.consecutive <= b.max
+}
+
+// reset records a successful call
+func (b *toolErrorBudget) reset() {
+	b.consecutive = 0
+}
+
+// toolErrorResponse answers a failed built-in call with the error, the current URL and a fresh screenshot when available
+func toolErrorResponse(session BrowserSession, name string, err error, opts toolOptions) *genai.Part {
+	result := map[string]any{
+		"error": err.Error(),
+	}
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		result["error"] = toolErr.Message
+		if toolErr.err != nil {
+			result["error"] = toolErr.Message + ": " + toolErr.err.Error()
+		}
+		result["code"] = toolErr.Code
+		result["retryable"] = toolErr.Retryable
+	}
+	if url, urlErr := session.GetURL(); urlErr == nil {
+		result["url"] = url
+	}
+
+	screenshot, screenshotErr := session.Screenshot()
+	if screenshotErr != nil {
+		return genai.NewPartFromFunctionResponse(name, result)
+	}
+	return genai.NewPartFromFunctionResponseWithParts(name, result, []*genai.FunctionResponsePart{
+		genai.NewFunctionResponsePartFromBytes(opts.gridOverlay.apply(screenshot), "image/png"),
+	})
+}
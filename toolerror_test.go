@@ -0,0 +1,154 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHandleBuiltInToolErrorCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		tool       string
+		args       map[string]any
+		failMethod string // Session method made to fail with failErr
+		failErr    error
+		wantCode   string
+		retryable  bool
+	}{
+		{name: "malformed argument", tool: "navigate", args: map[string]any{"url": 42}, wantCode: ToolErrorInvalidArgument},
+		{name: "negative wait", tool: "wait_5_seconds", args: map[string]any{"duration_seconds": -1.0}, wantCode: ToolErrorOutOfBounds},
+		{name: "drag steps out of range", tool: "drag_and_drop", args: map[string]any{
+			"x": 10.0, "y": 10.0, "destination_x": 20.0, "destination_y": 20.0, "steps": 10000.0,
+		}, wantCode: ToolErrorOutOfBounds},
+		{name: "unknown tool", tool: "fly_to_moon", wantCode: ToolErrorUnsupported},
+		{
+			name: "closed connection", tool: "click_at", args: map[string]any{"x": 10.0, "y": 10.0},
+			failMethod: "ClickAt", failErr: errors.New("websocket: close 1006 (abnormal closure)"),
+			wantCode: ToolErrorSessionDead,
+		},
+		{
+			name: "detached node", tool: "click_at", args: map[string]any{"x": 10.0, "y": 10.0},
+			failMethod: "ClickAt", failErr: errors.New("Node not found"),
+			wantCode: ToolErrorActionFailed, retryable: true,
+		},
+		{
+			name: "deadline", tool: "click_at", args: map[string]any{"x": 10.0, "y": 10.0},
+			failMethod: "ClickAt", failErr: context.DeadlineExceeded,
+			wantCode: ToolErrorTimeout, retryable: true,
+		},
+		{
+			name: "unrecognized session error", tool: "navigate", args: map[string]any{"url": "https://example.com"},
+			failMethod: "Navigate", failErr: errors.New("net::ERR_NAME_NOT_RESOLVED"),
+			wantCode: ToolErrorActionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, browser := newFakeSession(t)
+			if tt.failMethod != "" {
+				browser.fail(tt.failMethod, tt.failErr)
+			}
+
+			_, err := handleBuiltInTool(context.Background(), session, tt.tool, tt.args, nil, toolOptions{clock: newFakeClock()})
+			var toolErr *ToolError
+			if !errors.As(err, &toolErr) {
+				t.Fatalf("error = %v, want a ToolError", err)
+			}
+			if toolErr.Code != tt.wantCode {
+				t.Errorf("Code = %s, want %s (error %v)", toolErr.Code, tt.wantCode, err)
+			}
+			if toolErr.Retryable != tt.retryable {
+				t.Errorf("Retryable = %v, want %v", toolErr.Retryable, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestHandleBuiltInToolCancelledIsNotWrapped(t *testing.T) {
+	session, browser := newFakeSession(t)
+	browser.fail("ClickAt", context.Canceled)
+
+	_, err := handleBuiltInTool(context.Background(), session, "click_at", map[string]any{"x": 10.0, "y": 10.0}, nil, toolOptions{clock: newFakeClock()})
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		t.Fatalf("error = %v, want the cancellation unwrapped", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestExitCodeForRetryableToolError(t *testing.T) {
+	err := wrapToolError(ToolErrorActionFailed, "click_at failed", errors.New("execution context was destroyed"))
+	if got := exitCodeForError(err); got != ExitTransient {
+		t.Errorf("exitCodeForError = %d, want ExitTransient", got)
+	}
+	err = wrapToolError(ToolErrorActionFailed, "navigate failed", errors.New("net::ERR_NAME_NOT_RESOLVED"))
+	if got := exitCodeForError(err); got != ExitPermanent {
+		t.Errorf("exitCodeForError = %d, want ExitPermanent", got)
+	}
+}
+
+func TestToolErrorReportedToModel(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+	)
+	config, browser := testConfig(t, backend)
+	config.ToolErrorMode = ToolErrorModeReport
+	config.MaxConsecutiveToolErrors = 3
+	browser.fail("ClickAt", errors.New("Node not found"))
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	results := eventsOf[ToolResultEvent](events)
+	if len(results) != 1 {
+		t.Fatalf("got %d ToolResultEvents, want 1", len(results))
+	}
+	result := results[0]
+	if result.ToolName != "click_at" || result.Turn != 1 || result.Err == nil || result.Response != nil {
+		t.Errorf("ToolResultEvent = %+v, want a failed click_at in turn 1", result)
+	}
+	if result.Code != ToolErrorActionFailed {
+		t.Errorf("ToolResultEvent.Code = %s, want %s", result.Code, ToolErrorActionFailed)
+	}
+
+	calls := backend.calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d model calls, want 2", len(calls))
+	}
+	history := calls[1].Contents
+	response := functionResponses(history[len(history)-1])["click_at"]
+	if response == nil {
+		t.Fatal("click_at response missing from the second request")
+	}
+	if response.Response["code"] != ToolErrorActionFailed || response.Response["retryable"] != true {
+		t.Errorf("response = %v, want code %s and retryable", response.Response, ToolErrorActionFailed)
+	}
+}
+
+func TestToolResultEventMasksSensitiveArgs(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("type_text_at", map[string]any{"x": 10.0, "y": 10.0, "text": "hunter2", "press_enter": false}))},
+	)
+	config, _ := testConfig(t, backend)
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	results := eventsOf[ToolResultEvent](events)
+	if len(results) != 1 {
+		t.Fatalf("got %d ToolResultEvents, want 1", len(results))
+	}
+	if results[0].Args["text"] != "*******" {
+		t.Errorf("text = %v, want it masked", results[0].Args["text"])
+	}
+	if results[0].Err != nil || results[0].Response == nil {
+		t.Errorf("ToolResultEvent = %+v, want a successful call with its response", results[0])
+	}
+}
@@ -0,0 +1,94 @@
+package geminirod
+
+// Toolkit: the building blocks StartLoop is made of, for callers writing their own control flow
+// (tree search over actions, self-consistency across candidates, ...). StartLoop uses the same
+// functions, so behavior matches the managed loop.
+
+import (
+	"context"
+
+	"github.com/PeronGH/gemini-rod/internal/respparse"
+	"google.golang.org/genai"
+)
+
+// ToolResult is the outcome of an executed built-in tool call
+type ToolResult struct {
+	Name       string
+	Response   map[string]any // Response fields, such as the URL after the action
+	Screenshot []byte         // PNG screenshot taken after the action
+}
+
+// ExecuteBuiltIn executes a built-in tool call and returns the function response part for the model,
+// along with its contents. Safety decisions in the call are not checked: only call it when you approve the action,
+// calls requiring a safety confirmation are acknowledged in the response
+func ExecuteBuiltIn(ctx context.Context, session BrowserSession, call *genai.FunctionCall) (*genai.Part, ToolResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ToolResult{}, err
+	}
+
+	part, err := handleBuiltInTool(ctx, session, call.Name, call.Args, safetyAcknowledgement(call.Args), toolOptions{})
+	if err != nil {
+		return nil, ToolResult{}, err
+	}
+	return part, toolResultFromPart(part), nil
+}
+
+// toolResultFromPart unpacks a built-in tool's function response part
+func toolResultFromPart(part *genai.Part) ToolResult {
+	result := ToolResult{
+		Name:     part.FunctionResponse.Name,
+		Response: part.FunctionResponse.Response,
+	}
+	for _, responsePart := range part.FunctionResponse.Parts {
+		if responsePart.InlineData != nil {
+			result.Screenshot = responsePart.InlineData.Data
+		}
+	}
+	return result
+}
+
+// PrunePolicy controls which screenshots PruneHistory keeps
+type PrunePolicy struct {
+	MaxRecentScreenshots int  // Number of most recent turns whose screenshots are kept, <= 0 = unlimited
+	PinInitialScreenshot bool // Never prune the screenshot attached to the prompt at PromptIndex
+	PromptIndex          int  // Index of the run's prompt in history, 0 unless the run continues an earlier conversation
+}
+
+// PruneHistory removes screenshots from all but the most recent turns, in place.
+// The returned event describes what was removed; its TurnIndex is left for the caller to set.
+func PruneHistory(history []*genai.Content, policy PrunePolicy) PruneEvent {
+	if policy.MaxRecentScreenshots <= 0 {
+		return PruneEvent{}
+	}
+	pinIndex := -1
+	if policy.PinInitialScreenshot {
+		pinIndex = policy.PromptIndex
+	}
+	return pruneOldScreenshots(history, policy.MaxRecentScreenshots, pinIndex)
+}
+
+// ExtractTextAndThoughts splits the text of a model content into answer text and thought summaries
+func ExtractTextAndThoughts(content *genai.Content) (text, thoughts string) {
+	return respparse.TextAndThoughts(content)
+}
+
+// NewFunctionCall creates a FunctionCall for a ProgressEvent.
+// A call that needs action from the subscriber gets non-nil respond and reject functions.
+func NewFunctionCall(name string, args map[string]any, respond func(response map[string]any), reject func(err error)) *FunctionCall {
+	return &FunctionCall{
+		FunctionName: name,
+		Args:         args,
+		needsAction:  respond != nil || reject != nil,
+		respondFunc:  respond,
+		rejectFunc:   reject,
+	}
+}
+
+// NewSafetyConfirmationEvent creates a SafetyConfirmationEvent calling approve or deny on the subscriber's decision
+func NewSafetyConfirmationEvent(explanation string, approve, deny func()) SafetyConfirmationEvent {
+	return SafetyConfirmationEvent{
+		Explanation: explanation,
+		approveFunc: approve,
+		denyFunc:    deny,
+	}
+}
@@ -0,0 +1,143 @@
+package geminirod
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestExecuteBuiltIn(t *testing.T) {
+	session, browser := newFakeSession(t)
+	screenshot := testPNG(t, 10, 10)
+	browser.setScreenshot(screenshot)
+
+	part, result, err := ExecuteBuiltIn(context.Background(), session, call("navigate", map[string]any{"url": "https://example.com/"}))
+	if err != nil {
+		t.Fatalf("ExecuteBuiltIn: %v", err)
+	}
+	if result.Name != "navigate" || result.Response["url"] != "https://example.com/" {
+		t.Errorf("result = %+v, want the navigate response with the new URL", result)
+	}
+	if !bytes.Equal(result.Screenshot, screenshot) {
+		t.Error("result screenshot differs from the page's")
+	}
+	if part.FunctionResponse == nil || part.FunctionResponse.Name != "navigate" || len(part.FunctionResponse.Parts) != 1 {
+		t.Errorf("part = %+v, want a navigate response with the screenshot", part.FunctionResponse)
+	}
+}
+
+func TestExecuteBuiltInAcknowledgesSafetyDecision(t *testing.T) {
+	session, _ := newFakeSession(t)
+	args := map[string]any{"x": 10.0, "y": 10.0, "safety_decision": map[string]any{
+		"decision": "require_confirmation", "explanation": "buys something",
+	}}
+
+	_, result, err := ExecuteBuiltIn(context.Background(), session, call("click_at", args))
+	if err != nil {
+		t.Fatalf("ExecuteBuiltIn: %v", err)
+	}
+	if result.Response["safety_acknowledgement"] != "true" {
+		t.Errorf("response = %v, want the safety decision acknowledged", result.Response)
+	}
+}
+
+func TestExecuteBuiltInCancelled(t *testing.T) {
+	session, browser := newFakeSession(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := ExecuteBuiltIn(ctx, session, call("click_at", map[string]any{"x": 10.0, "y": 10.0})); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if got := len(browser.actions()); got != 0 {
+		t.Errorf("made %d actions, want none", got)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	image := func() *genai.Part { return genai.NewPartFromBytes([]byte("png"), "image/png") }
+	response := func() *genai.Content {
+		return genai.NewContentFromParts([]*genai.Part{genai.NewPartFromFunctionResponseWithParts("click_at", map[string]any{},
+			[]*genai.FunctionResponsePart{genai.NewFunctionResponsePartFromBytes([]byte("png"), "image/png")})}, genai.RoleUser)
+	}
+	newHistory := func() []*genai.Content {
+		return []*genai.Content{
+			genai.NewContentFromParts([]*genai.Part{genai.NewPartFromText("task"), image()}, genai.RoleUser),
+			response(), response(), response(),
+		}
+	}
+
+	history := newHistory()
+	event := PruneHistory(history, PrunePolicy{MaxRecentScreenshots: 1})
+	if event.ScreenshotsRemoved != 3 || !slices.Equal(event.AffectedTurns, []int{0, 1, 2}) {
+		t.Errorf("event = %+v, want the 3 oldest screenshots removed", event)
+	}
+	if got := countImages(history); got != 1 {
+		t.Errorf("%d response screenshots left, want 1", got)
+	}
+
+	history = newHistory()
+	event = PruneHistory(history, PrunePolicy{MaxRecentScreenshots: 1, PinInitialScreenshot: true})
+	if event.ScreenshotsRemoved != 2 || history[0].Parts[1].InlineData == nil {
+		t.Errorf("event = %+v, want the prompt screenshot pinned", event)
+	}
+
+	history = newHistory()
+	if event = PruneHistory(history, PrunePolicy{}); event.ScreenshotsRemoved != 0 {
+		t.Errorf("event = %+v, want nothing pruned without a limit", event)
+	}
+}
+
+func TestExtractTextAndThoughts(t *testing.T) {
+	content := &genai.Content{Parts: []*genai.Part{
+		{Text: "let me look", Thought: true},
+		{Text: "The answer"},
+		{Text: " is 42"},
+	}}
+	text, thoughts := ExtractTextAndThoughts(content)
+	if text != "The answer is 42" || thoughts != "let me look" {
+		t.Errorf("text = %q, thoughts = %q", text, thoughts)
+	}
+	if text, thoughts := ExtractTextAndThoughts(nil); text != "" || thoughts != "" {
+		t.Errorf("nil content: text = %q, thoughts = %q, want both empty", text, thoughts)
+	}
+}
+
+func TestNewFunctionCall(t *testing.T) {
+	var responded map[string]any
+	var rejected error
+	fc := NewFunctionCall("lookup", map[string]any{"q": "x"},
+		func(response map[string]any) { responded = response },
+		func(err error) { rejected = err })
+	if !fc.NeedsAction() {
+		t.Error("call with handlers does not need action")
+	}
+	fc.Respond(map[string]any{"ok": true})
+	fc.Reject(errors.New("no"))
+	if responded["ok"] != true || rejected == nil {
+		t.Errorf("responded %v, rejected %v, want both handlers called", responded, rejected)
+	}
+
+	handled := NewFunctionCall("click_at", nil, nil, nil)
+	if handled.NeedsAction() {
+		t.Error("call without handlers needs action")
+	}
+	handled.Respond(map[string]any{}) // Must not panic
+}
+
+func TestNewSafetyConfirmationEvent(t *testing.T) {
+	var approved, denied bool
+	event := NewSafetyConfirmationEvent("buys something", func() { approved = true }, func() { denied = true })
+	if event.Explanation != "buys something" {
+		t.Errorf("Explanation = %q", event.Explanation)
+	}
+	event.Approve()
+	event.Deny()
+	if !approved || !denied {
+		t.Errorf("approved %v, denied %v, want both callbacks called", approved, denied)
+	}
+}
@@ -1,16 +1,90 @@
 package geminirod
 
 import (
-	"fmt"
+	"context"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
-	computeruse "github.com/PeronGH/computer-use-lib"
 	"google.golang.org/genai"
 )
 
+// Wait tool durations, used when toolOptions leaves them unset
+const (
+	defaultWaitDuration    = 5 * time.Second
+	defaultMaxWaitDuration = 30 * time.Second
+)
+
+// toolOptions holds per-run settings that affect how built-in tools behave
+type toolOptions struct {
+	coordinateSpace CoordinateSpace   // Zero if unknown
+	scrollFraction  float64           // Default scroll_document distance as a viewport fraction, 0 = page up/down
+	scrollOverlap   float64           // Fraction of the viewport kept visible across a default scroll_document
+	actions         *actionCounter    // Nil if actions are not counted
+	screenshots     *screenshotPolicy // Nil if every response includes a screenshot
+	gridOverlay     GridOverlay       // Coordinate hints drawn on screenshots sent to the model
+	clock           Clock             // Nil = system clock
+	toolErrors      *toolErrorBudget  // Nil if built-in tool failures end the run
+
+	retryNonIdempotent bool     // Also retry clicks, typing, key presses and drags on transient errors
+	rejectUnknownArgs  bool     // Answer built-in calls with unknown arguments with an error instead of executing them
+	allowUnknownArgs   []string // Tools exempt from rejectUnknownArgs
+
+	waitDefault time.Duration // Duration of wait_5_seconds without duration_seconds, 0 = 5s
+	waitMax     time.Duration // Cap on the wait duration, 0 = 30s
+
+	elementCapture   bool // Execute capture_element_screenshot calls, see EnableElementCapture
+	emitScreenshots  bool // Report the screenshot of each executed built-in tool in a ScreenshotEvent
+	logSensitiveArgs bool // Report sensitive arguments unmasked in ToolResultEvents
+
+	excludedTools []string               // Built-in tools answered with an error instead of being executed
+	overrides     map[string]ToolHandler // Handlers replacing the default ones, by tool name
+	middleware    []ToolMiddleware       // Wrapping every built-in tool execution, first one outermost
+	registry      *ToolRegistry          // Custom tools executed without the subscriber, nil = none
+}
+
+// builtInHandler executes a built-in tool and returns the response fields
+type builtInHandler func(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error)
+
+// ToolHandler executes a built-in tool and returns the response fields, see StartLoopConfig.ToolOverrides.
+// The screenshot is added by the caller, as is the page URL if the handler leaves it out.
+type ToolHandler func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error)
+
+// BuiltInToolHandler returns the default handler of a built-in tool with default options, e.g. to wrap it in an override
+func BuiltInToolHandler(name string) (ToolHandler, bool) {
+	handler, exists := builtInTools[name]
+	if !exists {
+		return nil, false
+	}
+	return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+		return handler(ctx, session, args, toolOptions{})
+	}, true
+}
+
+// builtIn adapts an override to the built-in handler signature, adding the page URL if it is missing
+func (h ToolHandler) builtIn() builtInHandler {
+	return func(ctx context.Context, session BrowserSession, args map[string]any, _ toolOptions) (map[string]any, error) {
+		result, err := h(ctx, session, args)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = make(map[string]any)
+		}
+		if _, ok := result["url"]; !ok {
+			url, err := session.GetURL()
+			if err != nil {
+				return nil, err
+			}
+			result["url"] = url
+		}
+		return result, nil
+	}
+}
+
 // builtInTools maps tool names to their handler functions
-var builtInTools = map[string]func(*computeruse.Session, map[string]any) (map[string]any, error){
+var builtInTools = map[string]builtInHandler{
 	"open_web_browser": handleOpenWebBrowser,
 	"wait_5_seconds":   handleWait5Seconds,
 	"go_back":          handleGoBack,
@@ -32,42 +106,134 @@ func IsBuiltInTool(name string) bool {
 	return exists
 }
 
+// BuiltInToolNames returns the names of the built-in tools, sorted, e.g. for StartLoopConfig.ExcludedTools
+func BuiltInToolNames() []string {
+	return slices.Sorted(maps.Keys(builtInTools))
+}
+
+// excludedToolResponse answers a call to a built-in tool disabled by ExcludedTools, without executing it
+func excludedToolResponse(name string) *genai.Part {
+	return genai.NewPartFromFunctionResponse(name, map[string]any{
+		"error": "tool disabled by policy; the call was not executed, use another tool",
+		"code":  ToolErrorPolicyDenied,
+	})
+}
+
 // HandleBuiltInTool executes a built-in tool and returns a genai.Part with URL and screenshot.
-// Only call it when you approve the action
-func HandleBuiltInTool(session *computeruse.Session, name string, args map[string]any) (*genai.Part, error) {
+// Only call it when you approve the action: calls requiring a safety confirmation are acknowledged in the response.
+//
+// Deprecated: HandleBuiltInTool runs without a context, so a hung tool cannot be cancelled.
+// Use HandleBuiltInToolWithOptions, or ExecuteBuiltIn, with a context instead.
+func HandleBuiltInTool(session BrowserSession, name string, args map[string]any) (*genai.Part, error) {
+	return HandleBuiltInToolWithOptions(context.Background(), session, name, args, BuiltInToolOptions{})
+}
+
+// BuiltInToolOptions customizes a HandleBuiltInToolWithOptions call
+type BuiltInToolOptions struct {
+	ExtraFields map[string]any   // Merged into the function response, e.g. action ids or timing data. Overrides tool fields
+	Middleware  []ToolMiddleware // Wrapping the execution, see StartLoopConfig.ToolMiddleware
+}
+
+// HandleBuiltInToolWithOptions is HandleBuiltInTool with a context, whose cancellation interrupts the tool,
+// and extra response fields. The safety acknowledgement is added as in HandleBuiltInTool unless ExtraFields sets it.
+func HandleBuiltInToolWithOptions(ctx context.Context, session BrowserSession, name string, args map[string]any, options BuiltInToolOptions) (*genai.Part, error) {
+	extraFields := safetyAcknowledgement(args)
+	if extraFields == nil {
+		extraFields = make(map[string]any, len(options.ExtraFields))
+	}
+	for key, val := range options.ExtraFields {
+		extraFields[key] = val
+	}
+	return handleBuiltInTool(ctx, session, name, args, extraFields, toolOptions{middleware: options.Middleware})
+}
+
+// handleBuiltInTool is HandleBuiltInTool with per-run options.
+// extraFields are merged into the response, e.g. the safety acknowledgement of an approved call.
+func handleBuiltInTool(ctx context.Context, session BrowserSession, name string, args map[string]any, extraFields map[string]any, opts toolOptions) (*genai.Part, error) {
+	part, _, err := executeBuiltInTool(ctx, session, name, args, extraFields, opts)
+	return part, err
+}
+
+// executeBuiltInTool is handleBuiltInTool also returning the number of attempts made, see runWithRetry.
+// It is 0 when the tool was not run, e.g. because of an action limit or a middleware answering itself.
+func executeBuiltInTool(ctx context.Context, session BrowserSession, name string, args map[string]any, extraFields map[string]any, opts toolOptions) (*genai.Part, int, error) {
 	handler, exists := builtInTools[name]
 	if !exists {
-		return nil, fmt.Errorf("unknown built-in tool: %s", name)
+		return nil, 0, newToolError(ToolErrorUnsupported, "unknown built-in tool: %s", name)
+	}
+	if override, ok := opts.overrides[name]; ok {
+		handler = override.builtIn()
 	}
 
-	result, err := handler(session, args)
+	args, _ = normalizeArgs(name, args)
+	if opts.actions != nil {
+		if err := opts.actions.admit(name, args); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Middleware sees one invocation, retries included
+	attempts := 0
+	execute := chainMiddleware(func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+		result, made, err := runWithRetry(ctx, session, name, handler, args, opts)
+		attempts += made
+		if err != nil {
+			return nil, wrapToolError(ToolErrorActionFailed, name+" failed", err)
+		}
+		// Only actions that reached the browser count, not those a middleware answered itself
+		if opts.actions != nil {
+			opts.actions.record(name, args)
+		}
+		if made > 1 {
+			result["attempts"] = made
+		}
+		return result, nil
+	}, opts.middleware)
+	result, err := execute(middlewareContext(ctx, name, opts), session, args)
 	if err != nil {
-		return nil, err
+		return nil, attempts, err
+	}
+	if result == nil {
+		result = make(map[string]any)
 	}
 
-	// Add safety acknowledgement
-	result["safety_acknowledgement"] = "true"
+	for key, val := range extraFields {
+		result[key] = val
+	}
 
 	// Wait 1s for things to finish rendering
-	time.Sleep(1 * time.Second)
+	if err := opts.sleep(ctx, 1*time.Second); err != nil {
+		return nil, attempts, err
+	}
 
 	// Get screenshot
 	screenshot, err := session.Screenshot()
 	if err != nil {
-		return nil, fmt.Errorf("failed to take screenshot: %w", err)
+		return nil, attempts, wrapToolError(ToolErrorSessionDead, "failed to take screenshot", err)
+	}
+
+	// Leave the screenshot out if the policy says so, the model can ask for it with take_screenshot
+	if opts.screenshots != nil && !opts.screenshots.include(name, result, screenshot) {
+		result["screenshot"] = screenshotSkipped
+		return genai.NewPartFromFunctionResponse(name, result), attempts, nil
 	}
 
 	// Create function response part with screenshot
-	screenshotPart := genai.NewFunctionResponsePartFromBytes(screenshot, "image/png")
+	screenshotPart := genai.NewFunctionResponsePartFromBytes(opts.gridOverlay.apply(screenshot), "image/png")
 
 	// Create function response with URL and screenshot
-	return genai.NewPartFromFunctionResponseWithParts(name, result, []*genai.FunctionResponsePart{screenshotPart}), nil
+	return genai.NewPartFromFunctionResponseWithParts(name, result, []*genai.FunctionResponsePart{screenshotPart}), attempts, nil
+}
+
+// sleep waits for d on the run's clock
+func (opts toolOptions) sleep(ctx context.Context, d time.Duration) error {
+	return clockOrSystem(opts.clock).Sleep(ctx, d)
 }
 
 // Tool handlers
 // All handlers return only the current URL after the operation
 
-func getURLResponse(session *computeruse.Session) (map[string]any, error) {
+func getURLResponse(session BrowserSession) (map[string]any, error) {
 	url, err := session.GetURL()
 	if err != nil {
 		return nil, err
@@ -75,41 +241,75 @@ func getURLResponse(session *computeruse.Session) (map[string]any, error) {
 	return map[string]any{"url": url}, nil
 }
 
-func handleOpenWebBrowser(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleOpenWebBrowser(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	// Browser should already be open with the session, so this is a no-op
 	return getURLResponse(session)
 }
 
-func handleWait5Seconds(session *computeruse.Session, args map[string]any) (map[string]any, error) {
-	time.Sleep(5 * time.Second)
-	return getURLResponse(session)
+func handleWait5Seconds(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
+	maxDuration := opts.waitMax
+	if maxDuration <= 0 {
+		maxDuration = defaultMaxWaitDuration
+	}
+	duration := opts.waitDefault
+	if duration <= 0 {
+		duration = defaultWaitDuration
+	}
+	if val, exists := args["duration_seconds"]; exists {
+		var seconds float64
+		switch v := val.(type) {
+		case float64:
+			seconds = v
+		case int:
+			seconds = float64(v)
+		default:
+			return nil, newToolError(ToolErrorInvalidArgument, "duration_seconds argument must be a number")
+		}
+		if seconds < 0 {
+			return nil, newToolError(ToolErrorOutOfBounds, "duration_seconds argument must not be negative")
+		}
+		// Clamped before the conversion, which overflows for huge values
+		seconds = min(seconds, maxDuration.Seconds())
+		duration = time.Duration(seconds * float64(time.Second))
+	}
+	duration = min(duration, maxDuration)
+
+	if err := opts.sleep(ctx, duration); err != nil {
+		return nil, err
+	}
+	result, err := getURLResponse(session)
+	if err != nil {
+		return nil, err
+	}
+	result["waited_seconds"] = duration.Seconds()
+	return result, nil
 }
 
-func handleGoBack(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleGoBack(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	if err := session.GoBack(); err != nil {
 		return nil, err
 	}
 	return getURLResponse(session)
 }
 
-func handleGoForward(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleGoForward(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	if err := session.GoForward(); err != nil {
 		return nil, err
 	}
 	return getURLResponse(session)
 }
 
-func handleSearch(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleSearch(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	if err := session.Search(); err != nil {
 		return nil, err
 	}
 	return getURLResponse(session)
 }
 
-func handleNavigate(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleNavigate(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	url, ok := args["url"].(string)
 	if !ok {
-		return nil, fmt.Errorf("url argument must be a string")
+		return nil, newToolError(ToolErrorInvalidArgument, "url argument must be a string")
 	}
 	if err := session.Navigate(url); err != nil {
 		return nil, err
@@ -117,7 +317,7 @@ func handleNavigate(session *computeruse.Session, args map[string]any) (map[stri
 	return getURLResponse(session)
 }
 
-func handleClickAt(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleClickAt(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	x, y, err := extractCoordinates(args)
 	if err != nil {
 		return nil, err
@@ -128,7 +328,7 @@ func handleClickAt(session *computeruse.Session, args map[string]any) (map[strin
 	return getURLResponse(session)
 }
 
-func handleHoverAt(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleHoverAt(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	x, y, err := extractCoordinates(args)
 	if err != nil {
 		return nil, err
@@ -139,14 +339,14 @@ func handleHoverAt(session *computeruse.Session, args map[string]any) (map[strin
 	return getURLResponse(session)
 }
 
-func handleTypeTextAt(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleTypeTextAt(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	x, y, err := extractCoordinates(args)
 	if err != nil {
 		return nil, err
 	}
 	text, ok := args["text"].(string)
 	if !ok {
-		return nil, fmt.Errorf("text argument must be a string")
+		return nil, newToolError(ToolErrorInvalidArgument, "text argument must be a string")
 	}
 
 	// Optional arguments with defaults
@@ -170,10 +370,10 @@ func handleTypeTextAt(session *computeruse.Session, args map[string]any) (map[st
 	return getURLResponse(session)
 }
 
-func handleKeyCombination(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleKeyCombination(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	keys, ok := args["keys"].(string)
 	if !ok {
-		return nil, fmt.Errorf("keys argument must be a string")
+		return nil, newToolError(ToolErrorInvalidArgument, "keys argument must be a string")
 	}
 	// Split by "+" to convert "Control+C" to ["Control", "C"]
 	// This matches the Python reference implementation and computer-use-lib's expected format
@@ -184,26 +384,57 @@ func handleKeyCombination(session *computeruse.Session, args map[string]any) (ma
 	return getURLResponse(session)
 }
 
-func handleScrollDocument(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleScrollDocument(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	direction, ok := args["direction"].(string)
 	if !ok {
-		return nil, fmt.Errorf("direction argument must be a string")
+		return nil, newToolError(ToolErrorInvalidArgument, "direction argument must be a string")
 	}
-	// Use default scroll amount (800 based on 1000x1000 grid)
-	if err := session.Scroll(direction, 800); err != nil {
+
+	// An explicit distance, in grid units or as a viewport fraction, replaces the configured default
+	fraction, err := extractScrollFraction(args)
+	if err != nil {
 		return nil, err
 	}
-	return getURLResponse(session)
+	if fraction == 0 {
+		fraction = opts.scrollFraction * (1 - opts.scrollOverlap)
+	}
+
+	// Partial scrolls put the wheel at the center of the 0-999 grid, so they need a session with normalized
+	// coordinates, as known from a CoordinateSpace; other sessions page up and down
+	if fraction == 0 || (direction != "up" && direction != "down") || opts.coordinateSpace.GridSize == 0 {
+		// Use default scroll amount (800 based on 1000x1000 grid)
+		if err := session.Scroll(direction, 800); err != nil {
+			return nil, err
+		}
+		return getURLResponse(session)
+	}
+
+	// Scroll by the requested share of the viewport with the wheel at its center.
+	// The wheel scrolls whatever is under the center: usually the document, but a scrollable panel
+	// or map covering the center scrolls instead, the session offers no document-level scroll by distance.
+	magnitude := int(fraction * normalizedGridSize)
+	if err := session.ScrollAt(normalizedGridSize/2, normalizedGridSize/2, direction, magnitude); err != nil {
+		return nil, err
+	}
+
+	response, err := getURLResponse(session)
+	if err != nil {
+		return nil, err
+	}
+	if opts.coordinateSpace.Height > 0 {
+		response["scrolled_pixels"] = magnitude * opts.coordinateSpace.Height / normalizedGridSize
+	}
+	return response, nil
 }
 
-func handleScrollAt(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleScrollAt(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	x, y, err := extractCoordinates(args)
 	if err != nil {
 		return nil, err
 	}
 	direction, ok := args["direction"].(string)
 	if !ok {
-		return nil, fmt.Errorf("direction argument must be a string")
+		return nil, newToolError(ToolErrorInvalidArgument, "direction argument must be a string")
 	}
 
 	magnitude := 800 // default
@@ -212,6 +443,13 @@ func handleScrollAt(session *computeruse.Session, args map[string]any) (map[stri
 	} else if val, ok := args["magnitude"].(int); ok {
 		magnitude = val
 	}
+	if _, exists := args["fraction"]; exists {
+		fraction, err := extractScrollFraction(args)
+		if err != nil {
+			return nil, err
+		}
+		magnitude = int(fraction * normalizedGridSize)
+	}
 
 	if err := session.ScrollAt(x, y, direction, magnitude); err != nil {
 		return nil, err
@@ -219,7 +457,7 @@ func handleScrollAt(session *computeruse.Session, args map[string]any) (map[stri
 	return getURLResponse(session)
 }
 
-func handleDragAndDrop(session *computeruse.Session, args map[string]any) (map[string]any, error) {
+func handleDragAndDrop(ctx context.Context, session BrowserSession, args map[string]any, opts toolOptions) (map[string]any, error) {
 	x, y, err := extractCoordinates(args)
 	if err != nil {
 		return nil, err
@@ -230,7 +468,7 @@ func handleDragAndDrop(session *computeruse.Session, args map[string]any) (map[s
 		if destXInt, ok := args["destination_x"].(int); ok {
 			destX = float64(destXInt)
 		} else {
-			return nil, fmt.Errorf("destination_x argument must be a number")
+			return nil, newToolError(ToolErrorInvalidArgument, "destination_x argument must be a number")
 		}
 	}
 
@@ -239,25 +477,85 @@ func handleDragAndDrop(session *computeruse.Session, args map[string]any) (map[s
 		if destYInt, ok := args["destination_y"].(int); ok {
 			destY = float64(destYInt)
 		} else {
-			return nil, fmt.Errorf("destination_y argument must be a number")
+			return nil, newToolError(ToolErrorInvalidArgument, "destination_y argument must be a number")
 		}
 	}
 
-	if err := session.ClickDrag(x, y, int(destX), int(destY)); err != nil {
+	waypoints, err := extractWaypoints(args)
+	if err != nil {
 		return nil, err
 	}
-	return getURLResponse(session)
+	steps, err := extractDragSteps(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plain straight drag unless a path was requested
+	if len(waypoints) == 0 && steps == 1 {
+		if err := session.ClickDrag(x, y, int(destX), int(destY)); err != nil {
+			return nil, err
+		}
+		return getURLResponse(session)
+	}
+
+	path := append([]point{{x, y}}, waypoints...)
+	path = append(path, point{int(destX), int(destY)})
+	moves, err := dragAlongPath(ctx, session, path, steps, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := getURLResponse(session)
+	if err != nil {
+		return nil, err
+	}
+	response["drag_path"] = map[string]any{
+		"waypoints":         len(waypoints),
+		"steps_per_segment": steps,
+		"pointer_moves":     moves,
+	}
+	return response, nil
 }
 
 // Helper functions
 
+// extractScrollFraction parses the optional scroll distance as a share of the viewport: the fraction argument,
+// or the magnitude argument in grid units. It returns 0 if neither is given.
+func extractScrollFraction(args map[string]any) (float64, error) {
+	if val, exists := args["fraction"]; exists {
+		if _, both := args["magnitude"]; both {
+			return 0, newToolError(ToolErrorInvalidArgument, "give either magnitude or fraction, not both")
+		}
+		fraction, ok := val.(float64)
+		if !ok {
+			return 0, newToolError(ToolErrorInvalidArgument, "fraction argument must be a number")
+		}
+		if fraction <= 0 || fraction > 1 {
+			return 0, newToolError(ToolErrorOutOfBounds, "fraction argument must be greater than 0 and at most 1")
+		}
+		return fraction, nil
+	}
+
+	var magnitude float64
+	switch v := args["magnitude"].(type) {
+	case float64:
+		magnitude = v
+	case int:
+		magnitude = float64(v)
+	}
+	if magnitude <= 0 {
+		return 0, nil
+	}
+	return magnitude / normalizedGridSize, nil
+}
+
 func extractCoordinates(args map[string]any) (int, int, error) {
 	xVal, ok := args["x"].(float64)
 	if !ok {
 		if xInt, ok := args["x"].(int); ok {
 			xVal = float64(xInt)
 		} else {
-			return 0, 0, fmt.Errorf("x argument must be a number")
+			return 0, 0, newToolError(ToolErrorInvalidArgument, "x argument must be a number")
 		}
 	}
 
@@ -266,7 +564,7 @@ func extractCoordinates(args map[string]any) (int, int, error) {
 		if yInt, ok := args["y"].(int); ok {
 			yVal = float64(yInt)
 		} else {
-			return 0, 0, fmt.Errorf("y argument must be a number")
+			return 0, 0, newToolError(ToolErrorInvalidArgument, "y argument must be a number")
 		}
 	}
 
@@ -0,0 +1,269 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// scrollCall is the call a scroll tool makes on the session
+func scrollCall(method string, args ...any) *fakeCall {
+	return &fakeCall{Method: method, Args: args}
+}
+
+func TestHandleScrollDocument(t *testing.T) {
+	space := CoordinateSpace{Width: 1000, Height: 800, GridSize: normalizedGridSize}
+	tests := []struct {
+		name     string
+		args     map[string]any
+		opts     toolOptions
+		want     *fakeCall // Scroll call on the session, nil if the call fails
+		wantPx   any       // scrolled_pixels in the response, nil if absent
+		wantCode string    // ToolError code, "" if the call succeeds
+	}{
+		{
+			name: "default pages down",
+			args: map[string]any{"direction": "down"},
+			opts: toolOptions{coordinateSpace: space},
+			want: scrollCall("Scroll", "down", 800),
+		},
+		{
+			name:   "fraction scrolls with the wheel at the center",
+			args:   map[string]any{"direction": "down", "fraction": 0.5},
+			opts:   toolOptions{coordinateSpace: space},
+			want:   scrollCall("ScrollAt", 500, 500, "down", 500),
+			wantPx: 400,
+		},
+		{
+			name:   "grid units",
+			args:   map[string]any{"direction": "up", "magnitude": 250.0},
+			opts:   toolOptions{coordinateSpace: space},
+			want:   scrollCall("ScrollAt", 500, 500, "up", 250),
+			wantPx: 200,
+		},
+		{
+			name:   "a small magnitude is grid units",
+			args:   map[string]any{"direction": "down", "magnitude": 1.0},
+			opts:   toolOptions{coordinateSpace: space},
+			want:   scrollCall("ScrollAt", 500, 500, "down", 1),
+			wantPx: 0,
+		},
+		{
+			name:   "integer magnitude",
+			args:   map[string]any{"direction": "down", "magnitude": 500},
+			opts:   toolOptions{coordinateSpace: space},
+			want:   scrollCall("ScrollAt", 500, 500, "down", 500),
+			wantPx: 400,
+		},
+		{
+			name:     "fraction out of range",
+			args:     map[string]any{"direction": "down", "fraction": 1.5},
+			opts:     toolOptions{coordinateSpace: space},
+			wantCode: ToolErrorOutOfBounds,
+		},
+		{
+			name:     "magnitude and fraction",
+			args:     map[string]any{"direction": "down", "magnitude": 500.0, "fraction": 0.5},
+			opts:     toolOptions{coordinateSpace: space},
+			wantCode: ToolErrorInvalidArgument,
+		},
+		{
+			name:   "configured fraction with overlap",
+			args:   map[string]any{"direction": "down"},
+			opts:   toolOptions{coordinateSpace: space, scrollFraction: 1, scrollOverlap: 0.25},
+			want:   scrollCall("ScrollAt", 500, 500, "down", 750),
+			wantPx: 600,
+		},
+		{
+			name: "fraction without a coordinate space pages down",
+			args: map[string]any{"direction": "down", "fraction": 0.5},
+			want: scrollCall("Scroll", "down", 800),
+		},
+		{
+			name: "horizontal fraction uses the default scroll",
+			args: map[string]any{"direction": "left", "fraction": 0.5},
+			opts: toolOptions{coordinateSpace: space},
+			want: scrollCall("Scroll", "left", 800),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, browser := newFakeSession(t)
+			response, err := handleScrollDocument(context.Background(), session, tt.args, tt.opts)
+			if tt.wantCode != "" {
+				var toolErr *ToolError
+				if !errors.As(err, &toolErr) || toolErr.Code != tt.wantCode {
+					t.Fatalf("error = %v, want a ToolError with code %s", err, tt.wantCode)
+				}
+				if actions := browser.actions(); len(actions) != 0 {
+					t.Errorf("actions = %v, want none", actions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handleScrollDocument: %v", err)
+			}
+
+			scrolls := browser.recorded("Scroll", "ScrollAt")
+			if len(scrolls) != 1 || scrolls[0].Method != tt.want.Method || !slices.Equal(scrolls[0].Args, tt.want.Args) {
+				t.Errorf("scroll calls = %+v, want %+v", scrolls, *tt.want)
+			}
+			if got := response["scrolled_pixels"]; got != tt.wantPx {
+				t.Errorf("scrolled_pixels = %v, want %v", got, tt.wantPx)
+			}
+		})
+	}
+}
+
+func TestHandleScrollAt(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          map[string]any
+		wantMagnitude int
+	}{
+		{name: "default", args: map[string]any{}, wantMagnitude: 800},
+		{name: "a small magnitude is grid units", args: map[string]any{"magnitude": 1.0}, wantMagnitude: 1},
+		{name: "integer magnitude", args: map[string]any{"magnitude": 250}, wantMagnitude: 250},
+		{name: "fraction of the viewport", args: map[string]any{"fraction": 0.5}, wantMagnitude: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, browser := newFakeSession(t)
+			args := map[string]any{"x": 300.0, "y": 600.0, "direction": "down"}
+			for key, val := range tt.args {
+				args[key] = val
+			}
+			if _, err := handleScrollAt(context.Background(), session, args, toolOptions{}); err != nil {
+				t.Fatalf("handleScrollAt: %v", err)
+			}
+			want := []any{300, 600, "down", tt.wantMagnitude}
+			if scrolls := browser.recorded("ScrollAt"); len(scrolls) != 1 || !slices.Equal(scrolls[0].Args, want) {
+				t.Errorf("ScrollAt calls = %+v, want one with %v", scrolls, want)
+			}
+		})
+	}
+}
+
+func TestHandleBuiltInTool(t *testing.T) {
+	session, _ := newFakeSession(t)
+
+	part, err := HandleBuiltInTool(session, "navigate", map[string]any{"url": "https://example.com/"})
+	if err != nil {
+		t.Fatalf("HandleBuiltInTool: %v", err)
+	}
+	response := part.FunctionResponse
+	if response.Response["url"] != "https://example.com/" || len(response.Parts) != 1 {
+		t.Errorf("response = %+v, want the URL and a screenshot", response)
+	}
+	if _, ok := response.Response["safety_acknowledgement"]; ok {
+		t.Error("safety acknowledgement added to a call without a safety decision")
+	}
+
+	args := map[string]any{"x": 10.0, "y": 10.0, "safety_decision": map[string]any{"decision": "require_confirmation"}}
+	part, err = HandleBuiltInTool(session, "click_at", args)
+	if err != nil {
+		t.Fatalf("HandleBuiltInTool: %v", err)
+	}
+	if part.FunctionResponse.Response["safety_acknowledgement"] != "true" {
+		t.Errorf("response = %v, want the safety decision acknowledged", part.FunctionResponse.Response)
+	}
+}
+
+func TestHandleBuiltInToolWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		options BuiltInToolOptions
+		want    map[string]any // Fields expected in the response
+	}{
+		{
+			name: "no extra fields",
+			args: map[string]any{"url": "https://example.com/"},
+			want: map[string]any{"url": "https://example.com/"},
+		},
+		{
+			name:    "extra fields",
+			args:    map[string]any{"url": "https://example.com/"},
+			options: BuiltInToolOptions{ExtraFields: map[string]any{"action_id": "a1"}},
+			want:    map[string]any{"url": "https://example.com/", "action_id": "a1"},
+		},
+		{
+			name:    "extra fields override tool fields",
+			args:    map[string]any{"url": "https://example.com/"},
+			options: BuiltInToolOptions{ExtraFields: map[string]any{"url": "redacted"}},
+			want:    map[string]any{"url": "redacted"},
+		},
+		{
+			name:    "extra fields with a safety decision",
+			args:    map[string]any{"url": "https://example.com/", "safety_decision": map[string]any{"decision": "require_confirmation"}},
+			options: BuiltInToolOptions{ExtraFields: map[string]any{"action_id": "a2"}},
+			want:    map[string]any{"action_id": "a2", "safety_acknowledgement": "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, _ := newFakeSession(t)
+			part, err := HandleBuiltInToolWithOptions(context.Background(), session, "navigate", tt.args, tt.options)
+			if err != nil {
+				t.Fatalf("HandleBuiltInToolWithOptions: %v", err)
+			}
+			for key, want := range tt.want {
+				if got := part.FunctionResponse.Response[key]; got != want {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleBuiltInToolWithOptionsCancelled(t *testing.T) {
+	session, _ := newFakeSession(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := HandleBuiltInToolWithOptions(ctx, session, "wait_5_seconds", nil, BuiltInToolOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want the wait interrupted", err)
+	}
+}
+
+func TestHandleWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		waitMax time.Duration
+		waitDef time.Duration
+		want    time.Duration
+	}{
+		{name: "default", want: 5 * time.Second},
+		{name: "configured default", waitDef: 2 * time.Second, want: 2 * time.Second},
+		{name: "requested", args: map[string]any{"duration_seconds": 1.5}, want: 1500 * time.Millisecond},
+		{name: "zero", args: map[string]any{"duration_seconds": 0.0}, want: 0},
+		{name: "clamped to the default maximum", args: map[string]any{"duration_seconds": 120.0}, want: 30 * time.Second},
+		{name: "clamped to the configured maximum", args: map[string]any{"duration_seconds": 20.0}, waitMax: 10 * time.Second, want: 10 * time.Second},
+		{name: "huge value does not overflow", args: map[string]any{"duration_seconds": 1e300}, want: 30 * time.Second},
+		{name: "beyond the Duration range", args: map[string]any{"duration_seconds": 1e10}, waitMax: time.Hour, want: time.Hour},
+		{name: "default above the maximum", waitDef: time.Minute, waitMax: 10 * time.Second, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, _ := newFakeSession(t)
+			clock := newFakeClock()
+			opts := toolOptions{clock: clock, waitMax: tt.waitMax, waitDefault: tt.waitDef}
+
+			response, err := handleWait5Seconds(context.Background(), session, tt.args, opts)
+			if err != nil {
+				t.Fatalf("handleWait5Seconds: %v", err)
+			}
+			if sleeps := clock.sleeps(); len(sleeps) != 1 || sleeps[0] != tt.want {
+				t.Errorf("slept %v, want %v", sleeps, tt.want)
+			}
+			if response["waited_seconds"] != tt.want.Seconds() {
+				t.Errorf("waited_seconds = %v, want %v", response["waited_seconds"], tt.want.Seconds())
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package geminirod
+
+import (
+	"slices"
+
+	"google.golang.org/genai"
+)
+
+// turnContextPrefix delimits injected context from the rest of the user turn
+const turnContextPrefix = "[Context for this turn] "
+
+// injectedContext remembers parts injected into a content so they can be pruned later
+type injectedContext struct {
+	content *genai.Content
+	parts   []*genai.Part
+}
+
+// injectTurnContext appends texts to content as delimited text parts
+func injectTurnContext(content *genai.Content, texts []string) injectedContext {
+	injected := injectedContext{content: content}
+	for _, text := range texts {
+		part := &genai.Part{Text: turnContextPrefix + text}
+		content.Parts = append(content.Parts, part)
+		injected.parts = append(injected.parts, part)
+	}
+	return injected
+}
+
+// remove deletes the injected parts from their content
+func (ic injectedContext) remove() {
+	ic.content.Parts = slices.DeleteFunc(ic.content.Parts, func(part *genai.Part) bool {
+		return slices.Contains(ic.parts, part)
+	})
+}
@@ -0,0 +1,63 @@
+package geminirod
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestTurnContext(t *testing.T) {
+	tests := []struct {
+		name          string
+		prune         bool
+		wantFirstKept bool
+	}{
+		{name: "accumulated", wantFirstKept: true},
+		{name: "pruned", prune: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t,
+				fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))},
+				fakeReply{Response: callResponse(call("click_at", map[string]any{"x": 20.0, "y": 20.0}))},
+			)
+			config, _ := testConfig(t, backend)
+			config.PruneTurnContext = tt.prune
+			config.TurnContext = func(turn int) []string {
+				// Nothing is added on the second turn, pruning only happens when new context is injected
+				if turn == 2 {
+					return nil
+				}
+				return []string{fmt.Sprintf("budget left after turn %d", turn-1)}
+			}
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			calls := backend.calls()
+			if len(calls) != 3 {
+				t.Fatalf("got %d model calls, want 3", len(calls))
+			}
+			first := turnContextPrefix + "budget left after turn 0"
+			if !hasText(calls[0].Contents[:1], first) {
+				t.Error("context of the first turn not appended to the prompt")
+			}
+			if !hasText(calls[1].Contents, first) {
+				t.Error("context of the first turn pruned without new context")
+			}
+			if hasText(calls[1].Contents, turnContextPrefix+"budget left after turn 1") {
+				t.Error("context injected for a turn returning nil")
+			}
+
+			history := calls[2].Contents
+			if !hasText(history[len(history)-1:], turnContextPrefix+"budget left after turn 2") {
+				t.Error("context of the third turn not appended to the function responses")
+			}
+			if hasText(history, first) != tt.wantFirstKept {
+				t.Errorf("context of the first turn kept = %v, want %v", !tt.wantFirstKept, tt.wantFirstKept)
+			}
+		})
+	}
+}
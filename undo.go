@@ -0,0 +1,154 @@
+package geminirod
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/PeronGH/gemini-rod/internal/respparse"
+	"google.golang.org/genai"
+)
+
+// undoToolName is the name of the undo tool offered to the model
+const undoToolName = "undo_last_action"
+
+// maxUndoDepth bounds the number of actions remembered for undo
+const maxUndoDepth = 10
+
+// undoDeclaration declares the undo tool to the model
+var undoDeclaration = &genai.FunctionDeclaration{
+	Name: undoToolName,
+	Description: "Undo the most recent browser action when it has a known inverse. " +
+		"Only navigation (navigate, search, go_back, go_forward) and scrolling can be undone; " +
+		"clicks, typing, key presses and drags cannot, and the response says so. " +
+		"This is a heuristic: always check the returned screenshot.",
+}
+
+// undoAction is the inverse of an executed built-in tool call
+type undoAction struct {
+	name        string
+	args        map[string]any
+	description string
+}
+
+// inverseActions maps built-in tools to a function computing their inverse
+var inverseActions = map[string]func(args map[string]any) *undoAction{
+	"navigate":        inverseNavigation,
+	"search":          inverseNavigation,
+	"go_back":         func(map[string]any) *undoAction { return &undoAction{"go_forward", map[string]any{}, "went forward"} },
+	"go_forward":      func(map[string]any) *undoAction { return &undoAction{"go_back", map[string]any{}, "went back"} },
+	"scroll_document": inverseScroll,
+	"scroll_at":       inverseScroll,
+}
+
+// neutralActions have no side effects worth undoing and are not recorded
+var neutralActions = map[string]bool{
+	"open_web_browser": true,
+	"wait_5_seconds":   true,
+	"hover_at":         true,
+}
+
+func inverseNavigation(map[string]any) *undoAction {
+	return &undoAction{"go_back", map[string]any{}, "went back to the previous page"}
+}
+
+func inverseScroll(args map[string]any) *undoAction {
+	opposite := map[string]string{"up": "down", "down": "up", "left": "right", "right": "left"}
+	direction, _ := args["direction"].(string)
+	if _, ok := opposite[direction]; !ok {
+		return nil
+	}
+
+	inverseArgs := make(map[string]any, len(args))
+	for k, v := range args {
+		// A confirmation given for the scroll does not extend to its inverse
+		if k != respparse.SafetyDecisionField {
+			inverseArgs[k] = v
+		}
+	}
+	inverseArgs["direction"] = opposite[direction]
+	return &undoAction{"", inverseArgs, "scrolled " + opposite[direction]}
+}
+
+// undoStack records inverses of executed actions.
+// A nil entry marks an action without a known inverse, which blocks undoing past it.
+type undoStack struct {
+	actions []*undoAction
+}
+
+// record remembers the inverse of an executed built-in tool call
+func (s *undoStack) record(name string, args map[string]any) {
+	if neutralActions[name] {
+		return
+	}
+
+	var inverse *undoAction
+	if inverseFunc, ok := inverseActions[name]; ok {
+		inverse = inverseFunc(args)
+		if inverse != nil && inverse.name == "" {
+			inverse.name = name
+		}
+	}
+
+	s.actions = append(s.actions, inverse)
+	if len(s.actions) > maxUndoDepth {
+		s.actions = s.actions[1:]
+	}
+}
+
+// pop removes the inverse of the most recent action. When there is none to apply, it returns nil and the
+// response telling the model why; an inverse whose tool is excluded is dropped.
+func (s *undoStack) pop(excludedTools []string) (*undoAction, *genai.Part) {
+	if len(s.actions) == 0 {
+		return nil, undoneResponse(false, "there is no action to undo")
+	}
+
+	inverse := s.actions[len(s.actions)-1]
+	if inverse == nil {
+		return nil, undoneResponse(false, "the last action (such as a click, typing or a drag) has no known inverse; undo it manually")
+	}
+	s.actions = s.actions[:len(s.actions)-1]
+
+	if slices.Contains(excludedTools, inverse.name) {
+		return nil, undoneResponse(false, fmt.Sprintf("undoing the last action needs %s, which is disabled by policy", inverse.name))
+	}
+	return inverse, nil
+}
+
+// undoLastAction applies the inverse of the most recent action as if the model had called it, see
+// executeBuiltInCall, and returns the function response part. The inverse itself is not recorded.
+func undoLastAction(
+	ctx context.Context,
+	eventChan chan<- Event,
+	session BrowserSession,
+	undo *undoStack,
+	skipSafetyConfirmation bool,
+	opts toolOptions,
+) (*genai.Part, error) {
+	inverse, part := undo.pop(opts.excludedTools)
+	if inverse == nil {
+		return part, nil
+	}
+
+	part, err := executeBuiltInCall(ctx, eventChan, session, &genai.FunctionCall{Name: inverse.name, Args: inverse.args}, skipSafetyConfirmation, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Answer under the undo tool's name, keeping the URL and screenshot of the inverse action
+	part.FunctionResponse.Name = undoToolName
+	if _, failed := part.FunctionResponse.Response["error"]; failed {
+		part.FunctionResponse.Response["undone"] = false
+		return part, nil
+	}
+	part.FunctionResponse.Response["undone"] = true
+	part.FunctionResponse.Response["explanation"] = inverse.description
+	return part, nil
+}
+
+func undoneResponse(undone bool, explanation string) *genai.Part {
+	return genai.NewPartFromFunctionResponse(undoToolName, map[string]any{
+		"undone":      undone,
+		"explanation": explanation,
+	})
+}
@@ -0,0 +1,263 @@
+package geminirod
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestUndoStackRecordsInverses(t *testing.T) {
+	tests := []struct {
+		name        string
+		tool        string
+		args        map[string]any
+		wantInverse string // "" = no known inverse
+		wantArgs    map[string]any
+	}{
+		{name: "navigate", tool: "navigate", args: map[string]any{"url": "https://example.com"}, wantInverse: "go_back", wantArgs: map[string]any{}},
+		{name: "search", tool: "search", wantInverse: "go_back", wantArgs: map[string]any{}},
+		{name: "go back", tool: "go_back", wantInverse: "go_forward", wantArgs: map[string]any{}},
+		{name: "go forward", tool: "go_forward", wantInverse: "go_back", wantArgs: map[string]any{}},
+		{
+			name: "scroll document", tool: "scroll_document", args: map[string]any{"direction": "down"},
+			wantInverse: "scroll_document", wantArgs: map[string]any{"direction": "up"},
+		},
+		{
+			name: "scroll at keeps the position and magnitude", tool: "scroll_at",
+			args:        map[string]any{"x": 10.0, "y": 20.0, "direction": "left", "magnitude": 300.0},
+			wantInverse: "scroll_at", wantArgs: map[string]any{"x": 10.0, "y": 20.0, "direction": "right", "magnitude": 300.0},
+		},
+		{
+			name: "scroll drops the safety decision", tool: "scroll_document",
+			args:        map[string]any{"direction": "up", "safety_decision": map[string]any{"decision": "require_confirmation"}},
+			wantInverse: "scroll_document", wantArgs: map[string]any{"direction": "down"},
+		},
+		{name: "scroll without a direction", tool: "scroll_document", args: map[string]any{}},
+		{name: "click", tool: "click_at", args: map[string]any{"x": 10.0, "y": 10.0}},
+		{name: "typing", tool: "type_text_at", args: map[string]any{"x": 10.0, "y": 10.0, "text": "hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stack undoStack
+			stack.record(tt.tool, tt.args)
+
+			inverse, part := stack.pop(nil)
+			if tt.wantInverse == "" {
+				if inverse != nil {
+					t.Fatalf("inverse = %+v, want none", inverse)
+				}
+				if part.FunctionResponse.Response["undone"] != false {
+					t.Errorf("response = %v, want undone false", part.FunctionResponse.Response)
+				}
+				return
+			}
+			if inverse == nil {
+				t.Fatalf("no inverse, response %v", part.FunctionResponse.Response)
+			}
+			if inverse.name != tt.wantInverse {
+				t.Errorf("inverse = %s, want %s", inverse.name, tt.wantInverse)
+			}
+			if len(inverse.args) != len(tt.wantArgs) {
+				t.Fatalf("inverse args = %v, want %v", inverse.args, tt.wantArgs)
+			}
+			for key, want := range tt.wantArgs {
+				if inverse.args[key] != want {
+					t.Errorf("inverse args = %v, want %v", inverse.args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestUndoStackNeutralActionsAndDepth(t *testing.T) {
+	var stack undoStack
+	stack.record("navigate", map[string]any{"url": "https://example.com"})
+	stack.record("hover_at", map[string]any{"x": 10.0, "y": 10.0})
+	stack.record("wait_5_seconds", nil)
+	if inverse, _ := stack.pop(nil); inverse == nil || inverse.name != "go_back" {
+		t.Fatalf("inverse = %+v, want go_back past the neutral actions", inverse)
+	}
+
+	for range maxUndoDepth + 5 {
+		stack.record("go_back", nil)
+	}
+	if len(stack.actions) != maxUndoDepth {
+		t.Errorf("stack holds %d actions, want %d", len(stack.actions), maxUndoDepth)
+	}
+}
+
+func TestUndoStackEmpty(t *testing.T) {
+	var stack undoStack
+	inverse, part := stack.pop(nil)
+	if inverse != nil {
+		t.Fatalf("inverse = %+v, want none", inverse)
+	}
+	response := part.FunctionResponse
+	if response.Name != undoToolName || response.Response["undone"] != false || response.Response["explanation"] != "there is no action to undo" {
+		t.Errorf("response = %s %v, want undone false with an explanation", response.Name, response.Response)
+	}
+}
+
+func TestUndoNotOfferedByDefault(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+	collectEvents(t, context.Background(), config, nil)
+	if slices.Contains(backend.calls()[0].declaredFunctions(), undoToolName) {
+		t.Errorf("%s declared without EnableUndo", undoToolName)
+	}
+
+	config.EnableUndo = true
+	collectEvents(t, context.Background(), config, nil)
+	if !slices.Contains(backend.calls()[1].declaredFunctions(), undoToolName) {
+		t.Errorf("%s not declared with EnableUndo", undoToolName)
+	}
+}
+
+func TestUndoInLoop(t *testing.T) {
+	tests := []struct {
+		name        string
+		before      []map[string]any // Calls executed in the turn before the undo, by name and args
+		excluded    []string
+		wantUndone  bool
+		wantInverse string // Tool of the ToolResultEvent the undo produces, "" = none
+	}{
+		{name: "empty stack"},
+		{
+			name:        "navigation",
+			before:      []map[string]any{{"name": "navigate", "url": "https://example.com/next"}},
+			wantUndone:  true,
+			wantInverse: "go_back",
+		},
+		{
+			name:     "inverse tool excluded",
+			before:   []map[string]any{{"name": "navigate", "url": "https://example.com/next"}},
+			excluded: []string{"go_back"},
+		},
+		{
+			name:   "no known inverse",
+			before: []map[string]any{{"name": "click_at", "x": 10.0, "y": 10.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var replies []fakeReply
+			for _, before := range tt.before {
+				args := make(map[string]any)
+				for key, val := range before {
+					if key != "name" {
+						args[key] = val
+					}
+				}
+				replies = append(replies, fakeReply{Response: callResponse(call(before["name"].(string), args))})
+			}
+			replies = append(replies, fakeReply{Response: callResponse(call(undoToolName, nil))})
+			backend := newFakeBackend(t, replies...)
+			config, _ := testConfig(t, backend)
+			config.EnableUndo = true
+			config.ExcludedTools = tt.excluded
+
+			events := collectEvents(t, context.Background(), config, nil)
+			if err := finalError(events); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			calls := backend.calls()
+			history := calls[len(calls)-1].Contents
+			response := functionResponses(history[len(history)-1])[undoToolName]
+			if response == nil {
+				t.Fatal("undo response missing from the last request")
+			}
+			if response.Response["undone"] != tt.wantUndone || response.Response["explanation"] == nil {
+				t.Errorf("response = %v, want undone %v with an explanation", response.Response, tt.wantUndone)
+			}
+
+			// The inverse is reported like a call of the model, and only when it ran
+			results := eventsOf[ToolResultEvent](events)
+			undoResults := results[len(tt.before):]
+			if tt.wantInverse == "" {
+				if len(undoResults) != 0 {
+					t.Errorf("ToolResultEvents of the undo = %+v, want none", undoResults)
+				}
+				return
+			}
+			if len(undoResults) != 1 || undoResults[0].ToolName != tt.wantInverse || undoResults[0].Err != nil {
+				t.Errorf("ToolResultEvents of the undo = %+v, want a successful %s", undoResults, tt.wantInverse)
+			}
+		})
+	}
+}
+
+func TestUndoRunsThroughMiddleware(t *testing.T) {
+	backend := newFakeBackend(t,
+		fakeReply{Response: callResponse(call("navigate", map[string]any{"url": "https://example.com/next"}))},
+		fakeReply{Response: callResponse(call(undoToolName, nil))},
+	)
+	config, _ := testConfig(t, backend)
+	config.EnableUndo = true
+	var seen []string
+	config.ToolMiddleware = []ToolMiddleware{func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+			name, _ := ToolNameFromContext(ctx)
+			seen = append(seen, name)
+			return next(ctx, session, args)
+		}
+	}}
+
+	events := collectEvents(t, context.Background(), config, nil)
+	if err := finalError(events); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if !slices.Equal(seen, []string{"navigate", "go_back"}) {
+		t.Errorf("middleware saw %v, want navigate then the inverse go_back", seen)
+	}
+}
+
+func TestUndoFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      ToolErrorMode
+		wantFatal bool
+	}{
+		{name: "reported to the model", mode: ToolErrorModeReport},
+		{name: "ends the run", mode: ToolErrorModeAbort, wantFatal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t,
+				fakeReply{Response: callResponse(call("navigate", map[string]any{"url": "https://example.com/next"}))},
+				fakeReply{Response: callResponse(call(undoToolName, nil))},
+			)
+			config, _ := testConfig(t, backend)
+			config.EnableUndo = true
+			config.ToolErrorMode = tt.mode
+			failing := errors.New("Node not found")
+			config.ToolOverrides = map[string]ToolHandler{
+				"go_back": func(ctx context.Context, session BrowserSession, args map[string]any) (map[string]any, error) {
+					return nil, failing
+				},
+			}
+
+			events := collectEvents(t, context.Background(), config, nil)
+			err := finalError(events)
+			if tt.wantFatal {
+				if !errors.Is(err, failing) {
+					t.Fatalf("error = %v, want the go_back failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			calls := backend.calls()
+			if len(calls) != 3 {
+				t.Fatalf("got %d model calls, want 3", len(calls))
+			}
+			history := calls[2].Contents
+			response := functionResponses(history[len(history)-1])[undoToolName]
+			if response == nil || response.Response["undone"] != false || response.Response["error"] == nil {
+				t.Errorf("undo response = %+v, want undone false with the error", response)
+			}
+		})
+	}
+}
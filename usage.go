@@ -0,0 +1,53 @@
+package geminirod
+
+import (
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+// Usage counts the tokens of model calls, as reported by the API
+type Usage struct {
+	PromptTokens     int // Input tokens, including the history
+	CachedTokens     int // Part of PromptTokens served from the context cache
+	CandidatesTokens int // Output tokens of the responses
+	ThoughtsTokens   int // Tokens spent on thinking, if the model thinks
+	TotalTokens      int
+	Calls            int // Model calls counted, retries of empty responses included
+}
+
+// add counts the usage of one response, if it reports any
+func (u *Usage) add(metadata *genai.GenerateContentResponseUsageMetadata) {
+	if metadata == nil {
+		return
+	}
+	u.PromptTokens += int(metadata.PromptTokenCount)
+	u.CachedTokens += int(metadata.CachedContentTokenCount)
+	u.CandidatesTokens += int(metadata.CandidatesTokenCount)
+	u.ThoughtsTokens += int(metadata.ThoughtsTokenCount)
+	u.TotalTokens += int(metadata.TotalTokenCount)
+	u.Calls++
+}
+
+// Pricing gives model prices in a currency of the caller's choice, for estimating the cost of a run
+type Pricing struct {
+	InputPerMillion  float64 // Price of a million prompt tokens
+	OutputPerMillion float64 // Price of a million output tokens, thinking included
+}
+
+// EstimatedCost returns the cost of the usage at the given prices
+func (u Usage) EstimatedCost(pricing Pricing) float64 {
+	return float64(u.PromptTokens)*pricing.InputPerMillion/1e6 +
+		float64(u.CandidatesTokens+u.ThoughtsTokens)*pricing.OutputPerMillion/1e6
+}
+
+// budgetExceeded returns why usage is over the budget, or "" if it is within it. A zero limit is unlimited.
+func budgetExceeded(usage Usage, maxTokens int, maxCost float64, pricing Pricing) string {
+	if maxTokens > 0 && usage.TotalTokens >= maxTokens {
+		return fmt.Sprintf("used %d tokens, MaxTotalTokens is %d", usage.TotalTokens, maxTokens)
+	}
+	if cost := usage.EstimatedCost(pricing); maxCost > 0 && cost >= maxCost {
+		return fmt.Sprintf("estimated cost %.4f reached MaxEstimatedCost %.4f", cost, maxCost)
+	}
+	return ""
+}
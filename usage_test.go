@@ -0,0 +1,95 @@
+package geminirod
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// clickWithUsage is a model turn clicking, reporting prompt and output tokens
+func clickWithUsage(prompt, output int32) fakeReply {
+	resp := callResponse(call("click_at", map[string]any{"x": 10.0, "y": 10.0}))
+	resp.UsageMetadata = &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     prompt,
+		CandidatesTokenCount: output,
+		TotalTokenCount:      prompt + output,
+	}
+	return fakeReply{Response: resp}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	pricing := Pricing{InputPerMillion: 1, OutputPerMillion: 4}
+	usage := Usage{PromptTokens: 600_000, CandidatesTokens: 50_000, ThoughtsTokens: 50_000, TotalTokens: 700_000}
+	if cost := usage.EstimatedCost(pricing); cost != 1 {
+		t.Fatalf("EstimatedCost = %v, want 1", cost)
+	}
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		maxCost   float64
+		want      string // Part of the reason, "" = within the budget
+	}{
+		{name: "unlimited"},
+		{name: "within the tokens", maxTokens: 700_001},
+		{name: "tokens reached", maxTokens: 700_000, want: "MaxTotalTokens"},
+		{name: "within the cost", maxCost: 1.5},
+		{name: "cost reached", maxCost: 1, want: "MaxEstimatedCost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := budgetExceeded(usage, tt.maxTokens, tt.maxCost, pricing)
+			if (reason == "") != (tt.want == "") || !strings.Contains(reason, tt.want) {
+				t.Errorf("reason = %q, want one containing %q", reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetInLoop(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTokens int
+		maxCost   float64
+		wantCalls int
+	}{
+		{name: "token budget", maxTokens: 2500, wantCalls: 3},
+		{name: "cost budget", maxCost: 0.002, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend(t, clickWithUsage(900, 100), clickWithUsage(900, 100), clickWithUsage(900, 100), clickWithUsage(900, 100))
+			config, _ := testConfig(t, backend)
+			config.MaxTotalTokens = tt.maxTokens
+			config.MaxEstimatedCost = tt.maxCost
+			config.Pricing = Pricing{InputPerMillion: 1, OutputPerMillion: 1}
+
+			// The budget is checked before each model call, the turn that reaches it is finished
+			events := collectEvents(t, context.Background(), config, nil)
+			if got := len(backend.calls()); got != tt.wantCalls {
+				t.Errorf("got %d model calls, want %d", got, tt.wantCalls)
+			}
+			exceeded := eventsOf[BudgetExceededEvent](events)
+			if len(exceeded) != 1 {
+				t.Fatalf("got %d BudgetExceededEvents, want 1", len(exceeded))
+			}
+			if exceeded[0].Usage.TotalTokens != 1000*tt.wantCalls || exceeded[0].EstimatedCost != float64(tt.wantCalls)/1000 {
+				t.Errorf("BudgetExceededEvent = %+v, want the usage of %d calls", exceeded[0], tt.wantCalls)
+			}
+			if completions := eventsOf[CompletionEvent](events); len(completions) != 1 || completions[0].Turns != tt.wantCalls {
+				t.Errorf("CompletionEvents = %+v, want one after %d turns", completions, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBudgetRequiresPricing(t *testing.T) {
+	backend := newFakeBackend(t)
+	config, _ := testConfig(t, backend)
+	config.MaxEstimatedCost = 1
+	if err := config.Validate(context.Background()); err == nil || !strings.Contains(err.Error(), "Pricing") {
+		t.Errorf("error = %v, want MaxEstimatedCost refused without Pricing", err)
+	}
+}